@@ -13,6 +13,10 @@ type Seed struct {
 	Name     string
 	Role     model.RoleCode
 	Grants   []model.Grant
+
+	// MustChangePassword makes the seeded password temporary: the first
+	// login only reaches change_password.
+	MustChangePassword bool
 }
 
 // Bootstrap seeds the first user and their initial permissions. NO-OP if the users
@@ -40,7 +44,11 @@ func (m *Module) Bootstrap(s Seed) error {
 	}
 
 	// 2. Set Password
-	if err := m.SetPassword(u.Id, s.Password); err != nil {
+	setPassword := m.SetPassword
+	if s.MustChangePassword {
+		setPassword = m.SetTemporaryPassword
+	}
+	if err := setPassword(u.Id, s.Password); err != nil {
 		return err
 	}
 
@@ -0,0 +1,50 @@
+package authority
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/user"
+)
+
+// LoadBreachedList reads a breached/common password list — one password per
+// line, blank lines and '#' comments ignored — into a BreachedSet for
+// PasswordPolicy.Breached. fp is the false-positive rate (e.g. 0.001).
+//
+// The file is read twice, once to size the filter and once to fill it, so
+// only the filter is ever held in memory however long the list is.
+func LoadBreachedList(path string, fp float64) (*user.BreachedSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	n := 0
+	if err := eachBreached(f, func(string) { n++ }); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	set := user.NewBreachedSet(n, fp)
+	if err := eachBreached(f, set.Add); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// eachBreached calls fn with every password in the list r.
+func eachBreached(r io.Reader, fn func(string)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := fmt.Convert(sc.Text()).TrimSpace().String()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fn(line)
+	}
+	return sc.Err()
+}
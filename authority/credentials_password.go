@@ -8,43 +8,119 @@ import (
 
 // Login verifies email+password directly (no HTTP) — used by
 // email_password/credentials tests and any admin flow that needs to verify a
-// user's password without going through the mounted route.
+// user's password without going through the mounted route. It honours the
+// same lockout as POST /login.
 func (m *Module) Login(email, password string) (user.User, error) {
+	if m.Locked(email) {
+		emailpassword.DummyCompare(password)
+		return user.User{}, user.ErrAccountLocked
+	}
+	u, err := m.checkPassword(email, password)
+	if err != nil {
+		m.RecordFailure(email, "")
+		return user.User{}, err
+	}
+	m.RecordSuccess(email)
+	m.StartPasswordClock(u.Id)
+	// Only now, with the password proven, may the caller learn the account
+	// exists but is waiting on its email.
+	if u.Status == "pending" {
+		m.notify(user.SecurityEvent{Type: user.EventPendingAccess, UserID: u.Id})
+		return user.User{}, user.ErrEmailUnverified
+	}
+	return u, nil
+}
+
+// checkPassword resolves email and verifies password, paying one hash
+// comparison on every path.
+func (m *Module) checkPassword(email, password string) (user.User, error) {
 	u, err := m.UserByEmail(email)
 	if err != nil {
-		emailpassword.DummyCompare(password, emailpassword.DefaultHashCost)
+		emailpassword.DummyCompare(password)
 		return user.User{}, user.ErrInvalidCredentials
 	}
-	if u.Status != "active" {
-		emailpassword.DummyCompare(password, emailpassword.DefaultHashCost)
+	if u.Status != "active" && u.Status != "pending" {
+		emailpassword.DummyCompare(password)
 		return user.User{}, user.ErrInvalidCredentials
 	}
 	identity, err := m.IdentityFor(u.Id, "email_password")
 	if err != nil {
-		emailpassword.DummyCompare(password, emailpassword.DefaultHashCost)
+		emailpassword.DummyCompare(password)
 		return user.User{}, user.ErrInvalidCredentials
 	}
 	if err := emailpassword.VerifyPassword(identity.ProviderId, password); err != nil {
 		return user.User{}, err
 	}
+	if hash, ok := emailpassword.Rehash(identity.ProviderId, password); ok {
+		m.UpsertIdentity(u.Id, "email_password", hash, "")
+	}
 	return u, nil
 }
 
-// SetPassword hashes and stores password as userID's email_password credential.
-func (m *Module) SetPassword(userID, password string) error {
-	if len(password) < 8 {
+// ValidatePassword applies the password policy without storing anything, so a
+// sign-up can reject a weak password before it creates the user. personal is
+// what PasswordPolicy.RejectPersonal compares against (email, name). A
+// password longer than the hasher takes is refused here too, as too_long,
+// rather than failing later in SetPassword.
+func (m *Module) ValidatePassword(password string, personal ...string) error {
+	if max := emailpassword.MaxPasswordLength(); max > 0 && len(password) > max {
+		return &user.PolicyError{Reasons: []user.PolicyReason{user.ReasonTooLong}}
+	}
+	if p := m.config.PasswordPolicy; p != nil {
+		if err := p.Check(password, personal...); err != nil {
+			return err
+		}
+	} else if len(password) < 8 {
 		return user.ErrWeakPassword
 	}
 	if m.config.OnPasswordValidate != nil {
-		if err := m.config.OnPasswordValidate(password); err != nil {
+		return m.config.OnPasswordValidate(password)
+	}
+	return nil
+}
+
+// SetPassword hashes and stores password as userID's email_password credential.
+func (m *Module) SetPassword(userID, password string) error {
+	if err := m.checkNewPassword(userID, password); err != nil {
+		return err
+	}
+	return m.storePassword(userID, password)
+}
+
+// checkNewPassword is every rule SetPassword enforces: the policy and, with
+// PasswordHistory, reuse.
+func (m *Module) checkNewPassword(userID, password string) error {
+	if err := m.validateFor(userID, password); err != nil {
+		return err
+	}
+	return m.checkReuse(userID, password)
+}
+
+// storePassword is SetPassword past its checks.
+func (m *Module) storePassword(userID, password string) error {
+	hash, err := emailpassword.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := m.UpsertIdentity(userID, "email_password", hash, ""); err != nil {
+		return err
+	}
+	if m.historyOn() {
+		if err := m.recordPassword(userID, hash); err != nil {
 			return err
 		}
 	}
-	hash, err := emailpassword.HashPassword(password, emailpassword.DefaultHashCost)
+	return m.savePasswordState(userID, false)
+}
+
+// validateFor runs ValidatePassword with userID's own email and name as the
+// personal data.
+func (m *Module) validateFor(userID, password string) error {
+	u, err := m.UserByID(userID)
 	if err != nil {
-		return err
+		return m.ValidatePassword(password)
 	}
-	return m.UpsertIdentity(userID, "email_password", hash, "")
+	return m.ValidatePassword(password, u.Email, u.Name)
 }
 
 // VerifyPassword checks password against userID's stored email_password hash.
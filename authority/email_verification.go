@@ -0,0 +1,115 @@
+package authority
+
+import (
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// CreateVerification marks userID "pending" until the token mailed to it comes
+// back through VerifyEmail. Login refuses a pending account even with the
+// right password.
+func (m *Module) CreateVerification(userID string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := deleteVerificationsByUser(m.db, userID); err != nil {
+		return "", err
+	}
+	if err := setUserStatus(m.db, m.ucache, userID, "pending"); err != nil {
+		return "", err
+	}
+	now := time.Now() / 1e9
+	v := &user.EmailVerification{
+		TokenHash: hashToken(token),
+		UserId:    userID,
+		ExpiresAt: now + int64(m.config.VerifyTokenTTL),
+		CreatedAt: now,
+	}
+	if err := m.db.Create(v); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerifyEmail activates the account a verification token was minted for. Only
+// a "pending" account is activated: a link arriving after an admin suspended
+// the user must not undo the suspension.
+func (m *Module) VerifyEmail(token string) (string, error) {
+	qb := m.db.Query(&user.EmailVerification{}).Where(user.EmailVerification_.TokenHash).Eq(hashToken(token))
+	results, err := user.ReadAllEmailVerification(qb)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", user.ErrInvalidToken
+	}
+	v := results[0]
+	if err := m.db.Delete(v, orm.Eq(user.EmailVerification_.TokenHash, v.TokenHash)); err != nil {
+		return "", err
+	}
+	if v.ExpiresAt < time.Now()/1e9 {
+		return "", user.ErrInvalidToken
+	}
+	u, err := m.UserByID(v.UserId)
+	if err != nil {
+		return "", err
+	}
+	if u.Status == "pending" {
+		if err := setUserStatus(m.db, m.ucache, u.Id, "active"); err != nil {
+			return "", err
+		}
+	}
+	return u.Id, nil
+}
+
+// ClaimPending hands a "pending" account to someone who just proved, through
+// a provider that verified it, that they own its email. The password chosen
+// at sign-up goes: whoever chose it never proved as much, and may have signed
+// the address up first to wait for its owner. Other statuses answer
+// ErrNotFound and are left alone.
+func (m *Module) ClaimPending(userID string) error {
+	u, err := m.UserByID(userID)
+	if err != nil {
+		return err
+	}
+	if u.Status != "pending" {
+		return user.ErrNotFound
+	}
+	if identity, err := getIdentityByUserAndProvider(m.db, userID, "email_password"); err == nil {
+		if err := m.db.Delete(&identity, orm.Eq(user.Identity_.Id, identity.Id)); err != nil {
+			return err
+		}
+	}
+	if err := deleteVerificationsByUser(m.db, userID); err != nil {
+		return err
+	}
+	return setUserStatus(m.db, m.ucache, userID, "active")
+}
+
+// PurgeExpiredVerifications is maintenance, not part of any port. Accounts
+// whose link expired stay "pending"; an admin activates them with
+// ReactivateUser.
+func (m *Module) PurgeExpiredVerifications() error {
+	qb := m.db.Query(&user.EmailVerification{}).Where(user.EmailVerification_.ExpiresAt).Lt(time.Now() / 1e9)
+	list, _ := user.ReadAllEmailVerification(qb)
+	for _, v := range list {
+		m.db.Delete(v, orm.Eq(user.EmailVerification_.TokenHash, v.TokenHash))
+	}
+	return nil
+}
+
+func deleteVerificationsByUser(db *orm.DB, userID string) error {
+	qb := db.Query(&user.EmailVerification{}).Where(user.EmailVerification_.UserId).Eq(userID)
+	list, err := user.ReadAllEmailVerification(qb)
+	if err != nil {
+		return err
+	}
+	for _, v := range list {
+		if err := db.Delete(v, orm.Eq(user.EmailVerification_.TokenHash, v.TokenHash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -99,34 +99,62 @@ func upsertIdentity(db *orm.DB, ids model.IDGenerator, userID, provider, provide
 	}
 }
 
+// UnlinkIdentity removes userID's identity for provider; see RemoveIdentity.
 func (m *Module) UnlinkIdentity(userID, provider string) error {
-	identities, err := m.GetUserIdentities(userID)
+	identity, err := getIdentityByUserAndProvider(m.db, userID, provider)
 	if err != nil {
 		return err
 	}
+	return m.RemoveIdentity(userID, identity.Id)
+}
 
-	found := false
-	for _, id := range identities {
-		if id.Provider == provider {
-			found = true
-			break
+// RemoveIdentity unlinks one of userID's login methods, refusing with
+// ErrCannotUnlink when it is the last one: no other identity left and no
+// enabled user.AddressLogin that Reaches the user. Stored provider tokens and
+// a passkey's key go with their identity; removing the passkey that was the
+// user's last second factor is reported as EventMFADisabled too.
+func (m *Module) RemoveIdentity(userID, identityID string) error {
+	identities, err := m.GetUserIdentities(userID)
+	if err != nil {
+		return err
+	}
+	var target *user.Identity
+	for i := range identities {
+		if identities[i].Id == identityID {
+			target = &identities[i]
 		}
 	}
-	if !found {
+	if target == nil {
 		return user.ErrNotFound
 	}
-
-	if len(identities) <= 1 {
+	if len(identities) <= 1 && !m.reachableByAddress(userID) {
 		return user.ErrCannotUnlink
 	}
 
-	qb := m.db.Query(&user.Identity{}).Where(user.Identity_.UserId).Eq(userID).Where(user.Identity_.Provider).Eq(provider)
-	results, err := user.ReadAllIdentity(qb)
-	if err != nil {
+	hadMFA := m.RequiresMFA(userID)
+	if err := m.db.Delete(target, orm.Eq(user.Identity_.Id, target.Id)); err != nil {
 		return err
 	}
-	if len(results) > 0 {
-		return m.db.Delete(results[0], orm.Eq(user.Identity_.Id, results[0].Id))
+	deleteProviderToken(m.db, target.Id)
+	if target.Provider == providerWebAuthn {
+		m.db.Delete(&user.WebAuthnCredential{Id: target.ProviderId}, orm.Eq(user.WebAuthnCredential_.Id, target.ProviderId))
+	}
+	m.notify(user.SecurityEvent{Type: user.EventIdentityUnlinked, UserID: userID, Provider: target.Provider})
+	if hadMFA && !m.RequiresMFA(userID) {
+		m.notify(user.SecurityEvent{Type: user.EventMFADisabled, UserID: userID, Mode: target.Provider})
+	}
+	return nil
+}
+
+func (m *Module) reachableByAddress(userID string) bool {
+	u, err := m.GetUser(userID)
+	if err != nil {
+		return false
+	}
+	for _, a := range m.authenticators {
+		if al, ok := a.(user.AddressLogin); ok && al.Reaches(u) {
+			return true
+		}
 	}
-	return user.ErrNotFound
+	return false
 }
@@ -0,0 +1,125 @@
+package authority
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// Locked reports whether identifier is inside a lock window. Always false
+// with Config.LockThreshold == 0.
+func (m *Module) Locked(identifier string) bool {
+	if m.config.LockThreshold == 0 {
+		return false
+	}
+	l, ok := getLoginLock(m.db, lockKey(identifier))
+	return ok && l.LockedUntil > time.Now()/1e9
+}
+
+// RecordFailure counts one failed login for identifier. Reaching
+// Config.LockThreshold locks it for LockWindow seconds, doubled for every
+// earlier lock still on record, capped at LockMaxWindow. The record is
+// forgiven after LockMaxWindow seconds without failures or locks.
+//
+// Concurrent failures are counted one by one, the way countMFAFailure does:
+// the write is conditioned on the claim just read and the claim is read back,
+// so a writer that lost — or lost the race to create the row — retries
+// against the winner's row instead of overwriting it.
+func (m *Module) RecordFailure(identifier, ip string) {
+	if m.config.LockThreshold == 0 {
+		return
+	}
+	key := lockKey(identifier)
+	for try := 0; try < 8; try++ {
+		claim, err := newToken()
+		if err != nil {
+			return
+		}
+		now := time.Now() / 1e9
+		l, exists := getLoginLock(m.db, key)
+		if !exists {
+			l = &user.LoginLock{Identifier: key}
+		}
+		seen := l.Claim
+		if now-max(l.UpdatedAt, l.LockedUntil) > int64(m.config.LockMaxWindow) {
+			l.Failures, l.LockCount = 0, 0
+		}
+		l.Failures++
+		l.UpdatedAt = now
+		locked := l.Failures >= int64(m.config.LockThreshold)
+		if locked {
+			l.LockedUntil = now + m.lockWindow(l.LockCount)
+			l.LockCount++
+			l.Failures = 0
+		}
+		l.Claim = claim
+		switch {
+		case !exists:
+			err = m.db.Create(l)
+		case seen == "":
+			// A row from before the claim column has none to match (NULL):
+			// its first count is a plain update.
+			err = m.db.Update(l, orm.Eq(user.LoginLock_.Identifier, key))
+		default:
+			err = m.db.Update(l, orm.Eq(user.LoginLock_.Identifier, key), orm.Eq(user.LoginLock_.Claim, seen))
+		}
+		if err != nil {
+			continue
+		}
+		// Gone means RecordSuccess just forgave it.
+		if got, ok := getLoginLock(m.db, key); ok && got.Claim != claim {
+			continue
+		}
+		if locked {
+			m.notify(user.SecurityEvent{Type: user.EventAccountLocked, IP: ip, UserID: key})
+		}
+		return
+	}
+}
+
+// RecordSuccess clears identifier's failure history.
+func (m *Module) RecordSuccess(identifier string) {
+	if m.config.LockThreshold == 0 {
+		return
+	}
+	deleteLoginLock(m.db, lockKey(identifier))
+}
+
+// UnlockUser lifts a lockout on userID's email and forgets its failures.
+func (m *Module) UnlockUser(userID string) error {
+	u, err := m.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	return deleteLoginLock(m.db, lockKey(u.Email))
+}
+
+func (m *Module) lockWindow(earlierLocks int64) int64 {
+	window, limit := int64(m.config.LockWindow), int64(m.config.LockMaxWindow)
+	for i := int64(0); i < earlierLocks && window < limit; i++ {
+		window *= 2
+	}
+	return min(window, limit)
+}
+
+func lockKey(identifier string) string {
+	return fmt.Convert(identifier).TrimSpace().ToLower().String()
+}
+
+func getLoginLock(db *orm.DB, key string) (*user.LoginLock, bool) {
+	qb := db.Query(&user.LoginLock{}).Where(user.LoginLock_.Identifier).Eq(key)
+	results, err := user.ReadAllLoginLock(qb)
+	if err != nil || len(results) == 0 {
+		return nil, false
+	}
+	return results[0], true
+}
+
+func deleteLoginLock(db *orm.DB, key string) error {
+	l, ok := getLoginLock(db, key)
+	if !ok {
+		return nil
+	}
+	return db.Delete(l, orm.Eq(user.LoginLock_.Identifier, key))
+}
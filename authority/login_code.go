@@ -0,0 +1,144 @@
+package authority
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"math/big"
+
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// loginCodeWindow is the span Config.LoginCodeSends counts codes over.
+const loginCodeWindow = 3600
+
+// CreateLoginCode mints a 6-digit code for userID bound to client, replacing
+// any earlier one: only the most recent code a user asked for works. Past
+// Config.LoginCodeSends codes within the hour it answers ErrRateLimited,
+// whatever limiter the mode has — otherwise burning a code and asking for the
+// next would make LoginCodeAttempts meaningless.
+func (m *Module) CreateLoginCode(userID, client string) (string, error) {
+	prev, err := loginCodesByUser(m.db, userID)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now() / 1e9
+	sends, windowAt := int64(1), now
+	for _, p := range prev {
+		if now-p.WindowAt < loginCodeWindow {
+			if p.Sends >= int64(m.config.LoginCodeSends) {
+				return "", user.ErrRateLimited
+			}
+			sends, windowAt = p.Sends+1, p.WindowAt
+		}
+	}
+	code, err := newCode(6)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range prev {
+		if err := m.db.Delete(p, orm.Eq(user.LoginCode_.ClientHash, p.ClientHash)); err != nil {
+			return "", err
+		}
+	}
+	c := &user.LoginCode{
+		ClientHash: hashToken(client),
+		UserId:     userID,
+		CodeHash:   hashToken(code),
+		Sends:      sends,
+		WindowAt:   windowAt,
+		ExpiresAt:  now + int64(m.config.LoginCodeTTL),
+		CreatedAt:  now,
+	}
+	if err := m.db.Create(c); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// VerifyLoginCode looks the code up by its client, so another device holding
+// the right digits finds nothing. Every guess spends one of
+// Config.LoginCodeAttempts before it is compared, in constant time; a success
+// deletes the code, while a burned one stays until PurgeExpiredLoginCodes so
+// its sends still count.
+func (m *Module) VerifyLoginCode(client, code string) (string, error) {
+	c, err := loginCodeByClient(m.db, client)
+	if err != nil {
+		return "", err
+	}
+	if c.ExpiresAt < time.Now()/1e9 || c.Attempts >= int64(m.config.LoginCodeAttempts) {
+		return "", user.ErrInvalidCode
+	}
+
+	// The turn is taken with an UPDATE conditioned on the attempts just read
+	// and tagged with a fresh claim. Of concurrent guesses only one matches
+	// that condition; reading the claim back tells the others they lost, and
+	// they fail like wrong guesses.
+	claim, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	seen := c.Attempts
+	c.Attempts, c.Claim = seen+1, claim
+	if err := m.db.Update(c, orm.Eq(user.LoginCode_.ClientHash, c.ClientHash), orm.Eq(user.LoginCode_.Attempts, seen)); err != nil {
+		return "", err
+	}
+	if got, err := loginCodeByClient(m.db, client); err != nil || got.Claim != claim {
+		return "", user.ErrInvalidCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(code)), []byte(c.CodeHash)) != 1 {
+		return "", user.ErrInvalidCode
+	}
+	if err := m.db.Delete(c, orm.Eq(user.LoginCode_.ClientHash, c.ClientHash)); err != nil {
+		return "", err
+	}
+	return c.UserId, nil
+}
+
+// PurgeExpiredLoginCodes is maintenance, not part of any port — call it
+// periodically alongside PurgeExpiredMagicLinks. A code is kept past its
+// expiry until its LoginCodeSends window closes.
+func (m *Module) PurgeExpiredLoginCodes() error {
+	now := time.Now() / 1e9
+	qb := m.db.Query(&user.LoginCode{}).Where(user.LoginCode_.ExpiresAt).Lt(now)
+	codes, _ := user.ReadAllLoginCode(qb)
+	for _, c := range codes {
+		if now-c.WindowAt >= loginCodeWindow {
+			m.db.Delete(c, orm.Eq(user.LoginCode_.ClientHash, c.ClientHash))
+		}
+	}
+	return nil
+}
+
+// newCode returns n uniformly random decimal digits from crypto/rand.
+func newCode(n int) (string, error) {
+	b := make([]byte, n)
+	ten := big.NewInt(10)
+	for i := range b {
+		d, err := rand.Int(rand.Reader, ten)
+		if err != nil {
+			return "", err
+		}
+		b[i] = '0' + byte(d.Int64())
+	}
+	return string(b), nil
+}
+
+func loginCodeByClient(db *orm.DB, client string) (*user.LoginCode, error) {
+	qb := db.Query(&user.LoginCode{}).Where(user.LoginCode_.ClientHash).Eq(hashToken(client))
+	results, err := user.ReadAllLoginCode(qb)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, user.ErrInvalidCode
+	}
+	return results[0], nil
+}
+
+func loginCodesByUser(db *orm.DB, userID string) (user.LoginCodeList, error) {
+	qb := db.Query(&user.LoginCode{}).Where(user.LoginCode_.UserId).Eq(userID)
+	return user.ReadAllLoginCode(qb)
+}
@@ -0,0 +1,76 @@
+package authority
+
+import (
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// CreateMagicLink mints a login link token for userID, replacing any earlier
+// one: only the most recent link a user asked for works.
+func (m *Module) CreateMagicLink(userID string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := deleteMagicLinksByUser(m.db, userID); err != nil {
+		return "", err
+	}
+	now := time.Now() / 1e9
+	l := &user.MagicLink{
+		TokenHash: hashToken(token),
+		UserId:    userID,
+		ExpiresAt: now + int64(m.config.MagicLinkTTL),
+		CreatedAt: now,
+	}
+	if err := m.db.Create(l); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeMagicLink deletes the row before checking its expiry, so a link is
+// spent by its first use whether or not that use succeeds.
+func (m *Module) ConsumeMagicLink(token string) (string, error) {
+	qb := m.db.Query(&user.MagicLink{}).Where(user.MagicLink_.TokenHash).Eq(hashToken(token))
+	results, err := user.ReadAllMagicLink(qb)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", user.ErrInvalidToken
+	}
+	l := results[0]
+	if err := m.db.Delete(l, orm.Eq(user.MagicLink_.TokenHash, l.TokenHash)); err != nil {
+		return "", err
+	}
+	if l.ExpiresAt < time.Now()/1e9 {
+		return "", user.ErrInvalidToken
+	}
+	return l.UserId, nil
+}
+
+// PurgeExpiredMagicLinks is maintenance, not part of any port — call it
+// periodically alongside PurgeExpiredResetTokens.
+func (m *Module) PurgeExpiredMagicLinks() error {
+	qb := m.db.Query(&user.MagicLink{}).Where(user.MagicLink_.ExpiresAt).Lt(time.Now() / 1e9)
+	links, _ := user.ReadAllMagicLink(qb)
+	for _, l := range links {
+		m.db.Delete(l, orm.Eq(user.MagicLink_.TokenHash, l.TokenHash))
+	}
+	return nil
+}
+
+func deleteMagicLinksByUser(db *orm.DB, userID string) error {
+	qb := db.Query(&user.MagicLink{}).Where(user.MagicLink_.UserId).Eq(userID)
+	links, err := user.ReadAllMagicLink(qb)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if err := db.Delete(l, orm.Eq(user.MagicLink_.TokenHash, l.TokenHash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package authority
+
+import (
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// mfaWindow is how long, in seconds, a user's count of wrong codes lasts.
+const mfaWindow = 3600
+
+// RequiresMFA reports whether userID has any enabled second factor enrolled.
+func (m *Module) RequiresMFA(userID string) bool {
+	for _, a := range m.authenticators {
+		if f, ok := a.(user.SecondFactor); ok && f.Enrolled(userID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Module) codeFactors() []user.CodeFactor {
+	var fs []user.CodeFactor
+	for _, a := range m.authenticators {
+		if f, ok := a.(user.CodeFactor); ok {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+func (m *Module) PendingUser(ctx router.Context) (string, error) {
+	return m.ScopedUser(ctx, user.ScopeMFA)
+}
+
+// ScopedUser is the user whose session, restricted to scope, ctx carries;
+// ErrSessionExpired if it carries none. Authenticate never sets a scoped
+// session as ctx.UserID, so this is the only way a handler reaches one.
+func (m *Module) ScopedUser(ctx router.Context, scope string) (string, error) {
+	id, err := m.strategy.Identify(ctx)
+	if err != nil {
+		return "", user.ErrSessionExpired
+	}
+	s, userID := user.SplitScopedUserID(id)
+	if s != scope || userID == "" {
+		return "", user.ErrSessionExpired
+	}
+	return userID, nil
+}
+
+// CompleteMFA ends the partial session and issues the real one. A password
+// that must change still only buys a change_password session.
+func (m *Module) CompleteMFA(ctx router.Context, userID string, opts ...user.SessionOption) error {
+	if cs, ok := m.strategy.(user.CurrentSession); ok {
+		if id, ok := cs.SessionID(ctx); ok {
+			m.DeleteSession(id)
+		}
+	}
+	if m.MustChangePassword(userID) {
+		opts = append(opts, user.WithScope(user.OpChangePassword))
+	}
+	return m.strategy.Issue(ctx, userID, opts...)
+}
+
+type mfaData struct{ Code, Remember string }
+
+func (d *mfaData) IsNil() bool { return d == nil }
+func (d *mfaData) DecodeFields(r model.FieldReader) {
+	d.Code, _ = r.String("code")
+	d.Remember, _ = r.String("remember")
+}
+
+// mountMFA serves the second half of a two-phase login: the partial session
+// posts a code, any enrolled code factor may accept it. A wrong code counts
+// toward the same per-account lockout as a wrong password, and — lockout or
+// not — toward Config.MFAAttempts for the partial session (which then ends)
+// and Config.MFAUserAttempts for the user (who then gets 429 for the rest of
+// the hour), so logging in again never buys fresh guesses.
+func (m *Module) mountMFA(r router.Router) {
+	r.Post(user.PathMFAVerify, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, m.config.TrustProxy)
+		userID, err := m.PendingUser(ctx)
+		if err != nil {
+			user.RespondError(ctx, 401, user.CodeInvalidCredentials, err)
+			return
+		}
+		if !user.Allowed(m.config.RateLimiter, ip, userID) || mfaFailures(m.db, "user:"+userID) >= int64(m.config.MFAUserAttempts) {
+			m.notify(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, UserID: userID})
+			user.RespondError(ctx, 429, user.CodeRateLimited, user.ErrRateLimited)
+			return
+		}
+		data := &mfaData{}
+		if err := ctx.Decode(data); err != nil {
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, nil)
+			return
+		}
+		u, err := m.UserByID(userID)
+		if err != nil || u.Status != "active" {
+			user.RespondError(ctx, 401, user.CodeInvalidCredentials, user.ErrInvalidCredentials)
+			return
+		}
+		if m.Locked(u.Email) {
+			user.RespondError(ctx, 423, user.CodeAccountLocked, user.ErrAccountLocked)
+			return
+		}
+
+		passed := false
+		for _, f := range m.codeFactors() {
+			if f.Enrolled(userID) && f.Verify(userID, data.Code) == nil {
+				passed = true
+				break
+			}
+		}
+		var sid string
+		if cs, ok := m.strategy.(user.CurrentSession); ok {
+			sid, _ = cs.SessionID(ctx)
+		}
+		if !passed {
+			m.notify(user.SecurityEvent{Type: user.EventMFAFailed, IP: ip, UserID: userID})
+			m.RecordFailure(u.Email, ip)
+			m.countMFAFailure("user:" + userID)
+			// A stateless (JWT) partial session has no row to count on; the
+			// per-user cap still holds it.
+			if sid != "" && m.countMFAFailure("session:"+sid) >= int64(m.config.MFAAttempts) {
+				m.DeleteSession(sid)
+				deleteMFAAttempt(m.db, "session:"+sid)
+				user.RespondError(ctx, 401, user.CodeInvalidCredentials, user.ErrSessionExpired)
+				return
+			}
+			user.RespondError(ctx, 401, user.CodeInvalidCode, user.ErrInvalidCode)
+			return
+		}
+		m.RecordSuccess(u.Email)
+		deleteMFAAttempt(m.db, "user:"+userID)
+		if sid != "" {
+			deleteMFAAttempt(m.db, "session:"+sid)
+		}
+
+		if err := m.CompleteMFA(ctx, userID, user.WithRemember(user.Checked(data.Remember))); err != nil {
+			user.RespondError(ctx, 500, user.CodeServerError, nil)
+			return
+		}
+		user.RespondLogin(ctx, user.NextOr(ctx, user.PathAfterLogin), m, u)
+	}).Public()
+}
+
+// mfaFailures is subject's count of wrong codes inside the current window.
+func mfaFailures(db *orm.DB, subject string) int64 {
+	a, ok := getMFAAttempt(db, subject)
+	if !ok || time.Now()/1e9-a.WindowAt >= mfaWindow {
+		return 0
+	}
+	return a.Failures
+}
+
+// countMFAFailure adds one wrong code to subject and returns the new count.
+// Like VerifyLoginCode it writes conditioned on the claim it read and reads
+// the claim back: a writer that lost to a concurrent one, or lost the race to
+// create the row, retries against the winner's row. If it still can't record
+// the failure it reports every cap as reached rather than let a guess go
+// uncounted.
+func (m *Module) countMFAFailure(subject string) int64 {
+	for try := 0; try < 8; try++ {
+		claim, err := newToken()
+		if err != nil {
+			break
+		}
+		now := time.Now() / 1e9
+		a, exists := getMFAAttempt(m.db, subject)
+		if !exists {
+			if m.db.Create(&user.MFAAttempt{Subject: subject, Failures: 1, WindowAt: now, Claim: claim}) == nil {
+				return 1
+			}
+			continue
+		}
+		seen := a.Claim
+		if now-a.WindowAt >= mfaWindow {
+			a.Failures, a.WindowAt = 0, now
+		}
+		a.Failures++
+		a.Claim = claim
+		if m.db.Update(a, orm.Eq(user.MFAAttempt_.Subject, subject), orm.Eq(user.MFAAttempt_.Claim, seen)) != nil {
+			continue
+		}
+		if got, ok := getMFAAttempt(m.db, subject); ok && got.Claim == claim {
+			return a.Failures
+		}
+	}
+	return 1 << 62
+}
+
+func getMFAAttempt(db *orm.DB, subject string) (*user.MFAAttempt, bool) {
+	qb := db.Query(&user.MFAAttempt{}).Where(user.MFAAttempt_.Subject).Eq(subject)
+	results, err := user.ReadAllMFAAttempt(qb)
+	if err != nil || len(results) == 0 {
+		return nil, false
+	}
+	return results[0], true
+}
+
+func deleteMFAAttempt(db *orm.DB, subject string) {
+	if a, ok := getMFAAttempt(db, subject); ok {
+		db.Delete(a, orm.Eq(user.MFAAttempt_.Subject, subject))
+	}
+}
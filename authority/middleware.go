@@ -11,12 +11,16 @@ var _ model.Authorizer = (*Module)(nil).Can
 
 // Authenticate returns a router.Middleware that asks the active SessionStrategy
 // to identify the caller. If valid, sets UserId in the context via
-// ctx.SetUserID(id). If invalid, UserId remains empty (anonymous).
+// ctx.SetUserID(id). If invalid, UserId remains empty (anonymous) — as it does
+// for any scoped session (a partial MFA one, a forced change_password one):
+// only the handler that serves the scope looks it up (ScopedUser).
 func (m *Module) Authenticate() router.Middleware {
 	return func(next router.HandlerFunc) router.HandlerFunc {
 		return func(ctx router.Context) {
 			if userID, err := m.strategy.Identify(ctx); err == nil && userID != "" {
-				ctx.SetUserID(userID)
+				if scope, _ := user.SplitScopedUserID(userID); scope == "" {
+					ctx.SetUserID(userID)
+				}
 			}
 			next(ctx)
 		}
@@ -12,7 +12,10 @@ func initSchema(db *orm.DB) error {
 		&user.User{}, &user.Role{}, &user.Permission{},
 		&user.Identity{}, &user.LANIP{},
 		&user.OAuthState{}, &user.UserRole{}, &user.RolePermission{},
-		&user.Session{},
+		&user.Session{}, &user.PasswordReset{}, &user.EmailVerification{},
+		&user.LoginLock{}, &user.MFAAttempt{}, &user.PasswordHistory{}, &user.PasswordState{},
+		&user.MagicLink{}, &user.LoginCode{}, &user.TOTP{}, &user.RecoveryCode{},
+		&user.WebAuthnCredential{}, &user.WebAuthnChallenge{}, &user.ProviderToken{},
 	}
 	ddlCompiler, ok := db.RawConn().(ddl.Compiler)
 	if !ok {
@@ -32,12 +32,54 @@ func New(db *orm.DB, cfg user.Config) (*Module, error) {
 	if cfg.IDs == nil {
 		return nil, fmt.Err("user:", "Config.IDs", "is", "required")
 	}
+	if n := len(cfg.TokenKey); n != 0 && n != 32 {
+		return nil, fmt.Err("user:", "Config.TokenKey", "must", "be", "32", "bytes")
+	}
 	if cfg.CookieName == "" {
 		cfg.CookieName = "session"
 	}
 	if cfg.TokenTTL == 0 {
 		cfg.TokenTTL = 86400
 	}
+	if cfg.ResetTokenTTL == 0 {
+		cfg.ResetTokenTTL = 3600
+	}
+	if cfg.VerifyTokenTTL == 0 {
+		cfg.VerifyTokenTTL = 86400
+	}
+	if cfg.MagicLinkTTL == 0 {
+		cfg.MagicLinkTTL = 900
+	}
+	if cfg.LoginCodeTTL == 0 {
+		cfg.LoginCodeTTL = 300
+	}
+	if cfg.LoginCodeAttempts == 0 {
+		cfg.LoginCodeAttempts = 5
+	}
+	if cfg.LoginCodeSends == 0 {
+		cfg.LoginCodeSends = 5
+	}
+	if cfg.MFASessionTTL == 0 {
+		cfg.MFASessionTTL = 300
+	}
+	if cfg.MFAAttempts == 0 {
+		cfg.MFAAttempts = 5
+	}
+	if cfg.MFAUserAttempts == 0 {
+		cfg.MFAUserAttempts = 20
+	}
+	if cfg.ChallengeTTL == 0 {
+		cfg.ChallengeTTL = 300
+	}
+	if cfg.AnonymousChallenges == 0 {
+		cfg.AnonymousChallenges = 1000
+	}
+	if cfg.LockWindow == 0 {
+		cfg.LockWindow = 60
+	}
+	if cfg.LockMaxWindow == 0 {
+		cfg.LockMaxWindow = 3600
+	}
 
 	m := &Module{
 		db:     db,
@@ -47,7 +89,7 @@ func New(db *orm.DB, cfg user.Config) (*Module, error) {
 		ids:    cfg.IDs,
 		events: cfg.Events,
 	}
-	m.strategy = cookie.New(m, cfg.CookieName, cfg.TokenTTL, cfg.TrustProxy)
+	m.strategy = cookie.New(m, cfg.CookieName, cfg.TokenTTL, cfg.TrustProxy).WithRememberTTL(cfg.RememberTTL)
 
 	if err := initSchema(db); err != nil {
 		return nil, err
@@ -88,17 +130,28 @@ func (m *Module) notify(e user.SecurityEvent) {
 // SuspendUser sets Status = "suspended". Evicts user from cache.
 func (m *Module) SuspendUser(id string) error { return suspendUser(m.db, m.ucache, id) }
 
+// DeleteUser removes the user row. Evicts user from cache.
+func (m *Module) DeleteUser(id string) error { return deleteUser(m.db, m.ucache, id) }
+
 // ReactivateUser sets Status = "active". Evicts user from cache.
 func (m *Module) ReactivateUser(id string) error { return reactivateUser(m.db, m.ucache, id) }
 
 // PurgeSessionsByUser deletes all sessions belonging to userID from cache and DB.
 func (m *Module) PurgeSessionsByUser(userID string) error {
+	return m.purgeSessions(userID, "")
+}
+
+// purgeSessions deletes userID's sessions except the one with id keep ("" = none).
+func (m *Module) purgeSessions(userID, keep string) error {
 	qb := m.db.Query(&user.Session{}).Where(user.Session_.UserId).Eq(userID)
 	sessions, err := user.ReadAllSession(qb)
 	if err != nil {
 		return err
 	}
 	for _, s := range sessions {
+		if s.Id == keep {
+			continue
+		}
 		m.db.Delete(s, orm.Eq(user.Session_.Id, s.Id))
 		m.cache.delete(s.Id)
 	}
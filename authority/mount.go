@@ -11,16 +11,19 @@ func (m *Module) ModelName() string { return "user" }
 
 // MountAPI mounts the one session-termination endpoint centrally — logout ends
 // a session the same way no matter which mode started it (strategy.Revoke) —
-// then lets every enabled Authenticator mount its own login route. authority
-// never inspects what a mode mounts.
+// then lets every enabled Authenticator mount its own login route, and adds
+// POST /mfa/verify once any code factor is enabled. authority never inspects
+// what a mode mounts.
 func (m *Module) MountAPI(r router.Router) {
 	r.Post(user.PathLogout, func(ctx router.Context) {
 		m.strategy.Revoke(ctx)
-		ctx.SetHeader("Location", user.PathLogin)
-		ctx.WriteStatus(302)
+		user.RespondRedirect(ctx, user.PathLogin)
 	}).Public()
 
 	for _, auth := range m.authenticators {
 		auth.Mount(r)
 	}
+	if len(m.codeFactors()) > 0 {
+		m.mountMFA(r)
+	}
 }
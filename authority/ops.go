@@ -8,33 +8,70 @@ import (
 
 var _ router.OpModule = (*Module)(nil)
 
+// MountOps registers the ops. me and change_password are Public because a
+// change_password session has no ctx.UserID for the router to check: they
+// identify the caller themselves (caller).
 func (m *Module) MountOps(reg router.OpRegistry) {
-	reg.Op(user.OpMe, m.opMe).Authenticated()
+	reg.Op(user.OpMe, m.opMe).Public()
 	reg.Op(user.OpListUsers, m.opListUsers).Requires("users", model.Read)
 	reg.Op(user.OpUpsertUser, m.opUpsertUser).Requires("users", model.Create|model.Update).Accepts(&user.User{})
 	reg.Op(user.OpDeleteUser, m.opDeleteUser).Requires("users", model.Delete).Accepts(&user.User{})
+	reg.Op(user.OpResetMFA, m.opResetMFA).Requires("users", model.Update).Accepts(&user.User{})
+	reg.Op(user.OpChangePassword, m.opChangePassword).Public().Accepts(&user.PasswordData{})
+	reg.Op(user.OpSetTemporaryPassword, m.opSetTemporaryPassword).Requires("users", model.Update).Accepts(&user.TemporaryPasswordData{})
+	reg.Op(user.OpListIdentities, m.opListIdentities).Authenticated()
+	reg.Op(user.OpUnlinkIdentity, m.opUnlinkIdentity).Authenticated().Accepts(&user.Identity{})
 }
 
+// caller is ctx's signed-in user or, failing that, the user of a session
+// scoped to OpChangePassword, which Authenticate leaves out of ctx.UserID;
+// restricted reports the latter. A scoped id in ctx.UserID is refused.
+func (m *Module) caller(ctx router.Context) (userID string, restricted bool) {
+	if id := ctx.UserID(); id != "" {
+		if scope, userID := user.SplitScopedUserID(id); scope == "" {
+			return userID, false
+		}
+		return "", false
+	}
+	if userID, err := m.ScopedUser(ctx, user.OpChangePassword); err == nil {
+		return userID, true
+	}
+	return "", false
+}
+
+// opMe also answers a restricted session: the shell needs MustChangePassword
+// to know where to send the user.
 func (m *Module) opMe(ctx router.Context) {
-	userID := ctx.UserID()
+	userID, _ := m.caller(ctx)
 	if userID == "" {
 		ctx.WriteStatus(401)
 		return
 	}
-	u, err := m.GetUser(userID)
+	profile, err := m.Profile(userID)
 	if err != nil {
 		ctx.WriteStatus(404)
 		return
 	}
+	if err := ctx.Encode(&profile); err != nil {
+		ctx.WriteStatus(500)
+	}
+}
+
+// Profile builds userID's ProfileDTO — the me op's answer, and the profile a
+// JSON login response carries (user.ProfileSource).
+func (m *Module) Profile(userID string) (user.ProfileDTO, error) {
+	u, err := m.GetUser(userID)
+	if err != nil {
+		return user.ProfileDTO{}, err
+	}
 	profile := user.ProfileDTO{Id: u.Id, Name: u.Name, Email: u.Email, Avatar: u.Avatar}
 	for _, r := range u.Roles {
 		profile.Roles = append(profile.Roles, r.Code)
 		profile.RoleNames = append(profile.RoleNames, r.Name)
 	}
 	profile.Permissions = permissionsOf(u)
-	if err := ctx.Encode(&profile); err != nil {
-		ctx.WriteStatus(500)
-	}
+	profile.MustChangePassword = m.MustChangePassword(u.Id)
+	return profile, nil
 }
 
 func (m *Module) opListUsers(ctx router.Context) {
@@ -80,6 +117,153 @@ func (m *Module) opDeleteUser(ctx router.Context) {
 	}
 }
 
+func (m *Module) opResetMFA(ctx router.Context) {
+	var u user.User
+	if err := ctx.Decode(&u); err != nil {
+		ctx.WriteStatus(400)
+		return
+	}
+	if err := m.ResetSecondFactor(u.Id); err != nil {
+		ctx.WriteStatus(500)
+	}
+}
+
+// opChangePassword is the one op a session scoped to OpChangePassword
+// reaches. Once the password changes, that session is swapped for a full one.
+func (m *Module) opChangePassword(ctx router.Context) {
+	userID, restricted := m.caller(ctx)
+	if userID == "" {
+		ctx.WriteStatus(401)
+		return
+	}
+	var data user.PasswordData
+	if err := ctx.Decode(&data); err != nil {
+		ctx.WriteStatus(400)
+		return
+	}
+	if err := m.VerifyPassword(userID, data.Current); err != nil {
+		ctx.WriteStatus(403)
+		ctx.Write([]byte(err.Error()))
+		return
+	}
+	if data.New != data.Confirm {
+		ctx.WriteStatus(400)
+		ctx.Write([]byte(user.ErrPasswordMismatch.Error()))
+		return
+	}
+	// A forced change can't be held back by the minimum age.
+	if !m.MustChangePassword(userID) {
+		if err := m.checkMinAge(userID); err != nil {
+			ctx.WriteStatus(400)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+	}
+	if err := m.SetPassword(userID, data.New); err != nil {
+		user.WritePasswordError(ctx, err)
+		return
+	}
+	var current string
+	if cs, ok := m.strategy.(user.CurrentSession); ok {
+		current, _ = cs.SessionID(ctx)
+	}
+	if m.config.RevokeOnPasswordChange {
+		if err := m.purgeSessions(userID, current); err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+	}
+	if restricted {
+		// The full session keeps the remember-me the login asked for.
+		var opts []user.SessionOption
+		if current != "" {
+			if sess, err := m.GetSession(current); err == nil {
+				opts = append(opts, user.WithRemember(m.remembered(sess)))
+			}
+			m.DeleteSession(current)
+		}
+		if err := m.strategy.Issue(ctx, userID, opts...); err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+	}
+	m.notify(user.SecurityEvent{Type: user.EventPasswordChanged, IP: user.ClientIP(ctx, m.config.TrustProxy), UserID: userID})
+	ctx.WriteStatus(204)
+}
+
+// opSetTemporaryPassword is how an admin hands out a password: it goes
+// through the same policy as any other, and the user's next login only
+// reaches change_password. With RevokeOnPasswordChange their sessions end.
+func (m *Module) opSetTemporaryPassword(ctx router.Context) {
+	var data user.TemporaryPasswordData
+	if err := ctx.Decode(&data); err != nil {
+		ctx.WriteStatus(400)
+		return
+	}
+	if _, err := m.GetUser(data.UserId); err != nil {
+		ctx.WriteStatus(404)
+		return
+	}
+	if err := m.SetTemporaryPassword(data.UserId, data.Password); err != nil {
+		user.WritePasswordError(ctx, err)
+		return
+	}
+	if m.config.RevokeOnPasswordChange {
+		if err := m.purgeSessions(data.UserId, ""); err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+	}
+	ctx.WriteStatus(204)
+}
+
+// opListIdentities answers the caller's identities without ProviderId, which
+// for email_password is the password hash.
+func (m *Module) opListIdentities(ctx router.Context) {
+	scope, userID := user.SplitScopedUserID(ctx.UserID())
+	if userID == "" || scope != "" {
+		ctx.WriteStatus(401)
+		return
+	}
+	identities, err := m.GetUserIdentities(userID)
+	if err != nil {
+		ctx.WriteStatus(500)
+		return
+	}
+	list := make(user.IdentityList, 0, len(identities))
+	for i := range identities {
+		identities[i].ProviderId = ""
+		list = append(list, &identities[i])
+	}
+	if err := ctx.Encode(&list); err != nil {
+		ctx.WriteStatus(500)
+	}
+}
+
+func (m *Module) opUnlinkIdentity(ctx router.Context) {
+	scope, userID := user.SplitScopedUserID(ctx.UserID())
+	if userID == "" || scope != "" {
+		ctx.WriteStatus(401)
+		return
+	}
+	var i user.Identity
+	if err := ctx.Decode(&i); err != nil {
+		ctx.WriteStatus(400)
+		return
+	}
+	switch err := m.RemoveIdentity(userID, i.Id); err {
+	case nil:
+		ctx.WriteStatus(204)
+	case user.ErrNotFound:
+		ctx.WriteStatus(404)
+	case user.ErrCannotUnlink:
+		ctx.WriteStatus(409)
+		ctx.Write([]byte(err.Error()))
+	default:
+		ctx.WriteStatus(500)
+	}
+}
+
 func permissionsOf(u user.User) []string {
 	var perms []string
 	for _, p := range u.Permissions {
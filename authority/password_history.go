@@ -0,0 +1,101 @@
+package authority
+
+import (
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	emailpassword "github.com/tinywasm/user/email_password"
+
+	"github.com/tinywasm/user"
+)
+
+// historyOn reports whether SetPassword must keep password_history rows:
+// either for the reuse check or for PasswordMinAge's last-change time.
+func (m *Module) historyOn() bool {
+	return m.config.PasswordHistory > 0 || m.config.PasswordMinAge > 0
+}
+
+// checkReuse rejects password if it matches the current credential or any of
+// the last PasswordHistory hashes.
+func (m *Module) checkReuse(userID, password string) error {
+	depth := m.config.PasswordHistory
+	if depth <= 0 {
+		return nil
+	}
+	if identity, err := m.IdentityFor(userID, "email_password"); err == nil {
+		if emailpassword.VerifyPassword(identity.ProviderId, password) == nil {
+			return user.ErrPasswordReused
+		}
+	}
+	rows, err := passwordHistory(m.db, userID)
+	if err != nil {
+		return err
+	}
+	if len(rows) > depth {
+		rows = rows[len(rows)-depth:]
+	}
+	for _, h := range rows {
+		if emailpassword.VerifyPassword(h.Hash, password) == nil {
+			return user.ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// recordPassword appends hash to userID's history and prunes it down to
+// PasswordHistory rows (at least one, which PasswordMinAge reads).
+func (m *Module) recordPassword(userID, hash string) error {
+	rows, err := passwordHistory(m.db, userID)
+	if err != nil {
+		return err
+	}
+	var seq int64 = 1
+	if len(rows) > 0 {
+		seq = rows[len(rows)-1].Seq + 1
+	}
+	h := &user.PasswordHistory{
+		Id:        m.ids.NewID(),
+		UserId:    userID,
+		Seq:       seq,
+		Hash:      hash,
+		CreatedAt: time.Now() / 1e9,
+	}
+	if err := m.db.Create(h); err != nil {
+		return err
+	}
+
+	keep := m.config.PasswordHistory
+	if keep < 1 {
+		keep = 1
+	}
+	rows = append(rows, h)
+	for len(rows) > keep {
+		if err := m.db.Delete(rows[0], orm.Eq(user.PasswordHistory_.Id, rows[0].Id)); err != nil {
+			return err
+		}
+		rows = rows[1:]
+	}
+	return nil
+}
+
+// checkMinAge rejects a self-service change made sooner than PasswordMinAge
+// after the last one. Users with no recorded change are never held back.
+func (m *Module) checkMinAge(userID string) error {
+	if m.config.PasswordMinAge <= 0 {
+		return nil
+	}
+	rows, err := passwordHistory(m.db, userID)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	last := rows[len(rows)-1].CreatedAt
+	if time.Now()/1e9-last < int64(m.config.PasswordMinAge) {
+		return user.ErrPasswordTooRecent
+	}
+	return nil
+}
+
+// passwordHistory returns userID's rows oldest first.
+func passwordHistory(db *orm.DB, userID string) (user.PasswordHistoryList, error) {
+	qb := db.Query(&user.PasswordHistory{}).Where(user.PasswordHistory_.UserId).Eq(userID).OrderBy(user.PasswordHistory_.Seq).Asc()
+	return user.ReadAllPasswordHistory(qb)
+}
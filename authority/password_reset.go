@@ -0,0 +1,111 @@
+package authority
+
+import (
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// CreateResetToken mints a reset token for userID, replacing any earlier one:
+// only the most recent link a user asked for works.
+func (m *Module) CreateResetToken(userID string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := deleteResetsByUser(m.db, userID); err != nil {
+		return "", err
+	}
+	now := time.Now() / 1e9
+	r := &user.PasswordReset{
+		TokenHash: hashToken(token),
+		UserId:    userID,
+		ExpiresAt: now + int64(m.config.ResetTokenTTL),
+		CreatedAt: now,
+	}
+	if err := m.db.Create(r); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetPassword runs every check SetPassword would — policy and reuse —
+// BEFORE consuming the token, so a rejected password doesn't burn the link.
+//
+// The token is spent like a login code's turn: an UPDATE conditioned on the
+// row being unclaimed tags it with a fresh claim, and reading the claim back
+// tells concurrent resets with the same link that they lost.
+func (m *Module) ResetPassword(token, password string) (string, error) {
+	r, err := findReset(m.db, token)
+	if err != nil {
+		return "", err
+	}
+	if err := m.checkNewPassword(r.UserId, password); err != nil {
+		return "", err
+	}
+	claim, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	r.Claim = claim
+	if err := m.db.Update(r, orm.Eq(user.PasswordReset_.TokenHash, r.TokenHash), orm.Eq(user.PasswordReset_.Claim, "")); err != nil {
+		return "", err
+	}
+	if got, err := findReset(m.db, token); err != nil || got.Claim != claim {
+		return "", user.ErrInvalidToken
+	}
+	if err := m.db.Delete(r, orm.Eq(user.PasswordReset_.TokenHash, r.TokenHash)); err != nil {
+		return "", err
+	}
+	if err := m.storePassword(r.UserId, password); err != nil {
+		return "", err
+	}
+	if err := m.PurgeSessionsByUser(r.UserId); err != nil {
+		return "", err
+	}
+	return r.UserId, nil
+}
+
+// PurgeExpiredResetTokens is maintenance, not part of any port — call it
+// periodically alongside PurgeExpiredOAuthStates.
+func (m *Module) PurgeExpiredResetTokens() error {
+	qb := m.db.Query(&user.PasswordReset{}).Where(user.PasswordReset_.ExpiresAt).Lt(time.Now() / 1e9)
+	resets, _ := user.ReadAllPasswordReset(qb)
+	for _, r := range resets {
+		m.db.Delete(r, orm.Eq(user.PasswordReset_.TokenHash, r.TokenHash))
+	}
+	return nil
+}
+
+// findReset resolves token to its live reset row. An expired row is deleted
+// on sight.
+func findReset(db *orm.DB, token string) (*user.PasswordReset, error) {
+	qb := db.Query(&user.PasswordReset{}).Where(user.PasswordReset_.TokenHash).Eq(hashToken(token))
+	results, err := user.ReadAllPasswordReset(qb)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, user.ErrInvalidToken
+	}
+	r := results[0]
+	if r.ExpiresAt < time.Now()/1e9 {
+		db.Delete(r, orm.Eq(user.PasswordReset_.TokenHash, r.TokenHash))
+		return nil, user.ErrInvalidToken
+	}
+	return r, nil
+}
+
+func deleteResetsByUser(db *orm.DB, userID string) error {
+	qb := db.Query(&user.PasswordReset{}).Where(user.PasswordReset_.UserId).Eq(userID)
+	resets, err := user.ReadAllPasswordReset(qb)
+	if err != nil {
+		return err
+	}
+	for _, r := range resets {
+		if err := db.Delete(r, orm.Eq(user.PasswordReset_.TokenHash, r.TokenHash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
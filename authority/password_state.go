@@ -0,0 +1,70 @@
+package authority
+
+import (
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// SetTemporaryPassword is SetPassword for a password someone other than the
+// user chose (an admin, Seed.MustChangePassword): the next email_password
+// login only gets a session scoped to change_password.
+func (m *Module) SetTemporaryPassword(userID, password string) error {
+	if err := m.SetPassword(userID, password); err != nil {
+		return err
+	}
+	return m.savePasswordState(userID, true)
+}
+
+// MustChangePassword reports whether userID's password was admin-set or has
+// outlived Config.PasswordMaxAge. A user without an email_password identity
+// has no password to change, and one with no recorded change isn't flagged
+// until StartPasswordClock runs.
+func (m *Module) MustChangePassword(userID string) bool {
+	if _, err := m.IdentityFor(userID, "email_password"); err != nil {
+		return false
+	}
+	st, err := getPasswordState(m.db, userID)
+	if err != nil {
+		return false
+	}
+	if st.MustChange != 0 {
+		return true
+	}
+	return m.config.PasswordMaxAge > 0 && time.Now()/1e9-st.ChangedAt >= int64(m.config.PasswordMaxAge)
+}
+
+// StartPasswordClock gives a password set before PasswordMaxAge was configured
+// its first change time: now, at the login that just proved it.
+func (m *Module) StartPasswordClock(userID string) {
+	if m.config.PasswordMaxAge <= 0 {
+		return
+	}
+	if _, err := getPasswordState(m.db, userID); err == user.ErrNotFound {
+		m.savePasswordState(userID, false)
+	}
+}
+
+// savePasswordState records a password change made now.
+func (m *Module) savePasswordState(userID string, mustChange bool) error {
+	st := &user.PasswordState{UserId: userID, ChangedAt: time.Now() / 1e9}
+	if mustChange {
+		st.MustChange = 1
+	}
+	if _, err := getPasswordState(m.db, userID); err == nil {
+		return m.db.Update(st, orm.Eq(user.PasswordState_.UserId, userID))
+	}
+	return m.db.Create(st)
+}
+
+func getPasswordState(db *orm.DB, userID string) (*user.PasswordState, error) {
+	qb := db.Query(&user.PasswordState{}).Where(user.PasswordState_.UserId).Eq(userID)
+	results, err := user.ReadAllPasswordState(qb)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, user.ErrNotFound
+	}
+	return results[0], nil
+}
@@ -8,12 +8,26 @@ import (
 )
 
 var (
-	_ user.IdentityStore    = (*Module)(nil)
-	_ user.StateStore       = (*Module)(nil)
-	_ user.TrustedIPStore   = (*Module)(nil)
-	_ user.SessionRepo      = (*Module)(nil)
-	_ user.SecurityNotifier = (*Module)(nil)
-	_ user.SessionIssuer    = (*Module)(nil)
+	_ user.IdentityStore      = (*Module)(nil)
+	_ user.StateStore         = (*Module)(nil)
+	_ user.TrustedIPStore     = (*Module)(nil)
+	_ user.SessionRepo        = (*Module)(nil)
+	_ user.SecurityNotifier   = (*Module)(nil)
+	_ user.SessionIssuer      = (*Module)(nil)
+	_ user.PasswordStore      = (*Module)(nil)
+	_ user.PasswordResetStore = (*Module)(nil)
+	_ user.EmailVerifier      = (*Module)(nil)
+	_ user.LoginGuard         = (*Module)(nil)
+	_ user.PasswordExpiry     = (*Module)(nil)
+	_ user.ProfileSource      = (*Module)(nil)
+	_ user.MagicLinkStore     = (*Module)(nil)
+	_ user.LoginCodeStore     = (*Module)(nil)
+	_ user.TOTPStore          = (*Module)(nil)
+	_ user.PendingMFA         = (*Module)(nil)
+	_ user.ChallengeStore     = (*Module)(nil)
+	_ user.CredentialStore    = (*Module)(nil)
+	_ user.RoleAssigner       = (*Module)(nil)
+	_ user.ProviderTokenStore = (*Module)(nil)
 )
 
 func (m *Module) UserByID(id string) (user.User, error) { return getUser(m.db, m.ucache, id) }
@@ -36,16 +50,17 @@ func (m *Module) UpdateUserAvatar(userID, avatar string) error {
 	return updateUserAvatar(m.db, m.ucache, userID, avatar)
 }
 
-func (m *Module) CreateState(provider string) (string, error) {
-	state := m.ids.NewID()
+func (m *Module) CreateState(s user.OAuthState) (string, error) {
 	now := time.Now() / 1e9
-	s := &user.OAuthState{State: state, Provider: provider, ExpiresAt: now + 600, CreatedAt: now}
-	if err := m.db.Create(s); err != nil {
+	s.State = m.ids.NewID()
+	s.ExpiresAt = now + 600
+	s.CreatedAt = now
+	if err := m.db.Create(&s); err != nil {
 		return "", err
 	}
-	return state, nil
+	return s.State, nil
 }
-func (m *Module) ConsumeState(state, provider string) error {
+func (m *Module) ConsumeState(state, provider string) (user.OAuthState, error) {
 	return consumeState(m.db, state, provider)
 }
 
@@ -64,28 +79,37 @@ func (m *Module) IsTrustedIP(userID, ip string) bool { return checkLANIP(m.db, u
 
 func (m *Module) Notify(e user.SecurityEvent) { m.notify(e) }
 
-func (m *Module) IssueSession(ctx router.Context, userID string) error {
-	return m.strategy.Issue(ctx, userID)
+// IssueSession holds the session back from a user with a second factor
+// enrolled: they get a partial ScopeMFA session and ErrMFARequired instead,
+// whatever opts asked for — CompleteMFA settles those.
+func (m *Module) IssueSession(ctx router.Context, userID string, opts ...user.SessionOption) error {
+	if m.RequiresMFA(userID) {
+		if err := m.strategy.Issue(ctx, userID, user.WithScope(user.ScopeMFA), user.WithTTL(m.config.MFASessionTTL)); err != nil {
+			return err
+		}
+		return user.ErrMFARequired
+	}
+	return m.strategy.Issue(ctx, userID, opts...)
 }
 
-func consumeState(db *orm.DB, state, provider string) error {
+func consumeState(db *orm.DB, state, provider string) (user.OAuthState, error) {
 	qb := db.Query(&user.OAuthState{}).Where(user.OAuthState_.State).Eq(state)
 	results, err := user.ReadAllOAuthState(qb)
 	if err != nil {
-		return err
+		return user.OAuthState{}, err
 	}
 	if len(results) == 0 {
-		return user.ErrInvalidOAuthState
+		return user.OAuthState{}, user.ErrInvalidOAuthState
 	}
 	stateObj := results[0]
 	if stateObj.Provider != provider {
-		return user.ErrInvalidOAuthState
+		return user.OAuthState{}, user.ErrInvalidOAuthState
 	}
 	if err := db.Delete(stateObj, orm.Eq(user.OAuthState_.State, stateObj.State)); err != nil {
-		return err
+		return user.OAuthState{}, err
 	}
 	if stateObj.ExpiresAt < time.Now()/1e9 {
-		return user.ErrInvalidOAuthState
+		return user.OAuthState{}, user.ErrInvalidOAuthState
 	}
-	return nil
+	return *stateObj, nil
 }
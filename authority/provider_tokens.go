@@ -0,0 +1,137 @@
+package authority
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+var errNoTokenKey = fmt.Err("user:", "Config.TokenKey", "is", "required")
+
+// SaveProviderToken seals t for userID's provider identity, replacing what
+// was stored. A token without RefreshToken keeps the stored one: Google and
+// others only send it on the first consent, and not with every refresh.
+func (m *Module) SaveProviderToken(userID, provider string, t user.OAuthToken) error {
+	if m.config.TokenKey == nil {
+		return errNoTokenKey
+	}
+	identity, err := getIdentityByUserAndProvider(m.db, userID, provider)
+	if err != nil {
+		return err
+	}
+	now := time.Now() / 1e9
+	row := &user.ProviderToken{IdentityId: identity.Id, UserId: userID, Provider: provider, Expiry: t.Expiry, UpdatedAt: now}
+	if row.Expiry == 0 && t.ExpiresIn > 0 {
+		row.Expiry = now + int64(t.ExpiresIn)
+	}
+	if row.AccessToken, err = m.seal(identity.Id, "access_token", t.AccessToken); err != nil {
+		return err
+	}
+	old, _ := getProviderToken(m.db, identity.Id)
+	if t.RefreshToken == "" && old != nil {
+		row.RefreshToken = old.RefreshToken
+	} else if row.RefreshToken, err = m.seal(identity.Id, "refresh_token", t.RefreshToken); err != nil {
+		return err
+	}
+	if old != nil {
+		if err := m.db.Delete(old, orm.Eq(user.ProviderToken_.IdentityId, old.IdentityId)); err != nil {
+			return err
+		}
+	}
+	return m.db.Create(row)
+}
+
+// ProviderToken opens what SaveProviderToken stored; ErrNotFound if nothing
+// was.
+func (m *Module) ProviderToken(userID, provider string) (user.OAuthToken, error) {
+	if m.config.TokenKey == nil {
+		return user.OAuthToken{}, errNoTokenKey
+	}
+	identity, err := getIdentityByUserAndProvider(m.db, userID, provider)
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	row, err := getProviderToken(m.db, identity.Id)
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	access, err := m.open(identity.Id, "access_token", row.AccessToken)
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	refresh, err := m.open(identity.Id, "refresh_token", row.RefreshToken)
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	return user.OAuthToken{AccessToken: access, RefreshToken: refresh, Expiry: row.Expiry}, nil
+}
+
+func getProviderToken(db *orm.DB, identityID string) (*user.ProviderToken, error) {
+	qb := db.Query(&user.ProviderToken{}).Where(user.ProviderToken_.IdentityId).Eq(identityID)
+	results, err := user.ReadAllProviderToken(qb)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, user.ErrNotFound
+	}
+	return results[0], nil
+}
+
+func deleteProviderToken(db *orm.DB, identityID string) {
+	if row, err := getProviderToken(db, identityID); err == nil {
+		db.Delete(row, orm.Eq(user.ProviderToken_.IdentityId, row.IdentityId))
+	}
+}
+
+// seal encrypts plain with AES-256-GCM under Config.TokenKey. The identity
+// and column are authenticated too, so a ciphertext copied into another row
+// or column fails to open.
+func (m *Module) seal(identityID, column, plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	gcm, err := m.tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), []byte(identityID+"/"+column))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (m *Module) open(identityID, column, sealed string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+	gcm, err := m.tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(b) < gcm.NonceSize() {
+		return "", user.ErrInvalidToken
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], []byte(identityID+"/"+column))
+	if err != nil {
+		return "", user.ErrInvalidToken
+	}
+	return string(plain), nil
+}
+
+func (m *Module) tokenCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(m.config.TokenKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
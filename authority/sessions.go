@@ -76,7 +76,8 @@ func (c *sessionCache) delete(id string) {
 }
 
 // RotateSession atomically deletes the old session and creates a new one
-// with the same userID, updated IP/UserAgent, and a fresh TTL.
+// with the same userID, scope and remember-me, updated IP/UserAgent, and a
+// fresh TTL. A partial session stays short.
 // Prevents session fixation attacks when called post-login.
 func (m *Module) RotateSession(oldID, ip, userAgent string) (user.Session, error) {
 	oldSess, err := m.GetSession(oldID)
@@ -89,14 +90,31 @@ func (m *Module) RotateSession(oldID, ip, userAgent string) (user.Session, error
 		return user.Session{}, err
 	}
 
-	return m.CreateSession(oldSess.UserId, ip, userAgent)
+	opts := []user.SessionOption{user.WithScope(oldSess.Scope), user.WithRemember(m.remembered(oldSess))}
+	if oldSess.Scope == user.ScopeMFA {
+		opts = append(opts, user.WithTTL(m.config.MFASessionTTL))
+	}
+	return m.CreateSession(oldSess.UserId, ip, userAgent, opts...)
+}
+
+// remembered reports whether sess was issued WithRemember: only those outlive
+// TokenTTL.
+func (m *Module) remembered(sess user.Session) bool {
+	return sess.ExpiresAt-sess.CreatedAt > int64(m.config.TokenTTL)
 }
 
-func (m *Module) CreateSession(userID, ip, userAgent string) (user.Session, error) {
+func (m *Module) CreateSession(userID, ip, userAgent string, opts ...user.SessionOption) (user.Session, error) {
+	o := user.ApplySessionOptions(opts)
 	ttl := m.config.TokenTTL
 	if ttl == 0 {
 		ttl = 86400
 	}
+	if o.Remember && m.config.RememberTTL > 0 {
+		ttl = m.config.RememberTTL
+	}
+	if o.TTL > 0 {
+		ttl = o.TTL
+	}
 
 	now := time.Now() / 1e9
 	sess := user.Session{
@@ -106,6 +124,7 @@ func (m *Module) CreateSession(userID, ip, userAgent string) (user.Session, erro
 		Ip:        ip,
 		UserAgent: userAgent,
 		CreatedAt: now,
+		Scope:     o.Scope,
 	}
 
 	if err := m.db.Create(&sess); err != nil {
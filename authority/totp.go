@@ -0,0 +1,183 @@
+package authority
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// recoveryCodes is how many recovery codes ConfirmTOTP hands out.
+const recoveryCodes = 10
+
+// recoveryAlphabet leaves out look-alikes (0/o, 1/l) since the codes get
+// written down on paper.
+const recoveryAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+func (m *Module) TOTPSecret(userID string) (string, bool, error) {
+	t, err := getTOTP(m.db, userID)
+	if err != nil {
+		return "", false, err
+	}
+	return t.Secret, t.Confirmed == 1, nil
+}
+
+func (m *Module) SaveTOTPSecret(userID, secret string) error {
+	if err := deleteTOTP(m.db, userID); err != nil {
+		return err
+	}
+	return m.db.Create(&user.TOTP{UserId: userID, Secret: secret, CreatedAt: time.Now() / 1e9})
+}
+
+// ConfirmTOTP activates userID's enrollment and records step as used, so the
+// code that confirmed it can't log in too. Any earlier recovery codes are
+// replaced.
+func (m *Module) ConfirmTOTP(userID string, step int64) ([]string, error) {
+	t, err := getTOTP(m.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	t.Confirmed = 1
+	t.LastStep = step
+	if err := m.db.Update(t, orm.Eq(user.TOTP_.UserId, userID)); err != nil {
+		return nil, err
+	}
+	return m.newRecoveryCodes(userID)
+}
+
+// UseTOTPStep moves the replay guard forward to step. The UPDATE only lands
+// while last_step is still the value checked against step, and the claim read
+// back tells which of two logins racing with the same code moved it.
+func (m *Module) UseTOTPStep(userID string, step int64) error {
+	t, err := getTOTP(m.db, userID)
+	if err != nil {
+		return err
+	}
+	if t.Confirmed != 1 || step <= t.LastStep {
+		return user.ErrInvalidCode
+	}
+	claim, err := newToken()
+	if err != nil {
+		return err
+	}
+	seen := t.LastStep
+	t.LastStep = step
+	t.Claim = claim
+	if err := m.db.Update(t, orm.Eq(user.TOTP_.UserId, userID), orm.Eq(user.TOTP_.LastStep, seen)); err != nil {
+		return err
+	}
+	if got, err := getTOTP(m.db, userID); err != nil || got.Claim != claim {
+		return user.ErrInvalidCode
+	}
+	return nil
+}
+
+func (m *Module) UseRecoveryCode(userID, code string) error {
+	qb := m.db.Query(&user.RecoveryCode{}).Where(user.RecoveryCode_.CodeHash).Eq(hashToken(normalizeRecoveryCode(code)))
+	results, err := user.ReadAllRecoveryCode(qb)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 || results[0].UserId != userID {
+		return user.ErrInvalidCode
+	}
+	return m.db.Delete(results[0], orm.Eq(user.RecoveryCode_.CodeHash, results[0].CodeHash))
+}
+
+func (m *Module) DisableTOTP(userID string) error {
+	if err := deleteTOTP(m.db, userID); err != nil {
+		return err
+	}
+	return deleteRecoveryCodesByUser(m.db, userID)
+}
+
+// ResetSecondFactor is the admin side of DisableTOTP: the user's next login
+// needs no second factor, and they can enroll again.
+func (m *Module) ResetSecondFactor(userID string) error {
+	if err := m.DisableTOTP(userID); err != nil {
+		return err
+	}
+	m.notify(user.SecurityEvent{Type: user.EventMFADisabled, UserID: userID})
+	return nil
+}
+
+func (m *Module) newRecoveryCodes(userID string) ([]string, error) {
+	if err := deleteRecoveryCodesByUser(m.db, userID); err != nil {
+		return nil, err
+	}
+	now := time.Now() / 1e9
+	codes := make([]string, 0, recoveryCodes)
+	for i := 0; i < recoveryCodes; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := m.db.Create(&user.RecoveryCode{CodeHash: hashToken(normalizeRecoveryCode(code)), UserId: userID, CreatedAt: now}); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns ten random characters as "xxxxx-xxxxx".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 0, 11)
+	n := big.NewInt(int64(len(recoveryAlphabet)))
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			b = append(b, '-')
+		}
+		d, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		b = append(b, recoveryAlphabet[d.Int64()])
+	}
+	return string(b), nil
+}
+
+// normalizeRecoveryCode accepts a code however the user typed it back.
+func normalizeRecoveryCode(code string) string {
+	return fmt.Convert(code).TrimSpace().ToLower().Replace("-", "").Replace(" ", "").String()
+}
+
+func getTOTP(db *orm.DB, userID string) (*user.TOTP, error) {
+	qb := db.Query(&user.TOTP{}).Where(user.TOTP_.UserId).Eq(userID)
+	results, err := user.ReadAllTOTP(qb)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, user.ErrNotFound
+	}
+	return results[0], nil
+}
+
+func deleteTOTP(db *orm.DB, userID string) error {
+	t, err := getTOTP(db, userID)
+	if err == user.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return db.Delete(t, orm.Eq(user.TOTP_.UserId, userID))
+}
+
+func deleteRecoveryCodesByUser(db *orm.DB, userID string) error {
+	qb := db.Query(&user.RecoveryCode{}).Where(user.RecoveryCode_.UserId).Eq(userID)
+	codes, err := user.ReadAllRecoveryCode(qb)
+	if err != nil {
+		return err
+	}
+	for _, c := range codes {
+		if err := db.Delete(c, orm.Eq(user.RecoveryCode_.CodeHash, c.CodeHash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
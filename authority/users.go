@@ -190,20 +190,14 @@ func updateUserAvatar(db *orm.DB, cache *userCache, id, avatar string) error {
 }
 
 func suspendUser(db *orm.DB, cache *userCache, id string) error {
-	if cache != nil {
-		cache.Delete(id)
-	}
-	qb := db.Query(&user.User{}).Where(user.User_.Id).Eq(id)
-	results, err := user.ReadAllUser(qb)
-	if err != nil || len(results) == 0 {
-		return user.ErrNotFound
-	}
-	u := results[0]
-	u.Status = "suspended"
-	return db.Update(u, orm.Eq(user.User_.Id, u.Id))
+	return setUserStatus(db, cache, id, "suspended")
 }
 
 func reactivateUser(db *orm.DB, cache *userCache, id string) error {
+	return setUserStatus(db, cache, id, "active")
+}
+
+func setUserStatus(db *orm.DB, cache *userCache, id, status string) error {
 	if cache != nil {
 		cache.Delete(id)
 	}
@@ -213,7 +207,7 @@ func reactivateUser(db *orm.DB, cache *userCache, id string) error {
 		return user.ErrNotFound
 	}
 	u := results[0]
-	u.Status = "active"
+	u.Status = status
 	return db.Update(u, orm.Eq(user.User_.Id, u.Id))
 }
 
@@ -0,0 +1,135 @@
+package authority
+
+import (
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// providerWebAuthn is the Identity.Provider every passkey is recorded under.
+const providerWebAuthn = "webauthn"
+
+// CreateChallenge mints a ceremony challenge. userID is empty when the
+// ceremony doesn't know its user yet (a passwordless login); anyone can ask
+// for those, so at most Config.AnonymousChallenges of them are outstanding at
+// once and further ones get ErrRateLimited until some expire.
+func (m *Module) CreateChallenge(userID, purpose string) (string, error) {
+	if userID == "" && !m.roomForAnonymousChallenge() {
+		return "", user.ErrRateLimited
+	}
+	challenge, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	c := &user.WebAuthnChallenge{
+		ChallengeHash: hashToken(challenge),
+		UserId:        userID,
+		Purpose:       purpose,
+		ExpiresAt:     time.Now()/1e9 + int64(m.config.ChallengeTTL),
+	}
+	if err := m.db.Create(c); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// ConsumeChallenge deletes the row before checking it, like ConsumeMagicLink:
+// a challenge answers one ceremony at most.
+func (m *Module) ConsumeChallenge(challenge, purpose string) (string, error) {
+	qb := m.db.Query(&user.WebAuthnChallenge{}).Where(user.WebAuthnChallenge_.ChallengeHash).Eq(hashToken(challenge))
+	results, err := user.ReadAllWebAuthnChallenge(qb)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "", user.ErrInvalidToken
+	}
+	c := results[0]
+	if err := m.db.Delete(c, orm.Eq(user.WebAuthnChallenge_.ChallengeHash, c.ChallengeHash)); err != nil {
+		return "", err
+	}
+	if c.Purpose != purpose || c.ExpiresAt < time.Now()/1e9 {
+		return "", user.ErrInvalidToken
+	}
+	return c.UserId, nil
+}
+
+// roomForAnonymousChallenge reports whether another user-less challenge fits
+// under the cap, clearing out expired ones once it is reached.
+func (m *Module) roomForAnonymousChallenge() bool {
+	qb := m.db.Query(&user.WebAuthnChallenge{}).Where(user.WebAuthnChallenge_.UserId).Eq("")
+	pending, err := user.ReadAllWebAuthnChallenge(qb)
+	if err != nil {
+		return false
+	}
+	if len(pending) < m.config.AnonymousChallenges {
+		return true
+	}
+	now, live := time.Now()/1e9, 0
+	for _, c := range pending {
+		if c.ExpiresAt < now {
+			m.db.Delete(c, orm.Eq(user.WebAuthnChallenge_.ChallengeHash, c.ChallengeHash))
+			continue
+		}
+		live++
+	}
+	return live < m.config.AnonymousChallenges
+}
+
+// PurgeExpiredChallenges is maintenance, not part of any port — abandoned
+// ceremonies leave their challenge behind.
+func (m *Module) PurgeExpiredChallenges() error {
+	qb := m.db.Query(&user.WebAuthnChallenge{}).Where(user.WebAuthnChallenge_.ExpiresAt).Lt(time.Now() / 1e9)
+	challenges, _ := user.ReadAllWebAuthnChallenge(qb)
+	for _, c := range challenges {
+		m.db.Delete(c, orm.Eq(user.WebAuthnChallenge_.ChallengeHash, c.ChallengeHash))
+	}
+	return nil
+}
+
+// SaveCredential stores the key and records the credential as a "webauthn"
+// identity of its user.
+func (m *Module) SaveCredential(c user.WebAuthnCredential) error {
+	if _, err := m.CredentialByID(c.Id); err == nil {
+		return user.ErrInvalidAttestation
+	}
+	if err := createIdentity(m.db, m.ids, c.UserId, providerWebAuthn, c.Id, ""); err != nil {
+		return err
+	}
+	c.CreatedAt = time.Now() / 1e9
+	return m.db.Create(&c)
+}
+
+func (m *Module) CredentialByID(id string) (user.WebAuthnCredential, error) {
+	qb := m.db.Query(&user.WebAuthnCredential{}).Where(user.WebAuthnCredential_.Id).Eq(id)
+	results, err := user.ReadAllWebAuthnCredential(qb)
+	if err != nil {
+		return user.WebAuthnCredential{}, err
+	}
+	if len(results) == 0 {
+		return user.WebAuthnCredential{}, user.ErrNotFound
+	}
+	return *results[0], nil
+}
+
+func (m *Module) CredentialsFor(userID string) ([]user.WebAuthnCredential, error) {
+	qb := m.db.Query(&user.WebAuthnCredential{}).Where(user.WebAuthnCredential_.UserId).Eq(userID)
+	results, err := user.ReadAllWebAuthnCredential(qb)
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]user.WebAuthnCredential, len(results))
+	for i, c := range results {
+		creds[i] = *c
+	}
+	return creds, nil
+}
+
+func (m *Module) UpdateSignCount(id string, count int64) error {
+	c, err := m.CredentialByID(id)
+	if err != nil {
+		return err
+	}
+	c.SignCount = count
+	return m.db.Update(&c, orm.Eq(user.WebAuthnCredential_.Id, id))
+}
@@ -3,8 +3,6 @@ package emailpassword
 import (
 	"github.com/tinywasm/router"
 	"github.com/tinywasm/user"
-
-	"github.com/tinywasm/crypto/bcrypt"
 )
 
 type Authenticator struct {
@@ -13,7 +11,21 @@ type Authenticator struct {
 	notify     user.SecurityNotifier
 	afterLogin string
 	rateLimit  func(ip string) error
+	limiter    user.RateLimiter
 	trustProxy bool
+
+	passwords user.PasswordStore // non-nil = POST /register is mounted
+
+	resets   user.PasswordResetStore // non-nil = the /password/* routes are mounted
+	mailer   user.Mailer
+	resetURL string
+
+	verifier      user.EmailVerifier // non-nil = sign-ups start "pending", GET /verify-email is mounted
+	verifyMail    user.Mailer
+	origin        string
+	loginOnVerify bool
+
+	guard user.LoginGuard // nil = no per-account lockout
 }
 
 type Option func(*Authenticator)
@@ -24,6 +36,52 @@ func WithRateLimit(fn func(ip string) error) Option {
 }
 func WithTrustProxy(v bool) Option { return func(a *Authenticator) { a.trustProxy = v } }
 
+// WithRateLimiter limits every route of this mode per client IP and per email
+// (e.g. ratelimit.New). It composes with WithRateLimit: both must allow.
+func WithRateLimiter(l user.RateLimiter) Option { return func(a *Authenticator) { a.limiter = l } }
+
+// WithRegister mounts POST /register. passwords applies the same policy
+// Module.SetPassword does. Registering never signs anyone in — a session for
+// new accounts only would tell them from taken emails; to have the new
+// account signed in, use WithEmailVerificationLogin.
+func WithRegister(passwords user.PasswordStore) Option {
+	return func(a *Authenticator) { a.passwords = passwords }
+}
+
+// WithPasswordReset mounts POST /password/forgot and POST /password/reset.
+// resetURL is the app's own reset page; the mailed link is resetURL?token=...,
+// and that page posts the token back with the new password.
+func WithPasswordReset(resets user.PasswordResetStore, mailer user.Mailer, resetURL string) Option {
+	return func(a *Authenticator) { a.resets = resets; a.mailer = mailer; a.resetURL = resetURL }
+}
+
+// WithEmailVerification makes every sign-up start "pending" until the user
+// follows the link mailed to them: origin + /verify-email?token=.... origin is
+// the app's public scheme+host, e.g. "https://app.example.com". A pending
+// account can't log in until then; POST /verify-email/resend mails it a new
+// link.
+func WithEmailVerification(verifier user.EmailVerifier, mailer user.Mailer, origin string) Option {
+	return func(a *Authenticator) { a.verifier = verifier; a.verifyMail = mailer; a.origin = origin }
+}
+
+// WithEmailVerificationLogin is WithEmailVerification where following the
+// link also signs the account in, instead of sending the user to the login
+// page. Register still answers a taken email like a new one, since only the
+// mailbox sees the link. The trade-off is the link itself: until it is used
+// or expires, whoever reads it (a forwarded or leaked mail) gets a session
+// without the password, as with a magic link.
+func WithEmailVerificationLogin(verifier user.EmailVerifier, mailer user.Mailer, origin string) Option {
+	return func(a *Authenticator) {
+		WithEmailVerification(verifier, mailer, origin)(a)
+		a.loginOnVerify = true
+	}
+}
+
+// WithLockout locks an email out after repeated failed logins, on top of the
+// per-IP WithRateLimit. authority.Module implements guard; thresholds live in
+// user.Config.
+func WithLockout(guard user.LoginGuard) Option { return func(a *Authenticator) { a.guard = guard } }
+
 // New builds the email+password mode. store/sessions/notify are required ports;
 // everything else is an Option with a safe zero-value default.
 func New(store user.IdentityStore, sessions user.SessionIssuer, notify user.SecurityNotifier, opts ...Option) *Authenticator {
@@ -46,99 +104,112 @@ func (a *Authenticator) Mount(r router.Router) {
 		ip := user.ClientIP(ctx, a.trustProxy)
 		data := &user.LoginData{}
 		if err := ctx.Decode(data); err != nil {
-			ctx.WriteStatus(400)
-			ctx.Write([]byte(err.Error()))
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, err)
 			return
 		}
 
-		if a.rateLimit != nil {
-			if err := a.rateLimit(ip); err != nil {
-				a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, UserID: data.Email})
-				ctx.WriteStatus(429)
-				ctx.Write([]byte(err.Error()))
-				return
-			}
+		if a.limited(ctx, ip, data.Email) {
+			return
+		}
+
+		// A locked email still pays for one hash comparison, so the lock
+		// itself can't be timed.
+		if a.guard != nil && a.guard.Locked(data.Email) {
+			DummyCompare(data.Password)
+			user.RespondError(ctx, 423, user.CodeAccountLocked, user.ErrAccountLocked)
+			return
 		}
 
 		u, err := a.store.UserByEmail(data.Email)
 		if err != nil {
-			DummyCompare(data.Password, DefaultHashCost)
-			ctx.WriteStatus(401)
-			ctx.Write([]byte(user.ErrInvalidCredentials.Error()))
+			DummyCompare(data.Password)
+			a.deny(ctx, data.Email, ip)
 			return
 		}
-		if u.Status != "active" {
+		if u.Status != "active" && u.Status != "pending" {
 			a.notify.Notify(user.SecurityEvent{Type: user.EventNonActiveAccess, UserID: u.Id})
-			DummyCompare(data.Password, DefaultHashCost)
-			ctx.WriteStatus(401)
-			ctx.Write([]byte(user.ErrInvalidCredentials.Error()))
+			DummyCompare(data.Password)
+			a.deny(ctx, data.Email, ip)
 			return
 		}
 
 		identity, err := a.store.IdentityFor(u.Id, "email_password")
 		if err != nil {
-			DummyCompare(data.Password, DefaultHashCost)
-			ctx.WriteStatus(401)
-			ctx.Write([]byte(user.ErrInvalidCredentials.Error()))
+			DummyCompare(data.Password)
+			a.deny(ctx, data.Email, ip)
 			return
 		}
 		if err := VerifyPassword(identity.ProviderId, data.Password); err != nil {
 			a.notify.Notify(user.SecurityEvent{Type: user.EventAccessDenied, IP: ip, UserID: u.Id})
-			ctx.WriteStatus(401)
-			ctx.Write([]byte(err.Error()))
+			a.deny(ctx, data.Email, ip)
+			return
+		}
+		if a.guard != nil {
+			a.guard.RecordSuccess(data.Email)
+		}
+		if hash, ok := Rehash(identity.ProviderId, data.Password); ok {
+			a.store.UpsertIdentity(u.Id, "email_password", hash, "")
+		}
+		// Reported only after the password matched: a wrong guess at a pending
+		// account still reads as a plain 401.
+		if u.Status == "pending" {
+			a.notify.Notify(user.SecurityEvent{Type: user.EventPendingAccess, IP: ip, UserID: u.Id})
+			user.RespondError(ctx, 403, user.CodeEmailUnverified, user.ErrEmailUnverified)
 			return
 		}
 
-		if err := a.sessions.IssueSession(ctx, u.Id); err != nil {
-			ctx.WriteStatus(500)
-			ctx.Write([]byte(err.Error()))
+		// A password that must change buys a session that reaches nothing
+		// but change_password.
+		opts := []user.SessionOption{user.WithRemember(user.Checked(data.Remember))}
+		if pe, ok := a.store.(user.PasswordExpiry); ok {
+			pe.StartPasswordClock(u.Id)
+			if pe.MustChangePassword(u.Id) {
+				opts = append(opts, user.WithScope(user.OpChangePassword))
+			}
+		}
+		if err := a.sessions.IssueSession(ctx, u.Id, opts...); err != nil {
+			user.RespondIssueError(ctx, err)
 			return
 		}
-		ctx.SetHeader("Location", afterLogin)
-		ctx.WriteStatus(302)
+		user.RespondLogin(ctx, user.NextOr(ctx, afterLogin), a.store, u)
 	}).Public()
-}
-
-var _ user.Authenticator = (*Authenticator)(nil)
 
-// DefaultHashCost is bcrypt's cost factor. Tests lower it (bcrypt.MinCost) for
-// speed — same knob as the old package-level authority.PasswordHashCost.
-var DefaultHashCost = bcrypt.DefaultCost
-
-var dummyHashOnce []byte
-
-func getDummyHash(cost int) []byte {
-	if len(dummyHashOnce) == 0 {
-		dummyHashOnce, _ = bcrypt.GenerateFromPassword([]byte("dummy"), cost)
+	if a.passwords != nil {
+		a.mountRegister(r)
+	}
+	if a.resets != nil {
+		a.mountReset(r)
+	}
+	if a.verifier != nil {
+		a.mountVerify(r, afterLogin)
 	}
-	return dummyHashOnce
 }
 
-// DummyCompare burns the same time a real bcrypt comparison would, so a caller
-// can't distinguish "no such user" from "wrong password" by timing.
-func DummyCompare(password string, cost int) {
-	bcrypt.CompareHashAndPassword(getDummyHash(cost), []byte(password))
+// deny answers a failed login with the one uniform 401 and counts it toward
+// the lockout.
+func (a *Authenticator) deny(ctx router.Context, email, ip string) {
+	if a.guard != nil {
+		a.guard.RecordFailure(email, ip)
+	}
+	user.RespondError(ctx, 401, user.CodeInvalidCredentials, user.ErrInvalidCredentials)
 }
 
-// HashPassword is the ONLY place bcrypt.GenerateFromPassword is called in this
-// repo. authority/credentials_password.go calls this — it never calls bcrypt
-// directly.
-func HashPassword(password string, cost int) (string, error) {
-	if len(password) < 8 {
-		return "", user.ErrWeakPassword
+// limited runs the rate-limit hook and the limiter, answering 429 when either
+// rejects the attempt.
+func (a *Authenticator) limited(ctx router.Context, ip, email string) bool {
+	var err error
+	if a.rateLimit != nil {
+		err = a.rateLimit(ip)
 	}
-	h, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	if err != nil {
-		return "", err
+	if err == nil && !user.Allowed(a.limiter, ip, email) {
+		err = user.ErrRateLimited
 	}
-	return string(h), nil
-}
-
-// VerifyPassword is the ONLY place bcrypt.CompareHashAndPassword is called for a
-// real (non-dummy) comparison in this repo.
-func VerifyPassword(hash, password string) error {
-	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
-		return user.ErrInvalidCredentials
+	if err == nil {
+		return false
 	}
-	return nil
+	a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, UserID: email, Mode: a.Name()})
+	user.RespondError(ctx, 429, user.CodeRateLimited, err)
+	return true
 }
+
+var _ user.Authenticator = (*Authenticator)(nil)
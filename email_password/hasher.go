@@ -0,0 +1,251 @@
+package emailpassword
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"sync"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/user"
+	"golang.org/x/crypto/argon2"
+)
+
+// PasswordHasher is one password-hashing algorithm. Its encoded hashes are
+// self-describing ("$2a$..." for bcrypt, "$argon2id$..." for Argon2id), so the
+// identity row needs no algorithm column: VerifyPassword picks the hasher by
+// prefix.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) error // user.ErrInvalidCredentials on mismatch
+	Matches(encoded string) bool           // encoded was produced by this algorithm
+	Outdated(encoded string) bool          // encoded uses weaker parameters than this hasher
+}
+
+// DefaultHashCost is bcrypt's cost factor. Tests lower it (bcrypt.MinCost) for
+// speed — same knob as the old package-level authority.PasswordHashCost.
+var DefaultHashCost = bcrypt.DefaultCost
+
+// Hasher hashes every new password. Swapping it migrates the whole user base
+// without a forced reset: old hashes keep verifying, and each one is replaced
+// by Rehash on that user's next successful login.
+var Hasher PasswordHasher = Bcrypt{}
+
+// known verifies hashes made by an algorithm other than the current Hasher.
+var known = []PasswordHasher{Bcrypt{}, Argon2id{}}
+
+// Bcrypt hashes with bcrypt at Cost (0 = DefaultHashCost).
+type Bcrypt struct{ Cost int }
+
+func (b Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return DefaultHashCost
+	}
+	return b.Cost
+}
+
+// MaxLength is bcrypt's input limit in bytes.
+func (b Bcrypt) MaxLength() int { return 72 }
+
+// Hash refuses passwords over 72 bytes instead of letting bcrypt silently
+// ignore the rest.
+func (b Bcrypt) Hash(password string) (string, error) {
+	if len(password) > b.MaxLength() {
+		return "", user.ErrPasswordTooLong
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), b.cost())
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+func (b Bcrypt) Verify(encoded, password string) error {
+	if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+		return user.ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (b Bcrypt) Matches(encoded string) bool {
+	return fmt.HasPrefix(encoded, "$2a$") || fmt.HasPrefix(encoded, "$2b$") || fmt.HasPrefix(encoded, "$2y$")
+}
+
+// Outdated reads the cost from the "$2a$10$..." header.
+func (b Bcrypt) Outdated(encoded string) bool {
+	parts := fmt.Split(encoded, "$")
+	if len(parts) < 4 {
+		return true
+	}
+	cost, err := fmt.Convert(parts[2]).Int()
+	return err != nil || cost < b.cost()
+}
+
+// Argon2id hashes with Argon2id (RFC 9106) into the PHC string format. A zero
+// field takes the RFC's second recommended profile: 64 MiB, 3 passes, 4 lanes.
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+const argon2SaltLen, argon2KeyLen = 16, 32
+
+func (a Argon2id) params() (t, m uint32, p uint8) {
+	t, m, p = a.Time, a.Memory, a.Threads
+	if t == 0 {
+		t = 3
+	}
+	if m == 0 {
+		m = 64 * 1024
+	}
+	if p == 0 {
+		p = 4
+	}
+	return t, m, p
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	t, m, p := a.params()
+	key := argon2.IDKey([]byte(password), salt, t, m, p, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, m, t, p,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Verify(encoded, password string) error {
+	t, m, p, salt, key, ok := parseArgon2id(encoded)
+	if !ok {
+		return user.ErrInvalidCredentials
+	}
+	got := argon2.IDKey([]byte(password), salt, t, m, p, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return user.ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (a Argon2id) Matches(encoded string) bool { return fmt.HasPrefix(encoded, "$argon2id$") }
+
+func (a Argon2id) Outdated(encoded string) bool {
+	t, m, p, _, _, ok := parseArgon2id(encoded)
+	wt, wm, wp := a.params()
+	return !ok || t < wt || m < wm || p < wp
+}
+
+// parseArgon2id reads "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>".
+func parseArgon2id(encoded string) (t, m uint32, p uint8, salt, key []byte, ok bool) {
+	parts := fmt.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return 0, 0, 0, nil, nil, false
+	}
+	for _, kv := range fmt.Split(parts[3], ",") {
+		pair := fmt.Split(kv, "=")
+		if len(pair) != 2 {
+			return 0, 0, 0, nil, nil, false
+		}
+		n, err := fmt.Convert(pair[1]).Uint32()
+		if err != nil {
+			return 0, 0, 0, nil, nil, false
+		}
+		switch pair[0] {
+		case "m":
+			m = n
+		case "t":
+			t = n
+		case "p":
+			if n > 255 {
+				return 0, 0, 0, nil, nil, false
+			}
+			p = uint8(n)
+		}
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[4])
+	key, err2 := base64.RawStdEncoding.DecodeString(parts[5])
+	if err1 != nil || err2 != nil || t == 0 || m == 0 || p == 0 || len(key) == 0 {
+		return 0, 0, 0, nil, nil, false
+	}
+	return t, m, p, salt, key, true
+}
+
+func hasherFor(encoded string) PasswordHasher {
+	if Hasher.Matches(encoded) {
+		return Hasher
+	}
+	for _, h := range known {
+		if h.Matches(encoded) {
+			return h
+		}
+	}
+	return nil
+}
+
+var (
+	dummyMu   sync.Mutex
+	dummyHash string
+)
+
+// DummyCompare burns the same time a real comparison under the current Hasher
+// would, so a caller can't distinguish "no such user" from "wrong password" by
+// timing. The cached dummy hash is kept while Hasher would keep it as a user's
+// hash — same test as Rehash — so swapping or retuning Hasher regenerates it;
+// hashers are never compared with ==, which panics on uncomparable ones.
+func DummyCompare(password string) {
+	h := Hasher
+	dummyMu.Lock()
+	if !h.Matches(dummyHash) || h.Outdated(dummyHash) {
+		dummyHash, _ = h.Hash("dummy-password")
+	}
+	encoded := dummyHash
+	dummyMu.Unlock()
+	h.Verify(encoded, password)
+}
+
+// MaxPasswordLength is the longest password, in bytes, the current Hasher
+// accepts, or 0 for no limit. A hasher declares one with a MaxLength method;
+// authority's ValidatePassword enforces it before anything is written.
+func MaxPasswordLength() int {
+	if h, ok := Hasher.(interface{ MaxLength() int }); ok {
+		return h.MaxLength()
+	}
+	return 0
+}
+
+// HashPassword is the ONLY place a new password hash is produced in this repo.
+// authority/credentials_password.go calls this — it never calls a hasher
+// directly.
+func HashPassword(password string) (string, error) {
+	if len(password) < 8 {
+		return "", user.ErrWeakPassword
+	}
+	return Hasher.Hash(password)
+}
+
+// VerifyPassword is the ONLY place a stored hash is checked for real
+// (non-dummy) in this repo. It accepts any algorithm in known, not just the
+// current Hasher.
+func VerifyPassword(hash, password string) error {
+	h := hasherFor(hash)
+	if h == nil {
+		return user.ErrInvalidCredentials
+	}
+	return h.Verify(hash, password)
+}
+
+// Rehash returns a fresh hash of password when encoded was made by another
+// algorithm or weaker parameters than the current Hasher. Call it only after
+// VerifyPassword succeeded; ok=false means keep the stored hash.
+func Rehash(encoded, password string) (hash string, ok bool) {
+	if Hasher.Matches(encoded) && !Hasher.Outdated(encoded) {
+		return "", false
+	}
+	hash, err := Hasher.Hash(password)
+	if err != nil {
+		return "", false
+	}
+	return hash, true
+}
@@ -0,0 +1,90 @@
+package emailpassword
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/user"
+)
+
+// mountRegister serves POST /register. A taken email gets exactly the response
+// a new one does — same status, same Location, and the same bcrypt cost paid
+// via DummyCompare — so the endpoint can't be used to enumerate accounts. That
+// is also why it never issues a session: a cookie for new accounts only would
+// tell the two apart. See WithEmailVerificationLogin for the verified path.
+func (a *Authenticator) mountRegister(r router.Router) {
+	r.Post(user.PathRegister, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		data := &user.RegisterData{}
+		if err := ctx.Decode(data); err != nil {
+			ctx.WriteStatus(400)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+
+		if a.limited(ctx, ip, data.Email) {
+			return
+		}
+
+		if err := a.validate(data); err != nil {
+			user.WritePasswordError(ctx, err)
+			return
+		}
+
+		if err := a.register(data); err != nil {
+			user.RespondError(ctx, 500, user.CodeServerError, user.ErrSignupFailed)
+			return
+		}
+		ctx.SetHeader("Location", user.PathLogin)
+		ctx.WriteStatus(302)
+	}).Public()
+}
+
+// validate checks the payload shape and the password policy. It never looks
+// at the store, so its answer is the same whether or not the email exists.
+func (a *Authenticator) validate(data *user.RegisterData) error {
+	data.Name = fmt.Convert(data.Name).TrimSpace().String()
+	data.Email = fmt.Convert(data.Email).TrimSpace().String()
+	data.Phone = fmt.Convert(data.Phone).TrimSpace().String()
+	if data.Name == "" {
+		return user.ErrNameRequired
+	}
+	at := fmt.Index(data.Email, "@")
+	if at < 1 || at == len(data.Email)-1 {
+		return user.ErrInvalidEmail
+	}
+	return a.passwords.ValidatePassword(data.Password, data.Email, data.Name)
+}
+
+// register creates the account, or does nothing when the email is already
+// taken — after burning the time hashing would have taken.
+func (a *Authenticator) register(data *user.RegisterData) error {
+	if _, err := a.store.UserByEmail(data.Email); err == nil {
+		DummyCompare(data.Password)
+		return nil
+	}
+	u, err := a.store.CreateUser(data.Email, data.Name, data.Phone)
+	if err == user.ErrEmailTaken {
+		DummyCompare(data.Password)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Pending goes on before the password: the account is never active and
+	// loggable-into ahead of its email being proven.
+	var token string
+	if a.verifier != nil {
+		if token, err = a.verifier.CreateVerification(u.Id); err != nil {
+			return err
+		}
+	}
+	if err := a.passwords.SetPassword(u.Id, data.Password); err != nil {
+		return err
+	}
+	// Mailed after answering, like /password/forgot: a taken email sends
+	// nothing, and waiting on the mailer would tell the two apart.
+	if token != "" {
+		go a.verifyMail.Send(u.Email, user.MailVerifyEmail, withToken(a.origin+user.PathVerifyEmail, token))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package emailpassword
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/user"
+)
+
+// mountReset serves the forgotten-password flow. /password/forgot answers 202
+// whatever happens behind it — unknown email, inactive account, mailer failure
+// — so it can't be used to probe which emails have accounts. The lookup and
+// the mail run after the answer, so its timing gives nothing away either.
+func (a *Authenticator) mountReset(r router.Router) {
+	r.Post(user.PathPasswordForgot, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		data := &user.ForgotData{}
+		if err := ctx.Decode(data); err != nil {
+			ctx.WriteStatus(400)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+
+		if a.limited(ctx, ip, data.Email) {
+			return
+		}
+
+		go a.sendReset(fmt.Convert(data.Email).TrimSpace().String())
+		ctx.WriteStatus(202)
+	}).Public()
+
+	r.Post(user.PathPasswordReset, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		data := &user.ResetData{}
+		if err := ctx.Decode(data); err != nil {
+			ctx.WriteStatus(400)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+
+		if a.limited(ctx, ip, "") {
+			return
+		}
+
+		userID, err := a.resets.ResetPassword(data.Token, data.Password)
+		if err != nil {
+			user.WritePasswordError(ctx, err)
+			return
+		}
+		a.notify.Notify(user.SecurityEvent{Type: user.EventPasswordReset, IP: ip, UserID: userID})
+
+		ctx.SetHeader("Location", user.PathLogin)
+		ctx.WriteStatus(302)
+	}).Public()
+}
+
+func (a *Authenticator) sendReset(email string) {
+	u, err := a.store.UserByEmail(email)
+	if err != nil || u.Status != "active" {
+		return
+	}
+	token, err := a.resets.CreateResetToken(u.Id)
+	if err != nil {
+		return
+	}
+	a.mailer.Send(u.Email, user.MailPasswordReset, withToken(a.resetURL, token))
+}
+
+// withToken appends the token as a query parameter. Tokens are URL-safe, so no
+// escaping is needed.
+func withToken(base, token string) string {
+	sep := "?"
+	if fmt.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + token
+}
@@ -0,0 +1,68 @@
+package emailpassword
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/user"
+)
+
+// mountVerify serves the link WithEmailVerification mails out. It is a GET
+// because it is opened straight from the email client. With
+// WithEmailVerificationLogin, following it signs the now active account in:
+// only the owner of the mailbox holds the link, so this reveals nothing
+// register didn't.
+func (a *Authenticator) mountVerify(r router.Router, afterLogin string) {
+	r.Get(user.PathVerifyEmail, func(ctx router.Context) {
+		userID, err := a.verifier.VerifyEmail(user.QueryParam(ctx, "token"))
+		if err != nil {
+			ctx.WriteStatus(400)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+		if a.loginOnVerify {
+			if u, err := a.store.UserByID(userID); err == nil && u.Status == "active" {
+				if err := a.sessions.IssueSession(ctx, u.Id); err != nil {
+					user.RespondIssueError(ctx, err)
+					return
+				}
+				user.RespondLogin(ctx, user.NextOr(ctx, afterLogin), a.store, u)
+				return
+			}
+		}
+		ctx.SetHeader("Location", user.PathLogin)
+		ctx.WriteStatus(302)
+	}).Public()
+
+	// A lost or expired link is replaced through /verify-email/resend, which
+	// answers 202 like /password/forgot whoever the email belongs to.
+	r.Post(user.PathVerifyEmailResend, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		data := &user.ForgotData{}
+		if err := ctx.Decode(data); err != nil {
+			ctx.WriteStatus(400)
+			ctx.Write([]byte(err.Error()))
+			return
+		}
+		email := fmt.Convert(data.Email).TrimSpace().String()
+		if a.limited(ctx, ip, email) {
+			return
+		}
+
+		go a.resendVerification(email)
+		ctx.WriteStatus(202)
+	}).Public()
+}
+
+// resendVerification mails a fresh link to a still pending account; the new
+// token voids the earlier one.
+func (a *Authenticator) resendVerification(email string) {
+	u, err := a.store.UserByEmail(email)
+	if err != nil || u.Status != "pending" {
+		return
+	}
+	token, err := a.verifier.CreateVerification(u.Id)
+	if err != nil {
+		return
+	}
+	a.verifyMail.Send(u.Email, user.MailVerifyEmail, withToken(a.origin+user.PathVerifyEmail, token))
+}
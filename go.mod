@@ -16,9 +16,11 @@ require (
 	github.com/tinywasm/router v0.1.27
 	github.com/tinywasm/time v0.5.3
 	github.com/tinywasm/view v0.1.20
+	golang.org/x/crypto v0.55.0
 )
 
 require (
 	github.com/tinywasm/base64 v0.0.5 // indirect
 	github.com/tinywasm/storage v0.0.5 // indirect
+	golang.org/x/sys v0.47.0 // indirect
 )
@@ -0,0 +1,128 @@
+package magiclink
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/user"
+)
+
+type Authenticator struct {
+	store      user.IdentityStore
+	links      user.MagicLinkStore
+	sessions   user.SessionIssuer
+	notify     user.SecurityNotifier
+	mailer     user.Mailer
+	origin     string
+	afterLogin string
+	limiter    user.RateLimiter
+	trustProxy bool
+}
+
+type Option func(*Authenticator)
+
+func WithAfterLogin(path string) Option { return func(a *Authenticator) { a.afterLogin = path } }
+func WithTrustProxy(v bool) Option      { return func(a *Authenticator) { a.trustProxy = v } }
+
+// WithRateLimiter limits both routes per client IP, and POST /login/link per
+// email too (e.g. ratelimit.New).
+func WithRateLimiter(l user.RateLimiter) Option { return func(a *Authenticator) { a.limiter = l } }
+
+// New builds the passwordless mode. The mailed link is
+// origin + /login/link/verify?token=...; origin is the app's public
+// scheme+host, e.g. "https://app.example.com".
+func New(store user.IdentityStore, links user.MagicLinkStore, sessions user.SessionIssuer, notify user.SecurityNotifier, mailer user.Mailer, origin string, opts ...Option) *Authenticator {
+	a := &Authenticator{store: store, links: links, sessions: sessions, notify: notify, mailer: mailer, origin: origin}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *Authenticator) Name() string { return "magic_link" }
+
+// Mount serves the request and the link itself. POST /login/link answers 202
+// whatever happens behind it — unknown email, inactive account, mailer failure
+// — so it can't be used to probe which emails have accounts; the lookup and
+// the mail run after the answer so its timing doesn't either. The link is a GET
+// because it is opened straight from the email client.
+func (a *Authenticator) Mount(r router.Router) {
+	afterLogin := a.afterLogin
+	if afterLogin == "" {
+		afterLogin = user.PathAfterLogin
+	}
+
+	r.Post(user.PathMagicLink, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		data := &user.ForgotData{}
+		if err := ctx.Decode(data); err != nil {
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, nil)
+			return
+		}
+		email := fmt.Convert(data.Email).TrimSpace().String()
+		if a.limited(ctx, ip, email) {
+			return
+		}
+
+		go a.send(email)
+		ctx.WriteStatus(202)
+	}).Public()
+
+	r.Get(user.PathMagicLinkVerify, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		if a.limited(ctx, ip, "") {
+			return
+		}
+
+		userID, err := a.links.ConsumeMagicLink(user.QueryParam(ctx, "token"))
+		if err != nil {
+			user.RespondError(ctx, 401, user.CodeInvalidToken, user.ErrInvalidToken)
+			return
+		}
+		u, err := a.store.UserByID(userID)
+		if err != nil {
+			user.RespondError(ctx, 401, user.CodeInvalidToken, user.ErrInvalidToken)
+			return
+		}
+		if u.Status != "active" {
+			a.notify.Notify(user.SecurityEvent{Type: user.EventNonActiveAccess, IP: ip, UserID: u.Id, Mode: a.Name()})
+			user.RespondError(ctx, 401, user.CodeSuspended, nil)
+			return
+		}
+
+		if err := a.sessions.IssueSession(ctx, u.Id); err != nil {
+			user.RespondIssueError(ctx, err)
+			return
+		}
+		user.RespondLogin(ctx, user.NextOr(ctx, afterLogin), a.store, u)
+	}).Public()
+}
+
+func (a *Authenticator) send(email string) {
+	u, err := a.store.UserByEmail(email)
+	if err != nil || u.Status != "active" {
+		return
+	}
+	token, err := a.links.CreateMagicLink(u.Id)
+	if err != nil {
+		return
+	}
+	a.mailer.Send(u.Email, user.MailMagicLink, a.origin+user.PathMagicLinkVerify+"?token="+token)
+}
+
+// limited answers 429 when the limiter rejects ctx's client IP or key.
+func (a *Authenticator) limited(ctx router.Context, ip, key string) bool {
+	if user.Allowed(a.limiter, ip, key) {
+		return false
+	}
+	a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, UserID: key, Mode: a.Name()})
+	user.RespondError(ctx, 429, user.CodeRateLimited, user.ErrRateLimited)
+	return true
+}
+
+// Reaches: anyone with an email address can ask for a link.
+func (a *Authenticator) Reaches(u user.User) bool { return u.Email != "" }
+
+var (
+	_ user.Authenticator = (*Authenticator)(nil)
+	_ user.AddressLogin  = (*Authenticator)(nil)
+)
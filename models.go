@@ -29,6 +29,7 @@ var SessionModel = model.Definition{
 		{Name: "ip", Type: model.Text()},
 		{Name: "user_agent", Type: model.Text()},
 		{Name: "created_at", Type: model.Int()},
+		{Name: "scope", Type: model.Text()},
 	},
 }
 
@@ -96,16 +97,194 @@ var OAuthStateModel = model.Definition{
 	Fields: model.Fields{
 		{Name: "state", Type: model.Text(), DB: &model.FieldDB{PK: true}},
 		{Name: "provider", Type: model.Text()},
+		{Name: "code_verifier", Type: model.Text()},
+		{Name: "nonce", Type: model.Text()},
+		{Name: "link_user_id", Type: model.Text()}, // set when a signed-in user is linking this provider, not logging in
+		{Name: "next", Type: model.Text()},         // validated return path from ?next=, where the callback redirects
 		{Name: "expires_at", Type: model.Int()},
 		{Name: "created_at", Type: model.Int()},
 	},
 }
 
+// PasswordResetModel stores only the SHA-256 of each reset token: a leaked
+// table can't be replayed into a reset. claim names the one reset that got
+// to spend the token.
+var PasswordResetModel = model.Definition{
+	Name: "password_reset",
+	Fields: model.Fields{
+		{Name: "token_hash", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "expires_at", Type: model.Int()},
+		{Name: "created_at", Type: model.Int()},
+		{Name: "claim", Type: model.Text()},
+	},
+}
+
+// EmailVerificationModel, like PasswordResetModel, stores only token hashes.
+var EmailVerificationModel = model.Definition{
+	Name: "email_verification",
+	Fields: model.Fields{
+		{Name: "token_hash", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "expires_at", Type: model.Int()},
+		{Name: "created_at", Type: model.Int()},
+	},
+}
+
+// LoginLockModel tracks failed logins per identifier (the email as typed,
+// lowercased). No Ref to user: emails without an account are tracked too.
+var LoginLockModel = model.Definition{
+	Name: "login_lock",
+	Fields: model.Fields{
+		{Name: "identifier", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "failures", Type: model.Int()},
+		{Name: "lock_count", Type: model.Int()},
+		{Name: "locked_until", Type: model.Int()},
+		{Name: "updated_at", Type: model.Int()},
+		{Name: "claim", Type: model.Text()}, // serializes concurrent RecordFailure calls, like login_code's
+	},
+}
+
+// MFAAttemptModel counts wrong second-factor codes per partial session
+// ("session:" + id) and per user ("user:" + id) within a window, so POST
+// /mfa/verify stops long before a 6-digit code falls to guessing. claim
+// serializes concurrent counts the way login_code's does.
+var MFAAttemptModel = model.Definition{
+	Name: "mfa_attempt",
+	Fields: model.Fields{
+		{Name: "subject", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "failures", Type: model.Int()},
+		{Name: "window_at", Type: model.Int()},
+		{Name: "claim", Type: model.Text()},
+	},
+}
+
+// PasswordHistoryModel keeps the hashes of a user's recent passwords so
+// SetPassword can refuse a reuse. seq orders a user's rows (timestamps tie
+// within a second); pruned to Config.PasswordHistory rows.
+var PasswordHistoryModel = model.Definition{
+	Name: "password_history",
+	Fields: model.Fields{
+		{Name: "id", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "seq", Type: model.Int()},
+		{Name: "hash", Type: model.Text()},
+		{Name: "created_at", Type: model.Int()},
+	},
+}
+
+// PasswordStateModel holds what forced change and expiry need: whether an
+// admin set the password (must_change) and when it last changed.
+var PasswordStateModel = model.Definition{
+	Name: "password_state",
+	Fields: model.Fields{
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{PK: true, RefColumn: "id"}, Ref: &UserModel},
+		{Name: "must_change", Type: model.Int()},
+		{Name: "changed_at", Type: model.Int()},
+	},
+}
+
+// MagicLinkModel, like PasswordResetModel, stores only token hashes.
+var MagicLinkModel = model.Definition{
+	Name: "magic_link",
+	Fields: model.Fields{
+		{Name: "token_hash", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "expires_at", Type: model.Int()},
+		{Name: "created_at", Type: model.Int()},
+	},
+}
+
+// LoginCodeModel holds one code per user. client_hash binds it to the
+// requesting client; attempts counts guesses and claim names the last one
+// that got its turn. sends counts codes issued since window_at, so the row
+// outlives a burned code until the window closes.
+var LoginCodeModel = model.Definition{
+	Name: "login_code",
+	Fields: model.Fields{
+		{Name: "client_hash", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "code_hash", Type: model.Text()},
+		{Name: "attempts", Type: model.Int()},
+		{Name: "claim", Type: model.Text()},
+		{Name: "sends", Type: model.Int()},
+		{Name: "window_at", Type: model.Int()},
+		{Name: "expires_at", Type: model.Int()},
+		{Name: "created_at", Type: model.Int()},
+	},
+}
+
+// TOTPModel holds one authenticator secret per user. confirmed is 0 until the
+// user proves their app works; last_step is the replay guard, and claim names
+// the login that last moved it.
+var TOTPModel = model.Definition{
+	Name: "totp",
+	Fields: model.Fields{
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{PK: true, RefColumn: "id"}, Ref: &UserModel},
+		{Name: "secret", Type: model.Text()},
+		{Name: "confirmed", Type: model.Int()},
+		{Name: "last_step", Type: model.Int()},
+		{Name: "created_at", Type: model.Int()},
+		{Name: "claim", Type: model.Text()},
+	},
+}
+
+var RecoveryCodeModel = model.Definition{
+	Name: "recovery_code",
+	Fields: model.Fields{
+		{Name: "code_hash", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "created_at", Type: model.Int()},
+	},
+}
+
+// WebAuthnCredentialModel keeps a passkey's COSE public key (base64url) and
+// the last sign count its authenticator reported.
+var WebAuthnCredentialModel = model.Definition{
+	Name: "webauthn_credential",
+	Fields: model.Fields{
+		{Name: "id", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "public_key", Type: model.Text()},
+		{Name: "sign_count", Type: model.Int()},
+		{Name: "created_at", Type: model.Int()},
+	},
+}
+
+// WebAuthnChallengeModel stores only challenge hashes. user_id is empty for a
+// passwordless login, where the credential names the user.
+var WebAuthnChallengeModel = model.Definition{
+	Name: "webauthn_challenge",
+	Fields: model.Fields{
+		{Name: "challenge_hash", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text()},
+		{Name: "purpose", Type: model.Text()},
+		{Name: "expires_at", Type: model.Int()},
+	},
+}
+
+// ProviderTokenModel keeps the provider tokens of one OAuth identity, sealed
+// with Config.TokenKey (AES-256-GCM, base64url nonce||ciphertext). expiry is
+// when the access token stops working, 0 if the provider didn't say.
+var ProviderTokenModel = model.Definition{
+	Name: "provider_token",
+	Fields: model.Fields{
+		{Name: "identity_id", Type: model.Text(), DB: &model.FieldDB{PK: true}},
+		{Name: "user_id", Type: model.Text(), DB: &model.FieldDB{RefColumn: "id"}, Ref: &UserModel},
+		{Name: "provider", Type: model.Text()},
+		{Name: "access_token", Type: model.Text()},
+		{Name: "refresh_token", Type: model.Text()},
+		{Name: "expiry", Type: model.Int()},
+		{Name: "updated_at", Type: model.Int()},
+	},
+}
+
 var LoginDataModel = model.Definition{
 	Name: "login_data",
 	Fields: model.Fields{
 		{Name: "email", Type: input.Email(), NotNull: true},
 		{Name: "password", Type: input.Password(), NotNull: true},
+		{Name: "remember", Type: model.Text()}, // "on"/"true"/"1" = remember me
 	},
 }
 
@@ -135,3 +314,26 @@ var PasswordDataModel = model.Definition{
 		{Name: "confirm", Type: input.Password(), NotNull: true},
 	},
 }
+
+var TemporaryPasswordDataModel = model.Definition{
+	Name: "temporary_password_data",
+	Fields: model.Fields{
+		{Name: "user_id", Type: model.Text(), NotNull: true},
+		{Name: "password", Type: input.Password(), NotNull: true},
+	},
+}
+
+var ForgotDataModel = model.Definition{
+	Name: "forgot_data",
+	Fields: model.Fields{
+		{Name: "email", Type: input.Email(), NotNull: true},
+	},
+}
+
+var ResetDataModel = model.Definition{
+	Name: "reset_data",
+	Fields: model.Fields{
+		{Name: "token", Type: model.Text(), NotNull: true},
+		{Name: "password", Type: input.Password(), NotNull: true},
+	},
+}
@@ -135,6 +135,7 @@ type Session struct {
 	Ip        string
 	UserAgent string
 	CreatedAt int64
+	Scope     string
 }
 
 func (m *Session) ModelName() string { return "session" }
@@ -142,7 +143,7 @@ func (m *Session) ModelName() string { return "session" }
 func (m *Session) Schema() []model.Field { return SessionModel.Fields }
 
 func (m *Session) Pointers() []any {
-	return []any{&m.Id, &m.UserId, &m.ExpiresAt, &m.Ip, &m.UserAgent, &m.CreatedAt}
+	return []any{&m.Id, &m.UserId, &m.ExpiresAt, &m.Ip, &m.UserAgent, &m.CreatedAt, &m.Scope}
 }
 
 func (m *Session) IsNil() bool { return m == nil }
@@ -154,6 +155,7 @@ func (m *Session) EncodeFields(w model.FieldWriter) {
 	w.String("ip", m.Ip)
 	w.String("user_agent", m.UserAgent)
 	w.Int("created_at", m.CreatedAt)
+	w.String("scope", m.Scope)
 }
 
 func (m *Session) DecodeFields(r model.FieldReader) {
@@ -175,6 +177,9 @@ func (m *Session) DecodeFields(r model.FieldReader) {
 	if v, ok := r.Int("created_at"); ok {
 		m.CreatedAt = v
 	}
+	if v, ok := r.String("scope"); ok {
+		m.Scope = v
+	}
 }
 
 type SessionList []*Session
@@ -199,6 +204,7 @@ var Session_ = struct {
 	Ip        string
 	UserAgent string
 	CreatedAt string
+	Scope     string
 }{
 	Id:        "id",
 	UserId:    "user_id",
@@ -206,6 +212,7 @@ var Session_ = struct {
 	Ip:        "ip",
 	UserAgent: "user_agent",
 	CreatedAt: "created_at",
+	Scope:     "scope",
 }
 
 func ReadOneSession(qb *orm.QB, model *Session) (*Session, error) {
@@ -743,10 +750,14 @@ func (m *LANIP) SchemaExt() []model.FieldExt {
 }
 
 type OAuthState struct {
-	State     string
-	Provider  string
-	ExpiresAt int64
-	CreatedAt int64
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	LinkUserId   string
+	Next         string
+	ExpiresAt    int64
+	CreatedAt    int64
 }
 
 func (m *OAuthState) ModelName() string { return "oauth_state" }
@@ -754,7 +765,7 @@ func (m *OAuthState) ModelName() string { return "oauth_state" }
 func (m *OAuthState) Schema() []model.Field { return OAuthStateModel.Fields }
 
 func (m *OAuthState) Pointers() []any {
-	return []any{&m.State, &m.Provider, &m.ExpiresAt, &m.CreatedAt}
+	return []any{&m.State, &m.Provider, &m.CodeVerifier, &m.Nonce, &m.LinkUserId, &m.Next, &m.ExpiresAt, &m.CreatedAt}
 }
 
 func (m *OAuthState) IsNil() bool { return m == nil }
@@ -762,6 +773,10 @@ func (m *OAuthState) IsNil() bool { return m == nil }
 func (m *OAuthState) EncodeFields(w model.FieldWriter) {
 	w.String("state", m.State)
 	w.String("provider", m.Provider)
+	w.String("code_verifier", m.CodeVerifier)
+	w.String("nonce", m.Nonce)
+	w.String("link_user_id", m.LinkUserId)
+	w.String("next", m.Next)
 	w.Int("expires_at", m.ExpiresAt)
 	w.Int("created_at", m.CreatedAt)
 }
@@ -773,6 +788,18 @@ func (m *OAuthState) DecodeFields(r model.FieldReader) {
 	if v, ok := r.String("provider"); ok {
 		m.Provider = v
 	}
+	if v, ok := r.String("code_verifier"); ok {
+		m.CodeVerifier = v
+	}
+	if v, ok := r.String("nonce"); ok {
+		m.Nonce = v
+	}
+	if v, ok := r.String("link_user_id"); ok {
+		m.LinkUserId = v
+	}
+	if v, ok := r.String("next"); ok {
+		m.Next = v
+	}
 	if v, ok := r.Int("expires_at"); ok {
 		m.ExpiresAt = v
 	}
@@ -797,15 +824,23 @@ func (m *OAuthState) Validate(action byte) error {
 }
 
 var OAuthState_ = struct {
-	State     string
-	Provider  string
-	ExpiresAt string
-	CreatedAt string
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	LinkUserId   string
+	Next         string
+	ExpiresAt    string
+	CreatedAt    string
 }{
-	State:     "state",
-	Provider:  "provider",
-	ExpiresAt: "expires_at",
-	CreatedAt: "created_at",
+	State:        "state",
+	Provider:     "provider",
+	CodeVerifier: "code_verifier",
+	Nonce:        "nonce",
+	LinkUserId:   "link_user_id",
+	Next:         "next",
+	ExpiresAt:    "expires_at",
+	CreatedAt:    "created_at",
 }
 
 func ReadOneOAuthState(qb *orm.QB, model *OAuthState) (*OAuthState, error) {
@@ -825,185 +860,1569 @@ func ReadAllOAuthState(qb *orm.QB) (OAuthStateList, error) {
 	return results, err
 }
 
-type LoginData struct {
-	Email    string
-	Password string
+type PasswordReset struct {
+	TokenHash string
+	UserId    string
+	ExpiresAt int64
+	CreatedAt int64
+	Claim     string
 }
 
-func (m *LoginData) ModelName() string { return "login_data" }
+func (m *PasswordReset) ModelName() string { return "password_reset" }
 
-func (m *LoginData) Schema() []model.Field { return LoginDataModel.Fields }
+func (m *PasswordReset) Schema() []model.Field { return PasswordResetModel.Fields }
 
-func (m *LoginData) Pointers() []any { return []any{&m.Email, &m.Password} }
+func (m *PasswordReset) Pointers() []any {
+	return []any{&m.TokenHash, &m.UserId, &m.ExpiresAt, &m.CreatedAt, &m.Claim}
+}
 
-func (m *LoginData) IsNil() bool { return m == nil }
+func (m *PasswordReset) IsNil() bool { return m == nil }
 
-func (m *LoginData) EncodeFields(w model.FieldWriter) {
-	w.String("email", m.Email)
-	w.String("password", m.Password)
+func (m *PasswordReset) EncodeFields(w model.FieldWriter) {
+	w.String("token_hash", m.TokenHash)
+	w.String("user_id", m.UserId)
+	w.Int("expires_at", m.ExpiresAt)
+	w.Int("created_at", m.CreatedAt)
+	w.String("claim", m.Claim)
 }
 
-func (m *LoginData) DecodeFields(r model.FieldReader) {
-	if v, ok := r.String("email"); ok {
-		m.Email = v
+func (m *PasswordReset) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("token_hash"); ok {
+		m.TokenHash = v
 	}
-	if v, ok := r.String("password"); ok {
-		m.Password = v
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.Int("expires_at"); ok {
+		m.ExpiresAt = v
+	}
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
+	}
+	if v, ok := r.String("claim"); ok {
+		m.Claim = v
 	}
 }
 
-type LoginDataList []*LoginData
+type PasswordResetList []*PasswordReset
 
-func (s *LoginDataList) Schema() []model.Field            { return nil }
-func (s *LoginDataList) Pointers() []any                  { return nil }
-func (s *LoginDataList) Len() int                         { return len(*s) }
-func (s *LoginDataList) At(i int) model.Fielder           { return (*s)[i] }
-func (s *LoginDataList) Append() model.Fielder            { v := &LoginData{}; *s = append(*s, v); return v }
-func (s *LoginDataList) IsNil() bool                      { return s == nil }
-func (s *LoginDataList) EncodeFields(_ model.FieldWriter) {}
-func (s *LoginDataList) DecodeFields(_ model.FieldReader) {}
+func (s *PasswordResetList) Schema() []model.Field  { return nil }
+func (s *PasswordResetList) Pointers() []any        { return nil }
+func (s *PasswordResetList) Len() int               { return len(*s) }
+func (s *PasswordResetList) At(i int) model.Fielder { return (*s)[i] }
+func (s *PasswordResetList) Append() model.Fielder {
+	v := &PasswordReset{}
+	*s = append(*s, v)
+	return v
+}
+func (s *PasswordResetList) IsNil() bool                      { return s == nil }
+func (s *PasswordResetList) EncodeFields(_ model.FieldWriter) {}
+func (s *PasswordResetList) DecodeFields(_ model.FieldReader) {}
 
-func (m *LoginData) Validate(action byte) error {
+func (m *PasswordReset) Validate(action byte) error {
 	return model.ValidateFields(action, m)
 }
 
-type RegisterData struct {
-	Name     string
-	Email    string
-	Password string
-	Phone    string
+var PasswordReset_ = struct {
+	TokenHash string
+	UserId    string
+	ExpiresAt string
+	CreatedAt string
+	Claim     string
+}{
+	TokenHash: "token_hash",
+	UserId:    "user_id",
+	ExpiresAt: "expires_at",
+	CreatedAt: "created_at",
+	Claim:     "claim",
 }
 
-func (m *RegisterData) ModelName() string { return "register_data" }
+func ReadOnePasswordReset(qb *orm.QB, model *PasswordReset) (*PasswordReset, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
 
-func (m *RegisterData) Schema() []model.Field { return RegisterDataModel.Fields }
+func ReadAllPasswordReset(qb *orm.QB) (PasswordResetList, error) {
+	var results PasswordResetList
+	err := qb.ReadAll(
+		func() model.Model { return &PasswordReset{} },
+		func(m model.Model) { results = append(results, m.(*PasswordReset)) },
+	)
+	return results, err
+}
 
-func (m *RegisterData) Pointers() []any { return []any{&m.Name, &m.Email, &m.Password, &m.Phone} }
+func (m *PasswordReset) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: PasswordResetModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
 
-func (m *RegisterData) IsNil() bool { return m == nil }
+type EmailVerification struct {
+	TokenHash string
+	UserId    string
+	ExpiresAt int64
+	CreatedAt int64
+}
 
-func (m *RegisterData) EncodeFields(w model.FieldWriter) {
-	w.String("name", m.Name)
-	w.String("email", m.Email)
-	w.String("password", m.Password)
-	w.String("phone", m.Phone)
+func (m *EmailVerification) ModelName() string { return "email_verification" }
+
+func (m *EmailVerification) Schema() []model.Field { return EmailVerificationModel.Fields }
+
+func (m *EmailVerification) Pointers() []any {
+	return []any{&m.TokenHash, &m.UserId, &m.ExpiresAt, &m.CreatedAt}
 }
 
-func (m *RegisterData) DecodeFields(r model.FieldReader) {
-	if v, ok := r.String("name"); ok {
-		m.Name = v
+func (m *EmailVerification) IsNil() bool { return m == nil }
+
+func (m *EmailVerification) EncodeFields(w model.FieldWriter) {
+	w.String("token_hash", m.TokenHash)
+	w.String("user_id", m.UserId)
+	w.Int("expires_at", m.ExpiresAt)
+	w.Int("created_at", m.CreatedAt)
+}
+
+func (m *EmailVerification) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("token_hash"); ok {
+		m.TokenHash = v
 	}
-	if v, ok := r.String("email"); ok {
-		m.Email = v
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
 	}
-	if v, ok := r.String("password"); ok {
-		m.Password = v
+	if v, ok := r.Int("expires_at"); ok {
+		m.ExpiresAt = v
 	}
-	if v, ok := r.String("phone"); ok {
-		m.Phone = v
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
 	}
 }
 
-type RegisterDataList []*RegisterData
+type EmailVerificationList []*EmailVerification
 
-func (s *RegisterDataList) Schema() []model.Field            { return nil }
-func (s *RegisterDataList) Pointers() []any                  { return nil }
-func (s *RegisterDataList) Len() int                         { return len(*s) }
-func (s *RegisterDataList) At(i int) model.Fielder           { return (*s)[i] }
-func (s *RegisterDataList) Append() model.Fielder            { v := &RegisterData{}; *s = append(*s, v); return v }
-func (s *RegisterDataList) IsNil() bool                      { return s == nil }
-func (s *RegisterDataList) EncodeFields(_ model.FieldWriter) {}
-func (s *RegisterDataList) DecodeFields(_ model.FieldReader) {}
+func (s *EmailVerificationList) Schema() []model.Field  { return nil }
+func (s *EmailVerificationList) Pointers() []any        { return nil }
+func (s *EmailVerificationList) Len() int               { return len(*s) }
+func (s *EmailVerificationList) At(i int) model.Fielder { return (*s)[i] }
+func (s *EmailVerificationList) Append() model.Fielder {
+	v := &EmailVerification{}
+	*s = append(*s, v)
+	return v
+}
+func (s *EmailVerificationList) IsNil() bool                      { return s == nil }
+func (s *EmailVerificationList) EncodeFields(_ model.FieldWriter) {}
+func (s *EmailVerificationList) DecodeFields(_ model.FieldReader) {}
 
-func (m *RegisterData) Validate(action byte) error {
+func (m *EmailVerification) Validate(action byte) error {
 	return model.ValidateFields(action, m)
 }
 
-type ProfileData struct {
-	Name  string
-	Phone string
+var EmailVerification_ = struct {
+	TokenHash string
+	UserId    string
+	ExpiresAt string
+	CreatedAt string
+}{
+	TokenHash: "token_hash",
+	UserId:    "user_id",
+	ExpiresAt: "expires_at",
+	CreatedAt: "created_at",
 }
 
-func (m *ProfileData) ModelName() string { return "profile_data" }
+func ReadOneEmailVerification(qb *orm.QB, model *EmailVerification) (*EmailVerification, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
 
-func (m *ProfileData) Schema() []model.Field { return ProfileDataModel.Fields }
+func ReadAllEmailVerification(qb *orm.QB) (EmailVerificationList, error) {
+	var results EmailVerificationList
+	err := qb.ReadAll(
+		func() model.Model { return &EmailVerification{} },
+		func(m model.Model) { results = append(results, m.(*EmailVerification)) },
+	)
+	return results, err
+}
 
-func (m *ProfileData) Pointers() []any { return []any{&m.Name, &m.Phone} }
+func (m *EmailVerification) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: EmailVerificationModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
 
-func (m *ProfileData) IsNil() bool { return m == nil }
+type LoginLock struct {
+	Identifier  string
+	Failures    int64
+	LockCount   int64
+	LockedUntil int64
+	UpdatedAt   int64
+	Claim       string
+}
 
-func (m *ProfileData) EncodeFields(w model.FieldWriter) {
-	w.String("name", m.Name)
-	w.String("phone", m.Phone)
+func (m *LoginLock) ModelName() string { return "login_lock" }
+
+func (m *LoginLock) Schema() []model.Field { return LoginLockModel.Fields }
+
+func (m *LoginLock) Pointers() []any {
+	return []any{&m.Identifier, &m.Failures, &m.LockCount, &m.LockedUntil, &m.UpdatedAt, &m.Claim}
 }
 
-func (m *ProfileData) DecodeFields(r model.FieldReader) {
-	if v, ok := r.String("name"); ok {
-		m.Name = v
+func (m *LoginLock) IsNil() bool { return m == nil }
+
+func (m *LoginLock) EncodeFields(w model.FieldWriter) {
+	w.String("identifier", m.Identifier)
+	w.Int("failures", m.Failures)
+	w.Int("lock_count", m.LockCount)
+	w.Int("locked_until", m.LockedUntil)
+	w.Int("updated_at", m.UpdatedAt)
+	w.String("claim", m.Claim)
+}
+
+func (m *LoginLock) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("identifier"); ok {
+		m.Identifier = v
 	}
-	if v, ok := r.String("phone"); ok {
-		m.Phone = v
+	if v, ok := r.Int("failures"); ok {
+		m.Failures = v
+	}
+	if v, ok := r.Int("lock_count"); ok {
+		m.LockCount = v
+	}
+	if v, ok := r.Int("locked_until"); ok {
+		m.LockedUntil = v
+	}
+	if v, ok := r.Int("updated_at"); ok {
+		m.UpdatedAt = v
+	}
+	if v, ok := r.String("claim"); ok {
+		m.Claim = v
 	}
 }
 
-type ProfileDataList []*ProfileData
+type LoginLockList []*LoginLock
 
-func (s *ProfileDataList) Schema() []model.Field            { return nil }
-func (s *ProfileDataList) Pointers() []any                  { return nil }
-func (s *ProfileDataList) Len() int                         { return len(*s) }
-func (s *ProfileDataList) At(i int) model.Fielder           { return (*s)[i] }
-func (s *ProfileDataList) Append() model.Fielder            { v := &ProfileData{}; *s = append(*s, v); return v }
-func (s *ProfileDataList) IsNil() bool                      { return s == nil }
-func (s *ProfileDataList) EncodeFields(_ model.FieldWriter) {}
-func (s *ProfileDataList) DecodeFields(_ model.FieldReader) {}
+func (s *LoginLockList) Schema() []model.Field            { return nil }
+func (s *LoginLockList) Pointers() []any                  { return nil }
+func (s *LoginLockList) Len() int                         { return len(*s) }
+func (s *LoginLockList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *LoginLockList) Append() model.Fielder            { v := &LoginLock{}; *s = append(*s, v); return v }
+func (s *LoginLockList) IsNil() bool                      { return s == nil }
+func (s *LoginLockList) EncodeFields(_ model.FieldWriter) {}
+func (s *LoginLockList) DecodeFields(_ model.FieldReader) {}
 
-func (m *ProfileData) Validate(action byte) error {
+func (m *LoginLock) Validate(action byte) error {
 	return model.ValidateFields(action, m)
 }
 
-type PasswordData struct {
-	Current string
-	New     string
-	Confirm string
+var LoginLock_ = struct {
+	Identifier  string
+	Failures    string
+	LockCount   string
+	LockedUntil string
+	UpdatedAt   string
+	Claim       string
+}{
+	Identifier:  "identifier",
+	Failures:    "failures",
+	LockCount:   "lock_count",
+	LockedUntil: "locked_until",
+	UpdatedAt:   "updated_at",
+	Claim:       "claim",
 }
 
-func (m *PasswordData) ModelName() string { return "password_data" }
+func ReadOneLoginLock(qb *orm.QB, model *LoginLock) (*LoginLock, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
 
-func (m *PasswordData) Schema() []model.Field { return PasswordDataModel.Fields }
+func ReadAllLoginLock(qb *orm.QB) (LoginLockList, error) {
+	var results LoginLockList
+	err := qb.ReadAll(
+		func() model.Model { return &LoginLock{} },
+		func(m model.Model) { results = append(results, m.(*LoginLock)) },
+	)
+	return results, err
+}
 
-func (m *PasswordData) Pointers() []any { return []any{&m.Current, &m.New, &m.Confirm} }
+type MFAAttempt struct {
+	Subject  string
+	Failures int64
+	WindowAt int64
+	Claim    string
+}
 
-func (m *PasswordData) IsNil() bool { return m == nil }
+func (m *MFAAttempt) ModelName() string { return "mfa_attempt" }
 
-func (m *PasswordData) EncodeFields(w model.FieldWriter) {
-	w.String("current", m.Current)
-	w.String("new", m.New)
-	w.String("confirm", m.Confirm)
+func (m *MFAAttempt) Schema() []model.Field { return MFAAttemptModel.Fields }
+
+func (m *MFAAttempt) Pointers() []any {
+	return []any{&m.Subject, &m.Failures, &m.WindowAt, &m.Claim}
 }
 
-func (m *PasswordData) DecodeFields(r model.FieldReader) {
-	if v, ok := r.String("current"); ok {
-		m.Current = v
+func (m *MFAAttempt) IsNil() bool { return m == nil }
+
+func (m *MFAAttempt) EncodeFields(w model.FieldWriter) {
+	w.String("subject", m.Subject)
+	w.Int("failures", m.Failures)
+	w.Int("window_at", m.WindowAt)
+	w.String("claim", m.Claim)
+}
+
+func (m *MFAAttempt) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("subject"); ok {
+		m.Subject = v
 	}
-	if v, ok := r.String("new"); ok {
-		m.New = v
+	if v, ok := r.Int("failures"); ok {
+		m.Failures = v
 	}
-	if v, ok := r.String("confirm"); ok {
-		m.Confirm = v
+	if v, ok := r.Int("window_at"); ok {
+		m.WindowAt = v
+	}
+	if v, ok := r.String("claim"); ok {
+		m.Claim = v
 	}
 }
 
-type PasswordDataList []*PasswordData
+type MFAAttemptList []*MFAAttempt
 
-func (s *PasswordDataList) Schema() []model.Field            { return nil }
-func (s *PasswordDataList) Pointers() []any                  { return nil }
-func (s *PasswordDataList) Len() int                         { return len(*s) }
-func (s *PasswordDataList) At(i int) model.Fielder           { return (*s)[i] }
-func (s *PasswordDataList) Append() model.Fielder            { v := &PasswordData{}; *s = append(*s, v); return v }
-func (s *PasswordDataList) IsNil() bool                      { return s == nil }
-func (s *PasswordDataList) EncodeFields(_ model.FieldWriter) {}
-func (s *PasswordDataList) DecodeFields(_ model.FieldReader) {}
+func (s *MFAAttemptList) Schema() []model.Field            { return nil }
+func (s *MFAAttemptList) Pointers() []any                  { return nil }
+func (s *MFAAttemptList) Len() int                         { return len(*s) }
+func (s *MFAAttemptList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *MFAAttemptList) Append() model.Fielder            { v := &MFAAttempt{}; *s = append(*s, v); return v }
+func (s *MFAAttemptList) IsNil() bool                      { return s == nil }
+func (s *MFAAttemptList) EncodeFields(_ model.FieldWriter) {}
+func (s *MFAAttemptList) DecodeFields(_ model.FieldReader) {}
 
-func (m *PasswordData) Validate(action byte) error {
+func (m *MFAAttempt) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var MFAAttempt_ = struct {
+	Subject  string
+	Failures string
+	WindowAt string
+	Claim    string
+}{
+	Subject:  "subject",
+	Failures: "failures",
+	WindowAt: "window_at",
+	Claim:    "claim",
+}
+
+func ReadOneMFAAttempt(qb *orm.QB, model *MFAAttempt) (*MFAAttempt, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllMFAAttempt(qb *orm.QB) (MFAAttemptList, error) {
+	var results MFAAttemptList
+	err := qb.ReadAll(
+		func() model.Model { return &MFAAttempt{} },
+		func(m model.Model) { results = append(results, m.(*MFAAttempt)) },
+	)
+	return results, err
+}
+
+type PasswordHistory struct {
+	Id        string
+	UserId    string
+	Seq       int64
+	Hash      string
+	CreatedAt int64
+}
+
+func (m *PasswordHistory) ModelName() string { return "password_history" }
+
+func (m *PasswordHistory) Schema() []model.Field { return PasswordHistoryModel.Fields }
+
+func (m *PasswordHistory) Pointers() []any {
+	return []any{&m.Id, &m.UserId, &m.Seq, &m.Hash, &m.CreatedAt}
+}
+
+func (m *PasswordHistory) IsNil() bool { return m == nil }
+
+func (m *PasswordHistory) EncodeFields(w model.FieldWriter) {
+	w.String("id", m.Id)
+	w.String("user_id", m.UserId)
+	w.Int("seq", m.Seq)
+	w.String("hash", m.Hash)
+	w.Int("created_at", m.CreatedAt)
+}
+
+func (m *PasswordHistory) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("id"); ok {
+		m.Id = v
+	}
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.Int("seq"); ok {
+		m.Seq = v
+	}
+	if v, ok := r.String("hash"); ok {
+		m.Hash = v
+	}
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
+	}
+}
+
+type PasswordHistoryList []*PasswordHistory
+
+func (s *PasswordHistoryList) Schema() []model.Field  { return nil }
+func (s *PasswordHistoryList) Pointers() []any        { return nil }
+func (s *PasswordHistoryList) Len() int               { return len(*s) }
+func (s *PasswordHistoryList) At(i int) model.Fielder { return (*s)[i] }
+func (s *PasswordHistoryList) Append() model.Fielder {
+	v := &PasswordHistory{}
+	*s = append(*s, v)
+	return v
+}
+func (s *PasswordHistoryList) IsNil() bool                      { return s == nil }
+func (s *PasswordHistoryList) EncodeFields(_ model.FieldWriter) {}
+func (s *PasswordHistoryList) DecodeFields(_ model.FieldReader) {}
+
+func (m *PasswordHistory) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var PasswordHistory_ = struct {
+	Id        string
+	UserId    string
+	Seq       string
+	Hash      string
+	CreatedAt string
+}{
+	Id:        "id",
+	UserId:    "user_id",
+	Seq:       "seq",
+	Hash:      "hash",
+	CreatedAt: "created_at",
+}
+
+func ReadOnePasswordHistory(qb *orm.QB, model *PasswordHistory) (*PasswordHistory, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllPasswordHistory(qb *orm.QB) (PasswordHistoryList, error) {
+	var results PasswordHistoryList
+	err := qb.ReadAll(
+		func() model.Model { return &PasswordHistory{} },
+		func(m model.Model) { results = append(results, m.(*PasswordHistory)) },
+	)
+	return results, err
+}
+
+func (m *PasswordHistory) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: PasswordHistoryModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type PasswordState struct {
+	UserId     string
+	MustChange int64
+	ChangedAt  int64
+}
+
+func (m *PasswordState) ModelName() string { return "password_state" }
+
+func (m *PasswordState) Schema() []model.Field { return PasswordStateModel.Fields }
+
+func (m *PasswordState) Pointers() []any { return []any{&m.UserId, &m.MustChange, &m.ChangedAt} }
+
+func (m *PasswordState) IsNil() bool { return m == nil }
+
+func (m *PasswordState) EncodeFields(w model.FieldWriter) {
+	w.String("user_id", m.UserId)
+	w.Int("must_change", m.MustChange)
+	w.Int("changed_at", m.ChangedAt)
+}
+
+func (m *PasswordState) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.Int("must_change"); ok {
+		m.MustChange = v
+	}
+	if v, ok := r.Int("changed_at"); ok {
+		m.ChangedAt = v
+	}
+}
+
+type PasswordStateList []*PasswordState
+
+func (s *PasswordStateList) Schema() []model.Field  { return nil }
+func (s *PasswordStateList) Pointers() []any        { return nil }
+func (s *PasswordStateList) Len() int               { return len(*s) }
+func (s *PasswordStateList) At(i int) model.Fielder { return (*s)[i] }
+func (s *PasswordStateList) Append() model.Fielder {
+	v := &PasswordState{}
+	*s = append(*s, v)
+	return v
+}
+func (s *PasswordStateList) IsNil() bool                      { return s == nil }
+func (s *PasswordStateList) EncodeFields(_ model.FieldWriter) {}
+func (s *PasswordStateList) DecodeFields(_ model.FieldReader) {}
+
+func (m *PasswordState) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var PasswordState_ = struct {
+	UserId     string
+	MustChange string
+	ChangedAt  string
+}{
+	UserId:     "user_id",
+	MustChange: "must_change",
+	ChangedAt:  "changed_at",
+}
+
+func ReadOnePasswordState(qb *orm.QB, model *PasswordState) (*PasswordState, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllPasswordState(qb *orm.QB) (PasswordStateList, error) {
+	var results PasswordStateList
+	err := qb.ReadAll(
+		func() model.Model { return &PasswordState{} },
+		func(m model.Model) { results = append(results, m.(*PasswordState)) },
+	)
+	return results, err
+}
+
+func (m *PasswordState) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: PasswordStateModel.Fields[0], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type MagicLink struct {
+	TokenHash string
+	UserId    string
+	ExpiresAt int64
+	CreatedAt int64
+}
+
+func (m *MagicLink) ModelName() string { return "magic_link" }
+
+func (m *MagicLink) Schema() []model.Field { return MagicLinkModel.Fields }
+
+func (m *MagicLink) Pointers() []any {
+	return []any{&m.TokenHash, &m.UserId, &m.ExpiresAt, &m.CreatedAt}
+}
+
+func (m *MagicLink) IsNil() bool { return m == nil }
+
+func (m *MagicLink) EncodeFields(w model.FieldWriter) {
+	w.String("token_hash", m.TokenHash)
+	w.String("user_id", m.UserId)
+	w.Int("expires_at", m.ExpiresAt)
+	w.Int("created_at", m.CreatedAt)
+}
+
+func (m *MagicLink) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("token_hash"); ok {
+		m.TokenHash = v
+	}
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.Int("expires_at"); ok {
+		m.ExpiresAt = v
+	}
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
+	}
+}
+
+type MagicLinkList []*MagicLink
+
+func (s *MagicLinkList) Schema() []model.Field            { return nil }
+func (s *MagicLinkList) Pointers() []any                  { return nil }
+func (s *MagicLinkList) Len() int                         { return len(*s) }
+func (s *MagicLinkList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *MagicLinkList) Append() model.Fielder            { v := &MagicLink{}; *s = append(*s, v); return v }
+func (s *MagicLinkList) IsNil() bool                      { return s == nil }
+func (s *MagicLinkList) EncodeFields(_ model.FieldWriter) {}
+func (s *MagicLinkList) DecodeFields(_ model.FieldReader) {}
+
+func (m *MagicLink) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var MagicLink_ = struct {
+	TokenHash string
+	UserId    string
+	ExpiresAt string
+	CreatedAt string
+}{
+	TokenHash: "token_hash",
+	UserId:    "user_id",
+	ExpiresAt: "expires_at",
+	CreatedAt: "created_at",
+}
+
+func ReadOneMagicLink(qb *orm.QB, model *MagicLink) (*MagicLink, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllMagicLink(qb *orm.QB) (MagicLinkList, error) {
+	var results MagicLinkList
+	err := qb.ReadAll(
+		func() model.Model { return &MagicLink{} },
+		func(m model.Model) { results = append(results, m.(*MagicLink)) },
+	)
+	return results, err
+}
+
+func (m *MagicLink) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: MagicLinkModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type LoginCode struct {
+	ClientHash string
+	UserId     string
+	CodeHash   string
+	Attempts   int64
+	Claim      string
+	Sends      int64
+	WindowAt   int64
+	ExpiresAt  int64
+	CreatedAt  int64
+}
+
+func (m *LoginCode) ModelName() string { return "login_code" }
+
+func (m *LoginCode) Schema() []model.Field { return LoginCodeModel.Fields }
+
+func (m *LoginCode) Pointers() []any {
+	return []any{&m.ClientHash, &m.UserId, &m.CodeHash, &m.Attempts, &m.Claim, &m.Sends, &m.WindowAt, &m.ExpiresAt, &m.CreatedAt}
+}
+
+func (m *LoginCode) IsNil() bool { return m == nil }
+
+func (m *LoginCode) EncodeFields(w model.FieldWriter) {
+	w.String("client_hash", m.ClientHash)
+	w.String("user_id", m.UserId)
+	w.String("code_hash", m.CodeHash)
+	w.Int("attempts", m.Attempts)
+	w.String("claim", m.Claim)
+	w.Int("sends", m.Sends)
+	w.Int("window_at", m.WindowAt)
+	w.Int("expires_at", m.ExpiresAt)
+	w.Int("created_at", m.CreatedAt)
+}
+
+func (m *LoginCode) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("client_hash"); ok {
+		m.ClientHash = v
+	}
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.String("code_hash"); ok {
+		m.CodeHash = v
+	}
+	if v, ok := r.Int("attempts"); ok {
+		m.Attempts = v
+	}
+	if v, ok := r.String("claim"); ok {
+		m.Claim = v
+	}
+	if v, ok := r.Int("sends"); ok {
+		m.Sends = v
+	}
+	if v, ok := r.Int("window_at"); ok {
+		m.WindowAt = v
+	}
+	if v, ok := r.Int("expires_at"); ok {
+		m.ExpiresAt = v
+	}
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
+	}
+}
+
+type LoginCodeList []*LoginCode
+
+func (s *LoginCodeList) Schema() []model.Field            { return nil }
+func (s *LoginCodeList) Pointers() []any                  { return nil }
+func (s *LoginCodeList) Len() int                         { return len(*s) }
+func (s *LoginCodeList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *LoginCodeList) Append() model.Fielder            { v := &LoginCode{}; *s = append(*s, v); return v }
+func (s *LoginCodeList) IsNil() bool                      { return s == nil }
+func (s *LoginCodeList) EncodeFields(_ model.FieldWriter) {}
+func (s *LoginCodeList) DecodeFields(_ model.FieldReader) {}
+
+func (m *LoginCode) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var LoginCode_ = struct {
+	ClientHash string
+	UserId     string
+	CodeHash   string
+	Attempts   string
+	Claim      string
+	Sends      string
+	WindowAt   string
+	ExpiresAt  string
+	CreatedAt  string
+}{
+	ClientHash: "client_hash",
+	UserId:     "user_id",
+	CodeHash:   "code_hash",
+	Attempts:   "attempts",
+	Claim:      "claim",
+	Sends:      "sends",
+	WindowAt:   "window_at",
+	ExpiresAt:  "expires_at",
+	CreatedAt:  "created_at",
+}
+
+func ReadOneLoginCode(qb *orm.QB, model *LoginCode) (*LoginCode, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllLoginCode(qb *orm.QB) (LoginCodeList, error) {
+	var results LoginCodeList
+	err := qb.ReadAll(
+		func() model.Model { return &LoginCode{} },
+		func(m model.Model) { results = append(results, m.(*LoginCode)) },
+	)
+	return results, err
+}
+
+func (m *LoginCode) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: LoginCodeModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type TOTP struct {
+	UserId    string
+	Secret    string
+	Confirmed int64
+	LastStep  int64
+	CreatedAt int64
+	Claim     string
+}
+
+func (m *TOTP) ModelName() string { return "totp" }
+
+func (m *TOTP) Schema() []model.Field { return TOTPModel.Fields }
+
+func (m *TOTP) Pointers() []any {
+	return []any{&m.UserId, &m.Secret, &m.Confirmed, &m.LastStep, &m.CreatedAt, &m.Claim}
+}
+
+func (m *TOTP) IsNil() bool { return m == nil }
+
+func (m *TOTP) EncodeFields(w model.FieldWriter) {
+	w.String("user_id", m.UserId)
+	w.String("secret", m.Secret)
+	w.Int("confirmed", m.Confirmed)
+	w.Int("last_step", m.LastStep)
+	w.Int("created_at", m.CreatedAt)
+	w.String("claim", m.Claim)
+}
+
+func (m *TOTP) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.String("secret"); ok {
+		m.Secret = v
+	}
+	if v, ok := r.Int("confirmed"); ok {
+		m.Confirmed = v
+	}
+	if v, ok := r.Int("last_step"); ok {
+		m.LastStep = v
+	}
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
+	}
+	if v, ok := r.String("claim"); ok {
+		m.Claim = v
+	}
+}
+
+type TOTPList []*TOTP
+
+func (s *TOTPList) Schema() []model.Field            { return nil }
+func (s *TOTPList) Pointers() []any                  { return nil }
+func (s *TOTPList) Len() int                         { return len(*s) }
+func (s *TOTPList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *TOTPList) Append() model.Fielder            { v := &TOTP{}; *s = append(*s, v); return v }
+func (s *TOTPList) IsNil() bool                      { return s == nil }
+func (s *TOTPList) EncodeFields(_ model.FieldWriter) {}
+func (s *TOTPList) DecodeFields(_ model.FieldReader) {}
+
+func (m *TOTP) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var TOTP_ = struct {
+	UserId    string
+	Secret    string
+	Confirmed string
+	LastStep  string
+	CreatedAt string
+	Claim     string
+}{
+	UserId:    "user_id",
+	Secret:    "secret",
+	Confirmed: "confirmed",
+	LastStep:  "last_step",
+	CreatedAt: "created_at",
+	Claim:     "claim",
+}
+
+func ReadOneTOTP(qb *orm.QB, model *TOTP) (*TOTP, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllTOTP(qb *orm.QB) (TOTPList, error) {
+	var results TOTPList
+	err := qb.ReadAll(
+		func() model.Model { return &TOTP{} },
+		func(m model.Model) { results = append(results, m.(*TOTP)) },
+	)
+	return results, err
+}
+
+func (m *TOTP) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: TOTPModel.Fields[0], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type RecoveryCode struct {
+	CodeHash  string
+	UserId    string
+	CreatedAt int64
+}
+
+func (m *RecoveryCode) ModelName() string { return "recovery_code" }
+
+func (m *RecoveryCode) Schema() []model.Field { return RecoveryCodeModel.Fields }
+
+func (m *RecoveryCode) Pointers() []any { return []any{&m.CodeHash, &m.UserId, &m.CreatedAt} }
+
+func (m *RecoveryCode) IsNil() bool { return m == nil }
+
+func (m *RecoveryCode) EncodeFields(w model.FieldWriter) {
+	w.String("code_hash", m.CodeHash)
+	w.String("user_id", m.UserId)
+	w.Int("created_at", m.CreatedAt)
+}
+
+func (m *RecoveryCode) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("code_hash"); ok {
+		m.CodeHash = v
+	}
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
+	}
+}
+
+type RecoveryCodeList []*RecoveryCode
+
+func (s *RecoveryCodeList) Schema() []model.Field            { return nil }
+func (s *RecoveryCodeList) Pointers() []any                  { return nil }
+func (s *RecoveryCodeList) Len() int                         { return len(*s) }
+func (s *RecoveryCodeList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *RecoveryCodeList) Append() model.Fielder            { v := &RecoveryCode{}; *s = append(*s, v); return v }
+func (s *RecoveryCodeList) IsNil() bool                      { return s == nil }
+func (s *RecoveryCodeList) EncodeFields(_ model.FieldWriter) {}
+func (s *RecoveryCodeList) DecodeFields(_ model.FieldReader) {}
+
+func (m *RecoveryCode) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var RecoveryCode_ = struct {
+	CodeHash  string
+	UserId    string
+	CreatedAt string
+}{
+	CodeHash:  "code_hash",
+	UserId:    "user_id",
+	CreatedAt: "created_at",
+}
+
+func ReadOneRecoveryCode(qb *orm.QB, model *RecoveryCode) (*RecoveryCode, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllRecoveryCode(qb *orm.QB) (RecoveryCodeList, error) {
+	var results RecoveryCodeList
+	err := qb.ReadAll(
+		func() model.Model { return &RecoveryCode{} },
+		func(m model.Model) { results = append(results, m.(*RecoveryCode)) },
+	)
+	return results, err
+}
+
+func (m *RecoveryCode) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: RecoveryCodeModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type WebAuthnCredential struct {
+	Id        string
+	UserId    string
+	PublicKey string
+	SignCount int64
+	CreatedAt int64
+}
+
+func (m *WebAuthnCredential) ModelName() string { return "webauthn_credential" }
+
+func (m *WebAuthnCredential) Schema() []model.Field { return WebAuthnCredentialModel.Fields }
+
+func (m *WebAuthnCredential) Pointers() []any {
+	return []any{&m.Id, &m.UserId, &m.PublicKey, &m.SignCount, &m.CreatedAt}
+}
+
+func (m *WebAuthnCredential) IsNil() bool { return m == nil }
+
+func (m *WebAuthnCredential) EncodeFields(w model.FieldWriter) {
+	w.String("id", m.Id)
+	w.String("user_id", m.UserId)
+	w.String("public_key", m.PublicKey)
+	w.Int("sign_count", m.SignCount)
+	w.Int("created_at", m.CreatedAt)
+}
+
+func (m *WebAuthnCredential) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("id"); ok {
+		m.Id = v
+	}
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.String("public_key"); ok {
+		m.PublicKey = v
+	}
+	if v, ok := r.Int("sign_count"); ok {
+		m.SignCount = v
+	}
+	if v, ok := r.Int("created_at"); ok {
+		m.CreatedAt = v
+	}
+}
+
+type WebAuthnCredentialList []*WebAuthnCredential
+
+func (s *WebAuthnCredentialList) Schema() []model.Field  { return nil }
+func (s *WebAuthnCredentialList) Pointers() []any        { return nil }
+func (s *WebAuthnCredentialList) Len() int               { return len(*s) }
+func (s *WebAuthnCredentialList) At(i int) model.Fielder { return (*s)[i] }
+func (s *WebAuthnCredentialList) Append() model.Fielder {
+	v := &WebAuthnCredential{}
+	*s = append(*s, v)
+	return v
+}
+func (s *WebAuthnCredentialList) IsNil() bool                      { return s == nil }
+func (s *WebAuthnCredentialList) EncodeFields(_ model.FieldWriter) {}
+func (s *WebAuthnCredentialList) DecodeFields(_ model.FieldReader) {}
+
+func (m *WebAuthnCredential) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var WebAuthnCredential_ = struct {
+	Id        string
+	UserId    string
+	PublicKey string
+	SignCount string
+	CreatedAt string
+}{
+	Id:        "id",
+	UserId:    "user_id",
+	PublicKey: "public_key",
+	SignCount: "sign_count",
+	CreatedAt: "created_at",
+}
+
+func ReadOneWebAuthnCredential(qb *orm.QB, model *WebAuthnCredential) (*WebAuthnCredential, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllWebAuthnCredential(qb *orm.QB) (WebAuthnCredentialList, error) {
+	var results WebAuthnCredentialList
+	err := qb.ReadAll(
+		func() model.Model { return &WebAuthnCredential{} },
+		func(m model.Model) { results = append(results, m.(*WebAuthnCredential)) },
+	)
+	return results, err
+}
+
+func (m *WebAuthnCredential) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: WebAuthnCredentialModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type WebAuthnChallenge struct {
+	ChallengeHash string
+	UserId        string
+	Purpose       string
+	ExpiresAt     int64
+}
+
+func (m *WebAuthnChallenge) ModelName() string { return "webauthn_challenge" }
+
+func (m *WebAuthnChallenge) Schema() []model.Field { return WebAuthnChallengeModel.Fields }
+
+func (m *WebAuthnChallenge) Pointers() []any {
+	return []any{&m.ChallengeHash, &m.UserId, &m.Purpose, &m.ExpiresAt}
+}
+
+func (m *WebAuthnChallenge) IsNil() bool { return m == nil }
+
+func (m *WebAuthnChallenge) EncodeFields(w model.FieldWriter) {
+	w.String("challenge_hash", m.ChallengeHash)
+	w.String("user_id", m.UserId)
+	w.String("purpose", m.Purpose)
+	w.Int("expires_at", m.ExpiresAt)
+}
+
+func (m *WebAuthnChallenge) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("challenge_hash"); ok {
+		m.ChallengeHash = v
+	}
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.String("purpose"); ok {
+		m.Purpose = v
+	}
+	if v, ok := r.Int("expires_at"); ok {
+		m.ExpiresAt = v
+	}
+}
+
+type WebAuthnChallengeList []*WebAuthnChallenge
+
+func (s *WebAuthnChallengeList) Schema() []model.Field  { return nil }
+func (s *WebAuthnChallengeList) Pointers() []any        { return nil }
+func (s *WebAuthnChallengeList) Len() int               { return len(*s) }
+func (s *WebAuthnChallengeList) At(i int) model.Fielder { return (*s)[i] }
+func (s *WebAuthnChallengeList) Append() model.Fielder {
+	v := &WebAuthnChallenge{}
+	*s = append(*s, v)
+	return v
+}
+func (s *WebAuthnChallengeList) IsNil() bool                      { return s == nil }
+func (s *WebAuthnChallengeList) EncodeFields(_ model.FieldWriter) {}
+func (s *WebAuthnChallengeList) DecodeFields(_ model.FieldReader) {}
+
+func (m *WebAuthnChallenge) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var WebAuthnChallenge_ = struct {
+	ChallengeHash string
+	UserId        string
+	Purpose       string
+	ExpiresAt     string
+}{
+	ChallengeHash: "challenge_hash",
+	UserId:        "user_id",
+	Purpose:       "purpose",
+	ExpiresAt:     "expires_at",
+}
+
+func ReadOneWebAuthnChallenge(qb *orm.QB, model *WebAuthnChallenge) (*WebAuthnChallenge, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllWebAuthnChallenge(qb *orm.QB) (WebAuthnChallengeList, error) {
+	var results WebAuthnChallengeList
+	err := qb.ReadAll(
+		func() model.Model { return &WebAuthnChallenge{} },
+		func(m model.Model) { results = append(results, m.(*WebAuthnChallenge)) },
+	)
+	return results, err
+}
+
+type ProviderToken struct {
+	IdentityId   string
+	UserId       string
+	Provider     string
+	AccessToken  string
+	RefreshToken string
+	Expiry       int64
+	UpdatedAt    int64
+}
+
+func (m *ProviderToken) ModelName() string { return "provider_token" }
+
+func (m *ProviderToken) Schema() []model.Field { return ProviderTokenModel.Fields }
+
+func (m *ProviderToken) Pointers() []any {
+	return []any{&m.IdentityId, &m.UserId, &m.Provider, &m.AccessToken, &m.RefreshToken, &m.Expiry, &m.UpdatedAt}
+}
+
+func (m *ProviderToken) IsNil() bool { return m == nil }
+
+func (m *ProviderToken) EncodeFields(w model.FieldWriter) {
+	w.String("identity_id", m.IdentityId)
+	w.String("user_id", m.UserId)
+	w.String("provider", m.Provider)
+	w.String("access_token", m.AccessToken)
+	w.String("refresh_token", m.RefreshToken)
+	w.Int("expiry", m.Expiry)
+	w.Int("updated_at", m.UpdatedAt)
+}
+
+func (m *ProviderToken) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("identity_id"); ok {
+		m.IdentityId = v
+	}
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.String("provider"); ok {
+		m.Provider = v
+	}
+	if v, ok := r.String("access_token"); ok {
+		m.AccessToken = v
+	}
+	if v, ok := r.String("refresh_token"); ok {
+		m.RefreshToken = v
+	}
+	if v, ok := r.Int("expiry"); ok {
+		m.Expiry = v
+	}
+	if v, ok := r.Int("updated_at"); ok {
+		m.UpdatedAt = v
+	}
+}
+
+type ProviderTokenList []*ProviderToken
+
+func (s *ProviderTokenList) Schema() []model.Field  { return nil }
+func (s *ProviderTokenList) Pointers() []any        { return nil }
+func (s *ProviderTokenList) Len() int               { return len(*s) }
+func (s *ProviderTokenList) At(i int) model.Fielder { return (*s)[i] }
+func (s *ProviderTokenList) Append() model.Fielder {
+	v := &ProviderToken{}
+	*s = append(*s, v)
+	return v
+}
+func (s *ProviderTokenList) IsNil() bool                      { return s == nil }
+func (s *ProviderTokenList) EncodeFields(_ model.FieldWriter) {}
+func (s *ProviderTokenList) DecodeFields(_ model.FieldReader) {}
+
+func (m *ProviderToken) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+var ProviderToken_ = struct {
+	IdentityId   string
+	UserId       string
+	Provider     string
+	AccessToken  string
+	RefreshToken string
+	Expiry       string
+	UpdatedAt    string
+}{
+	IdentityId:   "identity_id",
+	UserId:       "user_id",
+	Provider:     "provider",
+	AccessToken:  "access_token",
+	RefreshToken: "refresh_token",
+	Expiry:       "expiry",
+	UpdatedAt:    "updated_at",
+}
+
+func ReadOneProviderToken(qb *orm.QB, model *ProviderToken) (*ProviderToken, error) {
+	err := qb.ReadOne()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func ReadAllProviderToken(qb *orm.QB) (ProviderTokenList, error) {
+	var results ProviderTokenList
+	err := qb.ReadAll(
+		func() model.Model { return &ProviderToken{} },
+		func(m model.Model) { results = append(results, m.(*ProviderToken)) },
+	)
+	return results, err
+}
+
+func (m *ProviderToken) SchemaExt() []model.FieldExt {
+	return []model.FieldExt{
+		{Field: ProviderTokenModel.Fields[1], Ref: "user", RefColumn: "id", OnDelete: ""},
+	}
+}
+
+type LoginData struct {
+	Email    string
+	Password string
+	Remember string
+}
+
+func (m *LoginData) ModelName() string { return "login_data" }
+
+func (m *LoginData) Schema() []model.Field { return LoginDataModel.Fields }
+
+func (m *LoginData) Pointers() []any { return []any{&m.Email, &m.Password, &m.Remember} }
+
+func (m *LoginData) IsNil() bool { return m == nil }
+
+func (m *LoginData) EncodeFields(w model.FieldWriter) {
+	w.String("email", m.Email)
+	w.String("password", m.Password)
+	w.String("remember", m.Remember)
+}
+
+func (m *LoginData) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("email"); ok {
+		m.Email = v
+	}
+	if v, ok := r.String("password"); ok {
+		m.Password = v
+	}
+	if v, ok := r.String("remember"); ok {
+		m.Remember = v
+	}
+}
+
+type LoginDataList []*LoginData
+
+func (s *LoginDataList) Schema() []model.Field            { return nil }
+func (s *LoginDataList) Pointers() []any                  { return nil }
+func (s *LoginDataList) Len() int                         { return len(*s) }
+func (s *LoginDataList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *LoginDataList) Append() model.Fielder            { v := &LoginData{}; *s = append(*s, v); return v }
+func (s *LoginDataList) IsNil() bool                      { return s == nil }
+func (s *LoginDataList) EncodeFields(_ model.FieldWriter) {}
+func (s *LoginDataList) DecodeFields(_ model.FieldReader) {}
+
+func (m *LoginData) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+type RegisterData struct {
+	Name     string
+	Email    string
+	Password string
+	Phone    string
+}
+
+func (m *RegisterData) ModelName() string { return "register_data" }
+
+func (m *RegisterData) Schema() []model.Field { return RegisterDataModel.Fields }
+
+func (m *RegisterData) Pointers() []any { return []any{&m.Name, &m.Email, &m.Password, &m.Phone} }
+
+func (m *RegisterData) IsNil() bool { return m == nil }
+
+func (m *RegisterData) EncodeFields(w model.FieldWriter) {
+	w.String("name", m.Name)
+	w.String("email", m.Email)
+	w.String("password", m.Password)
+	w.String("phone", m.Phone)
+}
+
+func (m *RegisterData) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("name"); ok {
+		m.Name = v
+	}
+	if v, ok := r.String("email"); ok {
+		m.Email = v
+	}
+	if v, ok := r.String("password"); ok {
+		m.Password = v
+	}
+	if v, ok := r.String("phone"); ok {
+		m.Phone = v
+	}
+}
+
+type RegisterDataList []*RegisterData
+
+func (s *RegisterDataList) Schema() []model.Field            { return nil }
+func (s *RegisterDataList) Pointers() []any                  { return nil }
+func (s *RegisterDataList) Len() int                         { return len(*s) }
+func (s *RegisterDataList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *RegisterDataList) Append() model.Fielder            { v := &RegisterData{}; *s = append(*s, v); return v }
+func (s *RegisterDataList) IsNil() bool                      { return s == nil }
+func (s *RegisterDataList) EncodeFields(_ model.FieldWriter) {}
+func (s *RegisterDataList) DecodeFields(_ model.FieldReader) {}
+
+func (m *RegisterData) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+type ProfileData struct {
+	Name  string
+	Phone string
+}
+
+func (m *ProfileData) ModelName() string { return "profile_data" }
+
+func (m *ProfileData) Schema() []model.Field { return ProfileDataModel.Fields }
+
+func (m *ProfileData) Pointers() []any { return []any{&m.Name, &m.Phone} }
+
+func (m *ProfileData) IsNil() bool { return m == nil }
+
+func (m *ProfileData) EncodeFields(w model.FieldWriter) {
+	w.String("name", m.Name)
+	w.String("phone", m.Phone)
+}
+
+func (m *ProfileData) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("name"); ok {
+		m.Name = v
+	}
+	if v, ok := r.String("phone"); ok {
+		m.Phone = v
+	}
+}
+
+type ProfileDataList []*ProfileData
+
+func (s *ProfileDataList) Schema() []model.Field            { return nil }
+func (s *ProfileDataList) Pointers() []any                  { return nil }
+func (s *ProfileDataList) Len() int                         { return len(*s) }
+func (s *ProfileDataList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *ProfileDataList) Append() model.Fielder            { v := &ProfileData{}; *s = append(*s, v); return v }
+func (s *ProfileDataList) IsNil() bool                      { return s == nil }
+func (s *ProfileDataList) EncodeFields(_ model.FieldWriter) {}
+func (s *ProfileDataList) DecodeFields(_ model.FieldReader) {}
+
+func (m *ProfileData) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+type PasswordData struct {
+	Current string
+	New     string
+	Confirm string
+}
+
+func (m *PasswordData) ModelName() string { return "password_data" }
+
+func (m *PasswordData) Schema() []model.Field { return PasswordDataModel.Fields }
+
+func (m *PasswordData) Pointers() []any { return []any{&m.Current, &m.New, &m.Confirm} }
+
+func (m *PasswordData) IsNil() bool { return m == nil }
+
+func (m *PasswordData) EncodeFields(w model.FieldWriter) {
+	w.String("current", m.Current)
+	w.String("new", m.New)
+	w.String("confirm", m.Confirm)
+}
+
+func (m *PasswordData) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("current"); ok {
+		m.Current = v
+	}
+	if v, ok := r.String("new"); ok {
+		m.New = v
+	}
+	if v, ok := r.String("confirm"); ok {
+		m.Confirm = v
+	}
+}
+
+type PasswordDataList []*PasswordData
+
+func (s *PasswordDataList) Schema() []model.Field            { return nil }
+func (s *PasswordDataList) Pointers() []any                  { return nil }
+func (s *PasswordDataList) Len() int                         { return len(*s) }
+func (s *PasswordDataList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *PasswordDataList) Append() model.Fielder            { v := &PasswordData{}; *s = append(*s, v); return v }
+func (s *PasswordDataList) IsNil() bool                      { return s == nil }
+func (s *PasswordDataList) EncodeFields(_ model.FieldWriter) {}
+func (s *PasswordDataList) DecodeFields(_ model.FieldReader) {}
+
+func (m *PasswordData) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+type TemporaryPasswordData struct {
+	UserId   string
+	Password string
+}
+
+func (m *TemporaryPasswordData) ModelName() string { return "temporary_password_data" }
+
+func (m *TemporaryPasswordData) Schema() []model.Field { return TemporaryPasswordDataModel.Fields }
+
+func (m *TemporaryPasswordData) Pointers() []any { return []any{&m.UserId, &m.Password} }
+
+func (m *TemporaryPasswordData) IsNil() bool { return m == nil }
+
+func (m *TemporaryPasswordData) EncodeFields(w model.FieldWriter) {
+	w.String("user_id", m.UserId)
+	w.String("password", m.Password)
+}
+
+func (m *TemporaryPasswordData) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("user_id"); ok {
+		m.UserId = v
+	}
+	if v, ok := r.String("password"); ok {
+		m.Password = v
+	}
+}
+
+type TemporaryPasswordDataList []*TemporaryPasswordData
+
+func (s *TemporaryPasswordDataList) Schema() []model.Field  { return nil }
+func (s *TemporaryPasswordDataList) Pointers() []any        { return nil }
+func (s *TemporaryPasswordDataList) Len() int               { return len(*s) }
+func (s *TemporaryPasswordDataList) At(i int) model.Fielder { return (*s)[i] }
+func (s *TemporaryPasswordDataList) Append() model.Fielder {
+	v := &TemporaryPasswordData{}
+	*s = append(*s, v)
+	return v
+}
+func (s *TemporaryPasswordDataList) IsNil() bool                      { return s == nil }
+func (s *TemporaryPasswordDataList) EncodeFields(_ model.FieldWriter) {}
+func (s *TemporaryPasswordDataList) DecodeFields(_ model.FieldReader) {}
+
+func (m *TemporaryPasswordData) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+type ForgotData struct {
+	Email string
+}
+
+func (m *ForgotData) ModelName() string { return "forgot_data" }
+
+func (m *ForgotData) Schema() []model.Field { return ForgotDataModel.Fields }
+
+func (m *ForgotData) Pointers() []any { return []any{&m.Email} }
+
+func (m *ForgotData) IsNil() bool { return m == nil }
+
+func (m *ForgotData) EncodeFields(w model.FieldWriter) {
+	w.String("email", m.Email)
+}
+
+func (m *ForgotData) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("email"); ok {
+		m.Email = v
+	}
+}
+
+type ForgotDataList []*ForgotData
+
+func (s *ForgotDataList) Schema() []model.Field            { return nil }
+func (s *ForgotDataList) Pointers() []any                  { return nil }
+func (s *ForgotDataList) Len() int                         { return len(*s) }
+func (s *ForgotDataList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *ForgotDataList) Append() model.Fielder            { v := &ForgotData{}; *s = append(*s, v); return v }
+func (s *ForgotDataList) IsNil() bool                      { return s == nil }
+func (s *ForgotDataList) EncodeFields(_ model.FieldWriter) {}
+func (s *ForgotDataList) DecodeFields(_ model.FieldReader) {}
+
+func (m *ForgotData) Validate(action byte) error {
+	return model.ValidateFields(action, m)
+}
+
+type ResetData struct {
+	Token    string
+	Password string
+}
+
+func (m *ResetData) ModelName() string { return "reset_data" }
+
+func (m *ResetData) Schema() []model.Field { return ResetDataModel.Fields }
+
+func (m *ResetData) Pointers() []any { return []any{&m.Token, &m.Password} }
+
+func (m *ResetData) IsNil() bool { return m == nil }
+
+func (m *ResetData) EncodeFields(w model.FieldWriter) {
+	w.String("token", m.Token)
+	w.String("password", m.Password)
+}
+
+func (m *ResetData) DecodeFields(r model.FieldReader) {
+	if v, ok := r.String("token"); ok {
+		m.Token = v
+	}
+	if v, ok := r.String("password"); ok {
+		m.Password = v
+	}
+}
+
+type ResetDataList []*ResetData
+
+func (s *ResetDataList) Schema() []model.Field            { return nil }
+func (s *ResetDataList) Pointers() []any                  { return nil }
+func (s *ResetDataList) Len() int                         { return len(*s) }
+func (s *ResetDataList) At(i int) model.Fielder           { return (*s)[i] }
+func (s *ResetDataList) Append() model.Fielder            { v := &ResetData{}; *s = append(*s, v); return v }
+func (s *ResetDataList) IsNil() bool                      { return s == nil }
+func (s *ResetDataList) EncodeFields(_ model.FieldWriter) {}
+func (s *ResetDataList) DecodeFields(_ model.FieldReader) {}
+
+func (m *ResetData) Validate(action byte) error {
 	return model.ValidateFields(action, m)
 }
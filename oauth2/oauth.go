@@ -1,25 +1,120 @@
 package oauth2
 
 import (
+	"sync"
+
 	"github.com/tinywasm/fmt"
 	"github.com/tinywasm/router"
+	"github.com/tinywasm/time"
 	"github.com/tinywasm/user"
 )
 
+// LinkCookie ties a link flow to the browser that began it. It is SameSite=Lax,
+// so unlike the session cookie it rides along on the IdP's redirect back.
+const LinkCookie = "oauth_link"
+
 type Authenticator struct {
 	store      user.IdentityStore
 	states     user.StateStore
 	sessions   user.SessionIssuer
 	providers  []user.OAuthProvider
 	afterLogin string
+	limiter    user.RateLimiter
+	notify     user.SecurityNotifier
+	trustProxy bool
+	noPKCE     bool
+	afterLink  string
+	linkPolicy LinkPolicy
+
+	noProvision bool
+	domains     []string
+	tenants     []string
+	roles       user.RoleAssigner
+	defaultRole string
+
+	tokens     user.ProviderTokenStore
+	refreshMu  sync.Mutex
+	refreshing map[string]*refreshLock // by userID + provider
+
+	nextOrigins []string
 }
 
 type Option func(*Authenticator)
 
+// LinkPolicy decides whether a first login through a provider may attach to
+// an existing account that has the same email.
+type LinkPolicy uint8
+
+const (
+	// LinkVerified links only when the provider reports the email verified
+	// (OAuthUserInfo.EmailVerified). The default.
+	LinkVerified LinkPolicy = iota
+	// LinkNever never links by email: the user signs in the way they already
+	// can and adds the provider through /oauth/link/{provider}.
+	LinkNever
+	// LinkAlways trusts every provider's email, as before. Only for IdPs the
+	// app controls.
+	LinkAlways
+)
+
 func WithAfterLogin(path string) Option { return func(a *Authenticator) { a.afterLogin = path } }
 
-func New(store user.IdentityStore, states user.StateStore, sessions user.SessionIssuer, providers []user.OAuthProvider, opts ...Option) *Authenticator {
-	a := &Authenticator{store: store, states: states, sessions: sessions, providers: providers}
+// WithLinkPolicy sets when a login may join an existing account by email. A
+// refused link answers 409 account_exists rather than merging.
+func WithLinkPolicy(p LinkPolicy) Option { return func(a *Authenticator) { a.linkPolicy = p } }
+
+// WithProvisioning(false) stops creating accounts: a provider login must match
+// an existing identity or (per the link policy) email. On by default.
+func WithProvisioning(v bool) Option { return func(a *Authenticator) { a.noProvision = !v } }
+
+// WithAllowedDomains creates accounts only for verified emails in one of
+// domains, e.g. "acme.com" (subdomains are not included).
+func WithAllowedDomains(domains ...string) Option {
+	return func(a *Authenticator) { a.domains = domains }
+}
+
+// WithAllowedTenants creates accounts only for logins whose
+// OAuthUserInfo.Tenant is one of tenants: a Google Workspace domain or an
+// Entra ID tenant id. Set together with WithAllowedDomains, both must match.
+func WithAllowedTenants(tenants ...string) Option {
+	return func(a *Authenticator) { a.tenants = tenants }
+}
+
+// WithDefaultRole assigns roleID to every account this mode creates. When that
+// fails the login answers 500 and, if the IdentityStore is a user.UserRemover,
+// the new account is deleted again.
+func WithDefaultRole(roles user.RoleAssigner, roleID string) Option {
+	return func(a *Authenticator) { a.roles, a.defaultRole = roles, roleID }
+}
+
+// WithTokenStore keeps each login's provider tokens (authority.Module, with
+// Config.TokenKey set) for TokenFor.
+func WithTokenStore(store user.ProviderTokenStore) Option {
+	return func(a *Authenticator) { a.tokens = store }
+}
+
+// WithNextOrigins lets ?next= on /oauth/{provider} name an absolute URL on one
+// of origins (e.g. "https://admin.example.com"), besides a path on this one.
+func WithNextOrigins(origins ...string) Option {
+	return func(a *Authenticator) { a.nextOrigins = origins }
+}
+
+// WithAfterLink is where /oauth/link/{provider} ends up once the provider is
+// linked. Default: the after-login path.
+func WithAfterLink(path string) Option { return func(a *Authenticator) { a.afterLink = path } }
+
+// WithRateLimiter limits the begin and callback routes per client IP (e.g.
+// ratelimit.New).
+func WithRateLimiter(l user.RateLimiter) Option { return func(a *Authenticator) { a.limiter = l } }
+
+func WithTrustProxy(v bool) Option { return func(a *Authenticator) { a.trustProxy = v } }
+
+// WithPKCE(false) stops sending an S256 code_challenge, for the rare IdP that
+// rejects one. On by default.
+func WithPKCE(v bool) Option { return func(a *Authenticator) { a.noPKCE = !v } }
+
+func New(store user.IdentityStore, states user.StateStore, sessions user.SessionIssuer, notify user.SecurityNotifier, providers []user.OAuthProvider, opts ...Option) *Authenticator {
+	a := &Authenticator{store: store, states: states, sessions: sessions, notify: notify, providers: providers}
 	for _, opt := range opts {
 		opt(a)
 	}
@@ -37,62 +132,90 @@ func (a *Authenticator) provider(name string) user.OAuthProvider {
 	return nil
 }
 
+func (a *Authenticator) report(e user.SecurityEvent) {
+	e.Mode = a.Name()
+	a.notify.Notify(e)
+}
+
+// limited answers 429 when the limiter rejects ctx's client IP.
+func (a *Authenticator) limited(ctx router.Context, provider string) bool {
+	ip := user.ClientIP(ctx, a.trustProxy)
+	if user.Allowed(a.limiter, ip, "") {
+		return false
+	}
+	a.report(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, Provider: provider})
+	user.RespondError(ctx, 429, user.CodeRateLimited, user.ErrRateLimited)
+	return true
+}
+
 func (a *Authenticator) Mount(r router.Router) {
 	afterLogin := a.afterLogin
 	if afterLogin == "" {
 		afterLogin = user.PathAfterLogin
 	}
+	afterLink := a.afterLink
+	if afterLink == "" {
+		afterLink = afterLogin
+	}
 
 	for _, p := range a.providers {
 		providerName := p.Name()
 
 		r.Get("/oauth/"+providerName, func(ctx router.Context) {
-			state, err := a.states.CreateState(providerName)
-			if err != nil {
-				ctx.WriteStatus(500)
+			if a.limited(ctx, providerName) {
 				return
 			}
-			ctx.SetHeader("Location", p.AuthCodeURL(state))
-			ctx.WriteStatus(302)
+			a.begin(ctx, p, "")
 		}).Public()
 
+		// The link route sends a signed-in user through the same flow; the
+		// callback then adds the provider to their account instead of logging in.
+		r.Get("/oauth/link/"+providerName, func(ctx router.Context) {
+			if a.limited(ctx, providerName) {
+				return
+			}
+			userID := caller(ctx)
+			if userID == "" {
+				ctx.WriteStatus(401)
+				return
+			}
+			a.begin(ctx, p, userID)
+		}).Authenticated()
+
 		r.Get("/oauth/callback/"+providerName, func(ctx router.Context) {
-			var state, code string
-			path := ctx.Path()
-			if fmt.Contains(path, "?") {
-				query := fmt.Split(path, "?")[1]
-				for _, part := range fmt.Split(query, "&") {
-					kv := fmt.Split(part, "=")
-					if len(kv) == 2 {
-						if kv[0] == "state" {
-							state = kv[1]
-						} else if kv[0] == "code" {
-							code = kv[1]
-						}
-					}
-				}
+			if a.limited(ctx, providerName) {
+				return
 			}
+			state := user.QueryParam(ctx, "state")
+			code := user.QueryParam(ctx, "code")
 
-			if err := a.states.ConsumeState(state, providerName); err != nil {
-				ctx.WriteStatus(401)
-				ctx.Write([]byte(user.ErrInvalidOAuthState.Error()))
+			s, err := a.states.ConsumeState(state, providerName)
+			if err != nil {
+				user.RespondError(ctx, 401, user.CodeInvalidState, user.ErrInvalidOAuthState)
 				return
 			}
 			prov := a.provider(providerName)
 			if prov == nil {
-				ctx.WriteStatus(500)
+				user.RespondError(ctx, 500, user.CodeServerError, nil)
 				return
 			}
-			token, err := prov.ExchangeCode(code)
+			token, err := prov.ExchangeCode(code, s.CodeVerifier)
 			if err != nil {
-				ctx.WriteStatus(401)
-				ctx.Write([]byte(err.Error()))
+				user.RespondError(ctx, 401, user.CodeInvalidCredentials, err)
 				return
 			}
-			info, err := prov.GetUserInfo(token)
+			var info user.OAuthUserInfo
+			if op, ok := prov.(user.OIDCProvider); ok {
+				info, err = op.VerifiedUserInfo(token, s.Nonce)
+			} else {
+				info, err = prov.GetUserInfo(token)
+			}
 			if err != nil {
-				ctx.WriteStatus(401)
-				ctx.Write([]byte(err.Error()))
+				user.RespondError(ctx, 401, user.CodeInvalidCredentials, err)
+				return
+			}
+			if s.LinkUserId != "" {
+				a.link(ctx, state, s, info, token, afterLink)
 				return
 			}
 
@@ -100,22 +223,56 @@ func (a *Authenticator) Mount(r router.Router) {
 			if identity, err := a.store.IdentityByProvider(providerName, info.ID); err == nil {
 				u, err = a.store.UserByID(identity.UserId)
 				if err != nil {
-					ctx.WriteStatus(500)
+					user.RespondError(ctx, 500, user.CodeServerError, nil)
+					return
+				}
+				if a.inactive(ctx, u) {
+					return
+				}
+			} else if existing, err := a.store.UserByEmail(info.Email); info.Email != "" && err == nil {
+				if !a.mayLink(info) {
+					user.RespondError(ctx, 409, user.CodeAccountExists, user.ErrEmailTaken)
+					return
+				}
+				// A pending sign-up is never joined as it stands: nobody proved
+				// its email, so it may be a squatter's, waiting for its victim.
+				// A provider-verified email claims it and drops its password.
+				if existing.Status == "pending" && info.EmailVerified {
+					if pa, ok := a.store.(user.PendingAccounts); ok && pa.ClaimPending(existing.Id) == nil {
+						existing.Status = "active"
+					}
+				}
+				if a.inactive(ctx, existing) {
 					return
 				}
-			} else if existing, err := a.store.UserByEmail(info.Email); err == nil {
 				u = existing
 				_ = a.store.UpsertIdentity(u.Id, providerName, info.ID, info.Email)
 			} else {
+				if !a.mayProvision(info) {
+					a.report(user.SecurityEvent{Type: user.EventProvisionDenied, IP: user.ClientIP(ctx, a.trustProxy), UserID: info.Email, Provider: providerName})
+					user.RespondError(ctx, 403, user.CodeSignupClosed, user.ErrSignupClosed)
+					return
+				}
 				created, err := a.store.CreateUser(info.Email, info.Name, "")
 				if err != nil {
-					ctx.WriteStatus(500)
+					user.RespondError(ctx, 500, user.CodeServerError, nil)
 					return
 				}
+				if a.roles != nil {
+					if err := a.roles.AssignRole(created.Id, a.defaultRole); err != nil {
+						if d, ok := a.store.(user.UserRemover); ok {
+							_ = d.DeleteUser(created.Id)
+						}
+						user.RespondError(ctx, 500, user.CodeServerError, nil)
+						return
+					}
+				}
 				u = created
 				_ = a.store.UpsertIdentity(u.Id, providerName, info.ID, info.Email)
 			}
 
+			a.keepToken(u.Id, providerName, token)
+
 			if info.Avatar != "" {
 				if err := a.store.UpdateUserAvatar(u.Id, info.Avatar); err == nil {
 					u.Avatar = info.Avatar
@@ -123,13 +280,235 @@ func (a *Authenticator) Mount(r router.Router) {
 			}
 
 			if err := a.sessions.IssueSession(ctx, u.Id); err != nil {
-				ctx.WriteStatus(500)
+				user.RespondIssueErrorNext(ctx, err, s.Next)
 				return
 			}
-			ctx.SetHeader("Location", afterLogin)
-			ctx.WriteStatus(302)
+			user.RespondLogin(ctx, or(s.Next, afterLogin), a.store, u)
 		}).Public()
 	}
 }
 
+// inactive refuses an account that can't sign in: pending ones with 403
+// email_unverified, any other non-active status with 401 suspended.
+func (a *Authenticator) inactive(ctx router.Context, u user.User) bool {
+	switch u.Status {
+	case "active":
+		return false
+	case "pending":
+		user.RespondError(ctx, 403, user.CodeEmailUnverified, user.ErrEmailUnverified)
+	default:
+		a.report(user.SecurityEvent{Type: user.EventNonActiveAccess, IP: user.ClientIP(ctx, a.trustProxy), UserID: u.Id})
+		user.RespondError(ctx, 401, user.CodeSuspended, nil)
+	}
+	return true
+}
+
+func (a *Authenticator) mayLink(info user.OAuthUserInfo) bool {
+	switch a.linkPolicy {
+	case LinkAlways:
+		return true
+	case LinkVerified:
+		return info.EmailVerified
+	}
+	return false
+}
+
+// mayProvision applies WithProvisioning, WithAllowedDomains and
+// WithAllowedTenants to a login that matched no account. A domain only counts
+// when the provider verified the email.
+func (a *Authenticator) mayProvision(info user.OAuthUserInfo) bool {
+	if a.noProvision {
+		return false
+	}
+	if len(a.domains) > 0 {
+		email := fmt.Convert(info.Email).ToLower().String()
+		domain := email[fmt.LastIndex(email, "@")+1:]
+		if !info.EmailVerified || !contains(a.domains, domain) {
+			return false
+		}
+	}
+	if len(a.tenants) > 0 && !contains(a.tenants, info.Tenant) {
+		return false
+	}
+	return true
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if v != "" && fmt.Convert(s).ToLower().String() == fmt.Convert(v).ToLower().String() {
+			return true
+		}
+	}
+	return false
+}
+
+// begin stores a fresh state (PKCE verifier, OIDC nonce, the user being
+// linked and the ?next= return path, if any) and redirects to the provider.
+func (a *Authenticator) begin(ctx router.Context, p user.OAuthProvider, linkUserID string) {
+	s := user.OAuthState{Provider: p.Name(), LinkUserId: linkUserID, Next: user.NextOr(ctx, "", a.nextOrigins...)}
+	var challenge string
+	if !a.noPKCE {
+		verifier, err := newRandom()
+		if err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		s.CodeVerifier, challenge = verifier, S256(verifier)
+	}
+	op, isOIDC := p.(user.OIDCProvider)
+	if isOIDC {
+		nonce, err := newRandom()
+		if err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		s.Nonce = nonce
+	}
+	state, err := a.states.CreateState(s)
+	if err != nil {
+		ctx.WriteStatus(500)
+		return
+	}
+	if linkUserID != "" {
+		ctx.SetCookie(router.Cookie{
+			Name: LinkCookie, Value: state, HttpOnly: true, Secure: true,
+			SameSite: router.SameSiteLax, Path: "/oauth/callback/" + p.Name(), MaxAge: 600,
+		})
+	}
+	var url string
+	if isOIDC {
+		url = op.AuthCodeURLWithNonce(state, challenge, s.Nonce)
+	} else {
+		url = p.AuthCodeURL(state, challenge)
+	}
+	if url == "" {
+		ctx.WriteStatus(502) // the provider couldn't reach its IdP, e.g. OIDC discovery
+		return
+	}
+	ctx.SetHeader("Location", url)
+	ctx.WriteStatus(302)
+}
+
+// link finishes a link flow for the user the state row was stored under at
+// /oauth/link/{provider}. The session cookie is SameSite=Strict and doesn't
+// come back with the IdP's redirect, so the browser is recognised by
+// LinkCookie instead: without it someone could start a link on their own
+// account and have a victim complete it with the victim's provider login.
+func (a *Authenticator) link(ctx router.Context, state string, s user.OAuthState, info user.OAuthUserInfo, token user.OAuthToken, afterLink string) {
+	c, ok := ctx.Cookie(LinkCookie)
+	ctx.SetCookie(router.Cookie{Name: LinkCookie, Value: "", Path: "/oauth/callback/" + s.Provider, MaxAge: -1, HttpOnly: true})
+	if !ok || c.Value != state {
+		user.RespondError(ctx, 401, user.CodeInvalidState, user.ErrInvalidOAuthState)
+		return
+	}
+	if identity, err := a.store.IdentityByProvider(s.Provider, info.ID); err == nil {
+		if identity.UserId != s.LinkUserId {
+			user.RespondError(ctx, 409, user.CodeIdentityLinked, user.ErrIdentityLinked)
+			return
+		}
+	} else if err := a.store.UpsertIdentity(s.LinkUserId, s.Provider, info.ID, info.Email); err != nil {
+		user.RespondError(ctx, 500, user.CodeServerError, nil)
+		return
+	}
+	a.keepToken(s.LinkUserId, s.Provider, token)
+	a.report(user.SecurityEvent{Type: user.EventIdentityLinked, IP: user.ClientIP(ctx, a.trustProxy), UserID: s.LinkUserId, Provider: s.Provider})
+	ctx.SetHeader("Location", or(s.Next, afterLink))
+	ctx.WriteStatus(302)
+}
+
+func or(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+// keepToken stores token with WithTokenStore. A failure costs only the
+// token, never the login.
+func (a *Authenticator) keepToken(userID, provider string, token user.OAuthToken) {
+	if a.tokens != nil {
+		_ = a.tokens.SaveProviderToken(userID, provider, token)
+	}
+}
+
+// TokenFor returns the access token userID's last login through provider
+// left, for calling that provider's APIs on their behalf. One expiring within
+// a minute is first renewed through the provider's token endpoint; without a
+// refresh token (or an OAuthRefresher provider) that answers
+// ErrSessionExpired, and the user has to sign in with the provider again.
+func (a *Authenticator) TokenFor(userID, provider string) (user.OAuthToken, error) {
+	if a.tokens == nil {
+		return user.OAuthToken{}, user.ErrNotFound
+	}
+	// One refresh at a time per token: providers that rotate refresh tokens
+	// revoke the old one, so a second concurrent refresh would fail.
+	defer a.lockRefresh(userID + "\x00" + provider)()
+
+	t, err := a.tokens.ProviderToken(userID, provider)
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	now := time.Now() / 1e9
+	if t.Expiry == 0 || t.Expiry-60 > now {
+		return t, nil
+	}
+	r, ok := a.provider(provider).(user.OAuthRefresher)
+	if !ok || t.RefreshToken == "" {
+		return user.OAuthToken{}, user.ErrSessionExpired
+	}
+	fresh, err := r.RefreshToken(t.RefreshToken)
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	if fresh.RefreshToken == "" {
+		fresh.RefreshToken = t.RefreshToken
+	}
+	if fresh.Expiry == 0 && fresh.ExpiresIn > 0 {
+		fresh.Expiry = now + int64(fresh.ExpiresIn)
+	}
+	if err := a.tokens.SaveProviderToken(userID, provider, fresh); err != nil {
+		return user.OAuthToken{}, err
+	}
+	return fresh, nil
+}
+
+type refreshLock struct {
+	sync.Mutex
+	holders int
+}
+
+// lockRefresh takes key's lock and returns its release. Locks live only while
+// someone holds or waits on them, so the map stays as small as the traffic.
+func (a *Authenticator) lockRefresh(key string) func() {
+	a.refreshMu.Lock()
+	if a.refreshing == nil {
+		a.refreshing = make(map[string]*refreshLock)
+	}
+	l := a.refreshing[key]
+	if l == nil {
+		l = &refreshLock{}
+		a.refreshing[key] = l
+	}
+	l.holders++
+	a.refreshMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		a.refreshMu.Lock()
+		if l.holders--; l.holders == 0 {
+			delete(a.refreshing, key)
+		}
+		a.refreshMu.Unlock()
+	}
+}
+
+func caller(ctx router.Context) string {
+	scope, userID := user.SplitScopedUserID(ctx.UserID())
+	if scope != "" {
+		return ""
+	}
+	return userID
+}
+
 var _ user.Authenticator = (*Authenticator)(nil)
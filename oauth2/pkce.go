@@ -0,0 +1,23 @@
+package oauth2
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// newRandom returns 32 random bytes, base64url — 43 characters: a PKCE
+// code_verifier inside RFC 7636's 43..128, or an OpenID Connect nonce.
+func newRandom() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// S256 is the code_challenge sent to the authorization endpoint for verifier.
+func S256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,194 @@
+// Package github signs users in with a GitHub OAuth App.
+package github
+
+import (
+	"github.com/tinywasm/fetch"
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/oauth2/provider/google"
+)
+
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+)
+
+var errNoVerifiedEmail = fmt.Err("email", "unverified")
+
+type GitHubProvider struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// Endpoint overrides, for GitHub Enterprise Server or a local stand-in;
+	// empty means github.com.
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+func (p *GitHubProvider) Name() string {
+	return "github"
+}
+
+func (p *GitHubProvider) config() user.OAuthConfig {
+	cfg := user.OAuthConfig{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       []string{"read:user", "user:email"},
+		AuthURL:      p.AuthURL,
+		TokenURL:     p.TokenURL,
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = githubAuthURL
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = githubTokenURL
+	}
+	return cfg
+}
+
+func (p *GitHubProvider) api() string {
+	if p.APIURL == "" {
+		return githubAPIURL
+	}
+	return p.APIURL
+}
+
+func (p *GitHubProvider) AuthCodeURL(state, challenge string) string {
+	return google.AuthCodeURLHelper(p.config(), state, challenge)
+}
+
+func (p *GitHubProvider) ExchangeCode(code, verifier string) (user.OAuthToken, error) {
+	return google.ExchangeCodeHelper(p.config(), code, verifier)
+}
+
+// RefreshToken only works for GitHub Apps with expiring user tokens; an OAuth
+// App's token doesn't expire and comes without a refresh token.
+func (p *GitHubProvider) RefreshToken(refreshToken string) (user.OAuthToken, error) {
+	return google.RefreshTokenHelper(p.config(), refreshToken)
+}
+
+type githubUser struct {
+	ID        int64
+	Login     string
+	Name      string
+	AvatarURL string
+}
+
+func (u *githubUser) IsNil() bool { return u == nil }
+func (u *githubUser) DecodeFields(r model.FieldReader) {
+	u.ID, _ = r.Int("id")
+	u.Login, _ = r.String("login")
+	u.Name, _ = r.String("name")
+	u.AvatarURL, _ = r.String("avatar_url")
+}
+
+type githubEmail struct {
+	Email    string
+	Primary  bool
+	Verified bool
+}
+
+func (e *githubEmail) IsNil() bool { return e == nil }
+func (e *githubEmail) DecodeFields(r model.FieldReader) {
+	e.Email, _ = r.String("email")
+}
+
+// parseEmails decodes /user/emails; FieldReader has no booleans, so primary
+// and verified are read by hand.
+func parseEmails(body string) ([]githubEmail, error) {
+	elems, ok := google.ElementsHelper(body)
+	if !ok {
+		return nil, user.ErrInvalidCredentials
+	}
+	emails := make([]githubEmail, len(elems))
+	for i, raw := range elems {
+		if err := json.Decode(raw, &emails[i]); err != nil {
+			return nil, err
+		}
+		emails[i].Primary = google.BoolHelper(raw, "primary")
+		emails[i].Verified = google.BoolHelper(raw, "verified")
+	}
+	return emails, nil
+}
+
+// GetUserInfo keys the user by GitHub's numeric id, which survives a rename of
+// the login. The email comes from /user/emails, since the profile's own is
+// empty when the user keeps it private: the primary one if verified, else any
+// verified one, else the login is refused.
+func (p *GitHubProvider) GetUserInfo(token user.OAuthToken) (user.OAuthUserInfo, error) {
+	body, err := p.get("/user", token)
+	if err != nil {
+		return user.OAuthUserInfo{}, err
+	}
+	var u githubUser
+	if err := json.Decode(body, &u); err != nil {
+		return user.OAuthUserInfo{}, err
+	}
+	if u.ID == 0 {
+		return user.OAuthUserInfo{}, user.ErrInvalidCredentials
+	}
+	if body, err = p.get("/user/emails", token); err != nil {
+		return user.OAuthUserInfo{}, err
+	}
+	emails, err := parseEmails(body)
+	if err != nil {
+		return user.OAuthUserInfo{}, err
+	}
+	email := ""
+	for _, e := range emails {
+		if e.Verified && (email == "" || e.Primary) {
+			email = e.Email
+		}
+	}
+	if email == "" {
+		return user.OAuthUserInfo{}, errNoVerifiedEmail
+	}
+	name := u.Name
+	if name == "" {
+		name = u.Login
+	}
+	return user.OAuthUserInfo{
+		ID:            fmt.Convert(u.ID).String(),
+		Email:         email,
+		Name:          name,
+		Avatar:        u.AvatarURL,
+		EmailVerified: true,
+	}, nil
+}
+
+// get returns the body of a REST API GET made with token.
+func (p *GitHubProvider) get(path string, token user.OAuthToken) (string, error) {
+	var body string
+	var errOut error
+	done := make(chan bool)
+
+	fetch.Get(p.api()+path).
+		Header("Authorization", "Bearer "+token.AccessToken).
+		Header("Accept", "application/vnd.github+json").
+		Send(func(resp *fetch.Response, err error) {
+			defer func() { done <- true }()
+			if err != nil {
+				errOut = err
+				return
+			}
+			if resp.Status != 200 {
+				errOut = user.ErrInvalidCredentials
+				return
+			}
+			body = resp.Text()
+		})
+
+	<-done
+	return body, errOut
+}
+
+var (
+	_ user.OAuthProvider  = (*GitHubProvider)(nil)
+	_ user.OAuthRefresher = (*GitHubProvider)(nil)
+)
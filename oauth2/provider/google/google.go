@@ -16,6 +16,12 @@ type GoogleProvider struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURL  string
+
+	// Scopes are added to email and profile, for the Google APIs the app
+	// calls with oauth2's TokenFor. Offline asks for a refresh token
+	// (access_type=offline, prompt=consent) so TokenFor can renew it.
+	Scopes  []string
+	Offline bool
 }
 
 func (p *GoogleProvider) Name() string {
@@ -27,34 +33,45 @@ func (p *GoogleProvider) config() user.OAuthConfig {
 		ClientID:     p.ClientID,
 		ClientSecret: p.ClientSecret,
 		RedirectURL:  p.RedirectURL,
-		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
+		Scopes:       append([]string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"}, p.Scopes...),
 		AuthURL:      googleAuthURL,
 		TokenURL:     googleTokenURL,
 	}
 }
 
-func (p *GoogleProvider) AuthCodeURL(state string) string {
-	return AuthCodeURLHelper(p.config(), state)
+func (p *GoogleProvider) AuthCodeURL(state, challenge string) string {
+	res := AuthCodeURLHelper(p.config(), state, challenge)
+	if p.Offline {
+		res += "&access_type=offline&prompt=consent"
+	}
+	return res
+}
+
+func (p *GoogleProvider) ExchangeCode(code, verifier string) (user.OAuthToken, error) {
+	return ExchangeCodeHelper(p.config(), code, verifier)
 }
 
-func (p *GoogleProvider) ExchangeCode(code string) (user.OAuthToken, error) {
-	return ExchangeCodeHelper(p.config(), code)
+func (p *GoogleProvider) RefreshToken(refreshToken string) (user.OAuthToken, error) {
+	return RefreshTokenHelper(p.config(), refreshToken)
 }
 
+// googleData is decoded with encoding/json: FieldReader has no booleans.
 type googleData struct {
-	ID      string
-	Email   string
-	Name    string
-	Picture string
+	ID            string
+	Email         string
+	VerifiedEmail bool // read by hand: FieldReader has no booleans
+	Name          string
+	Picture       string
+	HD            string // Workspace domain; absent for gmail.com accounts
 }
 
-func (d *googleData) EncodeFields(w model.FieldWriter) {}
-func (d *googleData) IsNil() bool                      { return false }
+func (d *googleData) IsNil() bool { return d == nil }
 func (d *googleData) DecodeFields(r model.FieldReader) {
 	d.ID, _ = r.String("id")
 	d.Email, _ = r.String("email")
 	d.Name, _ = r.String("name")
 	d.Picture, _ = r.String("picture")
+	d.HD, _ = r.String("hd")
 }
 
 func (p *GoogleProvider) GetUserInfo(token user.OAuthToken) (user.OAuthUserInfo, error) {
@@ -74,16 +91,20 @@ func (p *GoogleProvider) GetUserInfo(token user.OAuthToken) (user.OAuthUserInfo,
 				errOut = user.ErrInvalidCredentials
 				return
 			}
+			body := resp.Text()
 			var data googleData
-			if err := json.Decode(resp.Text(), &data); err != nil {
+			if err := json.Decode(body, &data); err != nil {
 				errOut = err
 				return
 			}
+			data.VerifiedEmail = BoolHelper(body, "verified_email")
 			res = user.OAuthUserInfo{
-				ID:     data.ID,
-				Email:  data.Email,
-				Name:   data.Name,
-				Avatar: data.Picture,
+				ID:            data.ID,
+				Email:         data.Email,
+				Name:          data.Name,
+				Avatar:        data.Picture,
+				EmailVerified: data.VerifiedEmail,
+				Tenant:        data.HD,
 			}
 		})
 
@@ -91,11 +112,21 @@ func (p *GoogleProvider) GetUserInfo(token user.OAuthToken) (user.OAuthUserInfo,
 	return res, errOut
 }
 
-func AuthCodeURLHelper(cfg user.OAuthConfig, state string) string {
+var (
+	_ user.OAuthProvider  = (*GoogleProvider)(nil)
+	_ user.OAuthRefresher = (*GoogleProvider)(nil)
+)
+
+// AuthCodeURLHelper builds the authorization request. A non-empty challenge is
+// sent as an S256 PKCE code_challenge.
+func AuthCodeURLHelper(cfg user.OAuthConfig, state, challenge string) string {
 	res := cfg.AuthURL + "?response_type=code"
 	res += "&client_id=" + QueryEscapeHelper(cfg.ClientID)
 	res += "&redirect_uri=" + QueryEscapeHelper(cfg.RedirectURL)
 	res += "&state=" + QueryEscapeHelper(state)
+	if challenge != "" {
+		res += "&code_challenge=" + QueryEscapeHelper(challenge) + "&code_challenge_method=S256"
+	}
 	if len(cfg.Scopes) > 0 {
 		res += "&scope="
 		for i, s := range cfg.Scopes {
@@ -108,19 +139,40 @@ func AuthCodeURLHelper(cfg user.OAuthConfig, state string) string {
 	return res
 }
 
-func ExchangeCodeHelper(cfg user.OAuthConfig, code string) (user.OAuthToken, error) {
+// ExchangeCodeHelper redeems code at the token endpoint, proving the PKCE
+// verifier when there is one.
+func ExchangeCodeHelper(cfg user.OAuthConfig, code, verifier string) (user.OAuthToken, error) {
 	body := "grant_type=authorization_code"
 	body += "&code=" + QueryEscapeHelper(code)
 	body += "&client_id=" + QueryEscapeHelper(cfg.ClientID)
 	body += "&client_secret=" + QueryEscapeHelper(cfg.ClientSecret)
 	body += "&redirect_uri=" + QueryEscapeHelper(cfg.RedirectURL)
+	if verifier != "" {
+		body += "&code_verifier=" + QueryEscapeHelper(verifier)
+	}
+	return postToken(cfg, body)
+}
+
+// RefreshTokenHelper redeems a refresh token for a new access token.
+func RefreshTokenHelper(cfg user.OAuthConfig, refreshToken string) (user.OAuthToken, error) {
+	body := "grant_type=refresh_token"
+	body += "&refresh_token=" + QueryEscapeHelper(refreshToken)
+	body += "&client_id=" + QueryEscapeHelper(cfg.ClientID)
+	body += "&client_secret=" + QueryEscapeHelper(cfg.ClientSecret)
+	return postToken(cfg, body)
+}
 
+// postToken posts a form to the token endpoint. It asks for JSON explicitly,
+// as GitHub otherwise answers form-encoded, and takes a 200 without
+// access_token (GitHub's way of reporting a bad code) as a failure.
+func postToken(cfg user.OAuthConfig, body string) (user.OAuthToken, error) {
 	var res user.OAuthToken
 	var errOut error
 	done := make(chan bool)
 
 	fetch.Post(cfg.TokenURL).
 		Header("Content-Type", "application/x-www-form-urlencoded").
+		Header("Accept", "application/json").
 		Body([]byte(body)).
 		Send(func(resp *fetch.Response, err error) {
 			defer func() { done <- true }()
@@ -136,6 +188,9 @@ func ExchangeCodeHelper(cfg user.OAuthConfig, code string) (user.OAuthToken, err
 				errOut = err
 				return
 			}
+			if res.AccessToken == "" {
+				errOut = user.ErrInvalidCredentials
+			}
 		})
 
 	<-done
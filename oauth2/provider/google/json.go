@@ -0,0 +1,147 @@
+package google
+
+// model.FieldReader reads strings, integers and arrays of strings. Provider
+// payloads also carry booleans (email_verified) and arrays of objects (JWKS
+// keys, GitHub's emails); these helpers cut such a payload into raw JSON
+// pieces without decoding them, so each piece can go to json.Decode or be
+// compared as is.
+
+// MemberHelper returns the raw JSON text of obj's top-level member name —
+// `true`, `"abc"`, `[...]` — and false if obj isn't an object or has no such
+// member. Names are compared as written, escapes and all.
+func MemberHelper(obj, name string) (string, bool) {
+	i := skipSpace(obj, 0)
+	if at(obj, i) != '{' {
+		return "", false
+	}
+	i = skipSpace(obj, i+1)
+	if at(obj, i) == '}' {
+		return "", false
+	}
+	for {
+		end := skipValue(obj, i)
+		if at(obj, i) != '"' || end < 0 {
+			return "", false
+		}
+		key := obj[i+1 : end-1]
+		i = skipSpace(obj, end)
+		if at(obj, i) != ':' {
+			return "", false
+		}
+		i = skipSpace(obj, i+1)
+		end = skipValue(obj, i)
+		if end < 0 {
+			return "", false
+		}
+		if key == name {
+			return obj[i:end], true
+		}
+		i = skipSpace(obj, end)
+		if at(obj, i) != ',' {
+			return "", false
+		}
+		i = skipSpace(obj, i+1)
+	}
+}
+
+// BoolHelper reports whether obj's top-level member name is literally true.
+func BoolHelper(obj, name string) bool {
+	v, _ := MemberHelper(obj, name)
+	return v == "true"
+}
+
+// ElementsHelper returns the raw JSON text of each element of the array arr,
+// and false if arr isn't an array.
+func ElementsHelper(arr string) ([]string, bool) {
+	i := skipSpace(arr, 0)
+	if at(arr, i) != '[' {
+		return nil, false
+	}
+	var out []string
+	i = skipSpace(arr, i+1)
+	if at(arr, i) == ']' {
+		return out, true
+	}
+	for {
+		end := skipValue(arr, i)
+		if end < 0 {
+			return nil, false
+		}
+		out = append(out, arr[i:end])
+		i = skipSpace(arr, end)
+		switch at(arr, i) {
+		case ',':
+			i = skipSpace(arr, i+1)
+		case ']':
+			return out, true
+		default:
+			return nil, false
+		}
+	}
+}
+
+// skipValue returns the index just past the JSON value starting at s[i], or
+// -1 if there is none. Containers only need to balance; json.Decode checks
+// whatever is taken out of them.
+func skipValue(s string, i int) int {
+	switch at(s, i) {
+	case 0:
+		return -1
+	case '"':
+		for i++; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return -1
+	case '{', '[':
+		depth := 0
+		for ; i < len(s); i++ {
+			switch s[i] {
+			case '"':
+				end := skipValue(s, i)
+				if end < 0 {
+					return -1
+				}
+				i = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return -1
+	}
+	start := i
+	for i < len(s) && !isDelim(s[i]) {
+		i++
+	}
+	if i == start {
+		return -1
+	}
+	return i
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+// at is s[i], or 0 past the end.
+func at(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func isDelim(c byte) bool { return isSpace(c) || c == ',' || c == ':' || c == '}' || c == ']' }
@@ -1,96 +1,85 @@
 package microsoft
 
 import (
-	"github.com/tinywasm/fetch"
-	"github.com/tinywasm/json"
-	"github.com/tinywasm/model"
+	"sync"
+
 	"github.com/tinywasm/user"
-	"github.com/tinywasm/user/oauth2/provider/google"
+	"github.com/tinywasm/user/oauth2/provider/oidc"
 )
 
-const (
-	msAuthURL  = "https://login.microsoftonline.com/common/oauth2/v2.0/authorize"
-	msTokenURL = "https://login.microsoftonline.com/common/oauth2/v2.0/token"
-)
+// msIssuer is the multi-tenant endpoint: work, school and personal accounts
+// of any tenant.
+const msIssuer = "https://login.microsoftonline.com/common/v2.0"
 
+// MicrosoftProvider signs users in with Microsoft Entra ID. The user comes from
+// the id_token, verified like any OpenID Connect one (oidc.Provider.Entra):
+// Tenant is its tid, and EmailVerified needs the optional xms_edov claim,
+// which the app registration must ask for — Graph's mail and
+// userPrincipalName are whatever a tenant's admin typed, so any tenant can
+// claim any address.
 type MicrosoftProvider struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURL  string
+
+	// Scopes are added to openid, email, profile and User.Read, for the Graph
+	// APIs the app calls with oauth2's TokenFor. Offline adds offline_access,
+	// which gets a refresh token.
+	Scopes  []string
+	Offline bool
+
+	once sync.Once
+	oidc *oidc.Provider
 }
 
 func (p *MicrosoftProvider) Name() string {
 	return "microsoft"
 }
 
-func (p *MicrosoftProvider) config() user.OAuthConfig {
-	return user.OAuthConfig{
-		ClientID:     p.ClientID,
-		ClientSecret: p.ClientSecret,
-		RedirectURL:  p.RedirectURL,
-		Scopes:       []string{"User.Read"},
-		AuthURL:      msAuthURL,
-		TokenURL:     msTokenURL,
-	}
+func (p *MicrosoftProvider) provider() *oidc.Provider {
+	p.once.Do(func() {
+		scopes := append([]string{"openid", "email", "profile", "User.Read"}, p.Scopes...)
+		if p.Offline {
+			scopes = append(scopes, "offline_access")
+		}
+		p.oidc = &oidc.Provider{
+			Issuer:       msIssuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			ProviderName: p.Name(),
+			Scopes:       scopes,
+			Entra:        true,
+		}
+	})
+	return p.oidc
 }
 
-func (p *MicrosoftProvider) AuthCodeURL(state string) string {
-	return google.AuthCodeURLHelper(p.config(), state)
+func (p *MicrosoftProvider) AuthCodeURL(state, challenge string) string {
+	return p.provider().AuthCodeURL(state, challenge)
 }
 
-func (p *MicrosoftProvider) ExchangeCode(code string) (user.OAuthToken, error) {
-	return google.ExchangeCodeHelper(p.config(), code)
+func (p *MicrosoftProvider) AuthCodeURLWithNonce(state, challenge, nonce string) string {
+	return p.provider().AuthCodeURLWithNonce(state, challenge, nonce)
 }
 
-type msData struct {
-	ID                string
-	Email             string
-	UserPrincipalName string
-	Name              string
+func (p *MicrosoftProvider) ExchangeCode(code, verifier string) (user.OAuthToken, error) {
+	return p.provider().ExchangeCode(code, verifier)
 }
 
-func (d *msData) EncodeFields(w model.FieldWriter) {}
-func (d *msData) IsNil() bool                      { return false }
-func (d *msData) DecodeFields(r model.FieldReader) {
-	d.ID, _ = r.String("id")
-	d.Email, _ = r.String("mail")
-	d.UserPrincipalName, _ = r.String("userPrincipalName")
-	d.Name, _ = r.String("displayName")
+func (p *MicrosoftProvider) RefreshToken(refreshToken string) (user.OAuthToken, error) {
+	return p.provider().RefreshToken(refreshToken)
 }
 
 func (p *MicrosoftProvider) GetUserInfo(token user.OAuthToken) (user.OAuthUserInfo, error) {
-	var res user.OAuthUserInfo
-	var errOut error
-	done := make(chan bool)
-
-	fetch.Get("https://graph.microsoft.com/v1.0/me").
-		Header("Authorization", "Bearer "+token.AccessToken).
-		Send(func(resp *fetch.Response, err error) {
-			defer func() { done <- true }()
-			if err != nil {
-				errOut = err
-				return
-			}
-			if resp.Status != 200 {
-				errOut = user.ErrInvalidCredentials
-				return
-			}
-			var data msData
-			if err := json.Decode(resp.Text(), &data); err != nil {
-				errOut = err
-				return
-			}
-			email := data.Email
-			if email == "" {
-				email = data.UserPrincipalName
-			}
-			res = user.OAuthUserInfo{
-				ID:    data.ID,
-				Email: email,
-				Name:  data.Name,
-			}
-		})
+	return p.provider().GetUserInfo(token)
+}
 
-	<-done
-	return res, errOut
+func (p *MicrosoftProvider) VerifiedUserInfo(token user.OAuthToken, nonce string) (user.OAuthUserInfo, error) {
+	return p.provider().VerifiedUserInfo(token, nonce)
 }
+
+var (
+	_ user.OIDCProvider   = (*MicrosoftProvider)(nil)
+	_ user.OAuthRefresher = (*MicrosoftProvider)(nil)
+)
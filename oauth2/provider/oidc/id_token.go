@@ -0,0 +1,269 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user/oauth2/provider/google"
+)
+
+// leeway absorbs clock skew between the IdP and this server, in seconds.
+const leeway = 60
+
+// jwk is one entry of the IdP's JWKS. Only RSA and P-256 signing keys are
+// used; others are skipped.
+type jwk struct{ Kty, Kid, Use, N, E, Crv, X, Y string }
+
+func (k *jwk) IsNil() bool { return k == nil }
+func (k *jwk) DecodeFields(r model.FieldReader) {
+	k.Kty, _ = r.String("kty")
+	k.Kid, _ = r.String("kid")
+	k.Use, _ = r.String("use")
+	k.N, _ = r.String("n")
+	k.E, _ = r.String("e")
+	k.Crv, _ = r.String("crv")
+	k.X, _ = r.String("x")
+	k.Y, _ = r.String("y")
+}
+
+// parseJWKS takes the keys out of a JWKS document; one that won't decode is
+// skipped like one of an unsupported type.
+func parseJWKS(body string) ([]jwk, bool) {
+	raw, ok := google.MemberHelper(body, "keys")
+	if !ok {
+		return nil, false
+	}
+	elems, ok := google.ElementsHelper(raw)
+	if !ok {
+		return nil, false
+	}
+	keys := []jwk{}
+	for _, e := range elems {
+		var k jwk
+		if json.Decode(e, &k) == nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys, true
+}
+
+func (k jwk) publicKey() crypto.PublicKey {
+	if k.Use != "" && k.Use != "sig" {
+		return nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if k.Crv != "P-256" || err1 != nil || err2 != nil || len(x) != 32 || len(y) != 32 {
+			return nil
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := pub.ECDH(); err != nil {
+			return nil
+		}
+		return pub
+	}
+	return nil
+}
+
+// audience is "aud", which may be one string or an array of them.
+type audience []string
+
+func (a audience) has(clientID string) bool {
+	for _, v := range a {
+		if v == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+type claims struct {
+	Iss        string
+	Sub        string
+	Aud        audience
+	Azp        string
+	Exp        int64
+	Nonce      string
+	Email      string
+	Verified   bool // set by decodeClaims: FieldReader has no booleans
+	Name       string
+	GivenName  string
+	FamilyName string
+	Picture    string
+	Tid        string // Entra ID tenant
+	HD         string // Google Workspace domain
+
+	// Entra ID only; see Provider.Entra.
+	Oid               string
+	PreferredUsername string
+	EdOV              bool
+}
+
+func (c *claims) IsNil() bool { return c == nil }
+func (c *claims) DecodeFields(r model.FieldReader) {
+	c.Iss, _ = r.String("iss")
+	c.Sub, _ = r.String("sub")
+	if ar, ok := r.Array("aud"); ok {
+		for i := 0; i < ar.Len(); i++ {
+			c.Aud = append(c.Aud, ar.String(i))
+		}
+	} else if aud, ok := r.String("aud"); ok {
+		c.Aud = audience{aud}
+	}
+	c.Azp, _ = r.String("azp")
+	c.Exp, _ = r.Int("exp")
+	c.Nonce, _ = r.String("nonce")
+	c.Email, _ = r.String("email")
+	c.Name, _ = r.String("name")
+	c.GivenName, _ = r.String("given_name")
+	c.FamilyName, _ = r.String("family_name")
+	c.Picture, _ = r.String("picture")
+	c.Tid, _ = r.String("tid")
+	c.HD, _ = r.String("hd")
+	c.Oid, _ = r.String("oid")
+	c.PreferredUsername, _ = r.String("preferred_username")
+}
+
+// decodeClaims decodes the payload, then reads the boolean claims by hand:
+// true, or "true" as some IdPs (Cognito, older ADFS) send it.
+func decodeClaims(payload string) (claims, error) {
+	var c claims
+	if err := json.Decode(payload, &c); err != nil {
+		return claims{}, err
+	}
+	c.Verified = isTrue(payload, "email_verified")
+	c.EdOV = isTrue(payload, "xms_edov")
+	return c, nil
+}
+
+func isTrue(payload, claim string) bool {
+	v, _ := google.MemberHelper(payload, claim)
+	return v == "true" || v == `"true"`
+}
+
+type header struct{ Alg, Kid string }
+
+func (h *header) IsNil() bool { return h == nil }
+func (h *header) DecodeFields(r model.FieldReader) {
+	h.Alg, _ = r.String("alg")
+	h.Kid, _ = r.String("kid")
+}
+
+// verify checks an id_token as OpenID Connect Core §3.1.3.7 asks of a
+// confidential client: RS256 or ES256 signature by a key in the issuer's
+// JWKS, then iss, aud (and azp when there are several audiences), exp and
+// nonce.
+func (p *Provider) verify(idToken, nonce string) (claims, error) {
+	parts := fmt.Split(idToken, ".")
+	if len(parts) != 3 {
+		return claims{}, errIDToken
+	}
+	var h header
+	if seg, err := segment(parts[0]); err != nil || json.Decode(seg, &h) != nil {
+		return claims{}, errIDToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims{}, errIDToken
+	}
+	if !p.checkSignature(h.Alg, h.Kid, parts[0]+"."+parts[1], sig) {
+		return claims{}, errIDToken
+	}
+
+	payload, err := segment(parts[1])
+	if err != nil {
+		return claims{}, errIDToken
+	}
+	c, err := decodeClaims(payload)
+	if err != nil {
+		return claims{}, errIDToken
+	}
+	m, err := p.discover()
+	if err != nil {
+		return claims{}, err
+	}
+	iss := m.Issuer
+	if p.Entra && fmt.Contains(iss, "{tenantid}") {
+		if c.Tid == "" {
+			return claims{}, errIDToken
+		}
+		iss = fmt.Convert(iss).Replace("{tenantid}", c.Tid).String()
+	}
+	if c.Iss != iss || c.Sub == "" || !c.Aud.has(p.ClientID) {
+		return claims{}, errIDToken
+	}
+	if len(c.Aud) > 1 && c.Azp != p.ClientID {
+		return claims{}, errIDToken
+	}
+	if c.Exp+leeway < time.Now()/1e9 {
+		return claims{}, errIDToken
+	}
+	if nonce != "" && c.Nonce != nonce {
+		return claims{}, errIDToken
+	}
+	return c, nil
+}
+
+// checkSignature tries the keys named by kid (every key when the header names
+// none), fetching the JWKS again once if kid isn't known yet.
+func (p *Provider) checkSignature(alg, kid, signed string, sig []byte) bool {
+	if alg != "RS256" && alg != "ES256" {
+		return false // notably "none" and HS256 with the public key as secret
+	}
+	h := sha256.Sum256([]byte(signed))
+	for _, refresh := range []bool{false, true} {
+		keys, err := p.signingKeys(refresh)
+		if err != nil {
+			return false
+		}
+		known := false
+		for _, k := range keys {
+			if kid != "" && k.Kid != kid {
+				continue
+			}
+			known = true
+			switch pub := k.publicKey().(type) {
+			case *rsa.PublicKey:
+				if alg == "RS256" && rsa.VerifyPKCS1v15(pub, crypto.SHA256, h[:], sig) == nil {
+					return true
+				}
+			case *ecdsa.PublicKey:
+				if alg == "ES256" && len(sig) == 64 &&
+					ecdsa.Verify(pub, h[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+					return true
+				}
+			}
+		}
+		if known {
+			return false
+		}
+	}
+	return false
+}
+
+// segment base64url-decodes one part of a JWT.
+func segment(seg string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,245 @@
+// Package oidc is a generic OpenID Connect provider: point it at an issuer
+// (Keycloak realm, Okta, Auth0, a tenant-specific Azure AD) and it discovers
+// the endpoints, then takes the user from a verified id_token rather than a
+// proprietary userinfo API.
+package oidc
+
+import (
+	"sync"
+
+	"github.com/tinywasm/fetch"
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/oauth2/provider/google"
+)
+
+var (
+	errDiscovery = fmt.Err("oidc", "discovery", "failed")
+	errIDToken   = fmt.Err("id_token", "invalid")
+)
+
+type Provider struct {
+	Issuer       string // exactly as the IdP writes it in "iss", e.g. "https://sso.example.com/realms/acme"
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	ProviderName string   // the {provider} in /oauth/{provider}; default "oidc"
+	Scopes       []string // default openid, email, profile; add offline_access for a refresh token
+	JWKSTTL      int64    // seconds fetched signing keys are trusted; default 3600
+
+	// Entra reads id_tokens the Microsoft Entra ID (Azure AD) way: the
+	// issuer may be the multi-tenant ".../{tenantid}/v2.0" template, which a
+	// token must match with its own tid; users are keyed by "oid", their id
+	// in every app and in Graph; and the email, falling back to
+	// preferred_username, counts as verified only when the optional xms_edov
+	// claim says the tenant owns its domain.
+	Entra bool
+
+	mu     sync.Mutex
+	meta   *metadata
+	keys   []jwk
+	keysAt int64
+}
+
+// metadata is the part of .well-known/openid-configuration this package uses.
+type metadata struct {
+	Issuer                string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+}
+
+func (m *metadata) IsNil() bool { return m == nil }
+func (m *metadata) DecodeFields(r model.FieldReader) {
+	m.Issuer, _ = r.String("issuer")
+	m.AuthorizationEndpoint, _ = r.String("authorization_endpoint")
+	m.TokenEndpoint, _ = r.String("token_endpoint")
+	m.JWKSURI, _ = r.String("jwks_uri")
+}
+
+func (p *Provider) Name() string {
+	if p.ProviderName == "" {
+		return "oidc"
+	}
+	return p.ProviderName
+}
+
+// discover fetches the issuer's metadata once; a failure is retried on the
+// next login rather than cached.
+func (p *Provider) discover() (metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return *p.meta, nil
+	}
+	body, err := get(p.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return metadata{}, err
+	}
+	var m metadata
+	if err := json.Decode(body, &m); err != nil {
+		return metadata{}, errDiscovery
+	}
+	if !p.publishedIssuer(m.Issuer) || m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return metadata{}, errDiscovery
+	}
+	p.meta = &m
+	return m, nil
+}
+
+// publishedIssuer reports whether discovery's issuer is the configured one —
+// or, with Entra, a "{tenantid}" template verify fills in per token.
+func (p *Provider) publishedIssuer(iss string) bool {
+	return iss == p.Issuer || p.Entra && fmt.Contains(iss, "{tenantid}")
+}
+
+func (p *Provider) config(m metadata) user.OAuthConfig {
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return user.OAuthConfig{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       scopes,
+		AuthURL:      m.AuthorizationEndpoint,
+		TokenURL:     m.TokenEndpoint,
+	}
+}
+
+// AuthCodeURL is "" while discovery fails; the oauth2 mode answers 502.
+func (p *Provider) AuthCodeURL(state, challenge string) string {
+	return p.AuthCodeURLWithNonce(state, challenge, "")
+}
+
+func (p *Provider) AuthCodeURLWithNonce(state, challenge, nonce string) string {
+	m, err := p.discover()
+	if err != nil {
+		return ""
+	}
+	res := google.AuthCodeURLHelper(p.config(m), state, challenge)
+	if nonce != "" {
+		res += "&nonce=" + google.QueryEscapeHelper(nonce)
+	}
+	return res
+}
+
+func (p *Provider) ExchangeCode(code, verifier string) (user.OAuthToken, error) {
+	m, err := p.discover()
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	return google.ExchangeCodeHelper(p.config(m), code, verifier)
+}
+
+func (p *Provider) RefreshToken(refreshToken string) (user.OAuthToken, error) {
+	m, err := p.discover()
+	if err != nil {
+		return user.OAuthToken{}, err
+	}
+	return google.RefreshTokenHelper(p.config(m), refreshToken)
+}
+
+// GetUserInfo verifies the id_token without a nonce check; the oauth2 mode
+// calls VerifiedUserInfo instead.
+func (p *Provider) GetUserInfo(token user.OAuthToken) (user.OAuthUserInfo, error) {
+	return p.VerifiedUserInfo(token, "")
+}
+
+// VerifiedUserInfo maps the standard claims of token's id_token once its
+// signature, iss, aud, exp and — when nonce isn't "" — nonce check out.
+func (p *Provider) VerifiedUserInfo(token user.OAuthToken, nonce string) (user.OAuthUserInfo, error) {
+	c, err := p.verify(token.IDToken, nonce)
+	if err != nil {
+		return user.OAuthUserInfo{}, err
+	}
+	name := c.Name
+	if name == "" {
+		name = fmt.Convert(c.GivenName + " " + c.FamilyName).TrimSpace().String()
+	}
+	tenant := c.Tid
+	if tenant == "" {
+		tenant = c.HD
+	}
+	id, email, verified := c.Sub, c.Email, c.Verified
+	if p.Entra {
+		id, verified = c.Oid, c.Email != "" && c.EdOV
+		if email == "" {
+			email = c.PreferredUsername
+		}
+		if id == "" {
+			return user.OAuthUserInfo{}, errIDToken
+		}
+	}
+	return user.OAuthUserInfo{ID: id, Email: email, Name: name, Avatar: c.Picture, EmailVerified: verified, Tenant: tenant}, nil
+}
+
+// signingKeys returns the cached JWKS, refetching it once it is older than
+// JWKSTTL or, when refresh is set, older than a minute — how an unknown kid
+// after a key rotation gets picked up without letting forged kids hammer the
+// IdP.
+func (p *Provider) signingKeys(refresh bool) ([]jwk, error) {
+	m, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	ttl := p.JWKSTTL
+	if ttl == 0 {
+		ttl = 3600
+	}
+	if refresh && ttl > 60 {
+		ttl = 60
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now() / 1e9
+	if p.keys != nil && now-p.keysAt < ttl {
+		return p.keys, nil
+	}
+	body, err := get(m.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	keys, ok := parseJWKS(body)
+	if !ok {
+		return nil, errDiscovery
+	}
+	p.keys, p.keysAt = keys, now
+	return p.keys, nil
+}
+
+// get fetches url and returns the body of a 200 answer.
+func get(url string) (string, error) {
+	var body string
+	var errOut error
+	done := make(chan bool)
+
+	fetch.Get(url).
+		Header("Accept", "application/json").
+		Send(func(resp *fetch.Response, err error) {
+			defer func() { done <- true }()
+			if err != nil {
+				errOut = err
+				return
+			}
+			if resp.Status != 200 {
+				errOut = errDiscovery
+				return
+			}
+			body = resp.Text()
+		})
+
+	<-done
+	return body, errOut
+}
+
+var (
+	_ user.OIDCProvider   = (*Provider)(nil)
+	_ user.OAuthRefresher = (*Provider)(nil)
+)
@@ -0,0 +1,185 @@
+package otpcode
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/user"
+)
+
+// ClientCookie carries the value a code is bound to, from the request that
+// asked for it to the one that redeems it.
+const ClientCookie = "otp_client"
+
+type Authenticator struct {
+	store      user.IdentityStore
+	codes      user.LoginCodeStore
+	sessions   user.SessionIssuer
+	notify     user.SecurityNotifier
+	sender     user.CodeSender
+	channel    user.CodeChannel
+	afterLogin string
+	limiter    user.RateLimiter
+	trustProxy bool
+}
+
+type Option func(*Authenticator)
+
+func WithAfterLogin(path string) Option { return func(a *Authenticator) { a.afterLogin = path } }
+func WithTrustProxy(v bool) Option      { return func(a *Authenticator) { a.trustProxy = v } }
+
+// WithRateLimiter limits both routes per client IP, and POST /login/code per
+// email too (e.g. ratelimit.New).
+func WithRateLimiter(l user.RateLimiter) Option { return func(a *Authenticator) { a.limiter = l } }
+
+// WithChannel picks where codes go. Default user.CodeByEmail; with
+// user.CodeBySMS an account without a phone simply gets nothing.
+func WithChannel(c user.CodeChannel) Option { return func(a *Authenticator) { a.channel = c } }
+
+func New(store user.IdentityStore, codes user.LoginCodeStore, sessions user.SessionIssuer, notify user.SecurityNotifier, sender user.CodeSender, opts ...Option) *Authenticator {
+	a := &Authenticator{store: store, codes: codes, sessions: sessions, notify: notify, sender: sender}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *Authenticator) Name() string { return "otp_code" }
+
+type codeData struct{ Code string }
+
+func (d *codeData) IsNil() bool                      { return d == nil }
+func (d *codeData) DecodeFields(r model.FieldReader) { d.Code, _ = r.String("code") }
+
+// Mount serves the request and the redemption. POST /login/code answers 202
+// and sets the client cookie whatever happens behind it, so it can't be used
+// to probe which emails have accounts. The code is made and sent after the
+// answer, so neither can its timing.
+func (a *Authenticator) Mount(r router.Router) {
+	afterLogin := a.afterLogin
+	if afterLogin == "" {
+		afterLogin = user.PathAfterLogin
+	}
+
+	r.Post(user.PathLoginCode, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		data := &user.ForgotData{}
+		if err := ctx.Decode(data); err != nil {
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, nil)
+			return
+		}
+		email := fmt.Convert(data.Email).TrimSpace().String()
+		if a.limited(ctx, ip, email) {
+			return
+		}
+
+		client, err := newClient()
+		if err != nil {
+			user.RespondError(ctx, 500, user.CodeServerError, nil)
+			return
+		}
+		ctx.SetCookie(router.Cookie{
+			Name: ClientCookie, Value: client, HttpOnly: true, Secure: true,
+			SameSite: router.SameSiteStrict, Path: user.PathLoginCode,
+		})
+		go a.send(email, client)
+		ctx.WriteStatus(202)
+	}).Public()
+
+	r.Post(user.PathLoginCodeVerify, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		data := &codeData{}
+		if err := ctx.Decode(data); err != nil {
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, nil)
+			return
+		}
+		if a.limited(ctx, ip, "") {
+			return
+		}
+
+		c, ok := ctx.Cookie(ClientCookie)
+		if !ok || c.Value == "" {
+			user.RespondError(ctx, 401, user.CodeInvalidCode, user.ErrInvalidCode)
+			return
+		}
+		userID, err := a.codes.VerifyLoginCode(c.Value, fmt.Convert(data.Code).TrimSpace().String())
+		if err != nil {
+			user.RespondError(ctx, 401, user.CodeInvalidCode, user.ErrInvalidCode)
+			return
+		}
+		u, err := a.store.UserByID(userID)
+		if err != nil {
+			user.RespondError(ctx, 401, user.CodeInvalidCode, user.ErrInvalidCode)
+			return
+		}
+		if u.Status != "active" {
+			a.notify.Notify(user.SecurityEvent{Type: user.EventNonActiveAccess, IP: ip, UserID: u.Id, Mode: a.Name()})
+			user.RespondError(ctx, 401, user.CodeSuspended, nil)
+			return
+		}
+
+		if err := a.sessions.IssueSession(ctx, u.Id); err != nil {
+			user.RespondIssueError(ctx, err)
+			return
+		}
+		ctx.SetCookie(router.Cookie{Name: ClientCookie, Value: "", Path: user.PathLoginCode, MaxAge: -1, HttpOnly: true})
+		user.RespondLogin(ctx, user.NextOr(ctx, afterLogin), a.store, u)
+	}).Public()
+}
+
+func (a *Authenticator) send(email, client string) {
+	u, err := a.store.UserByEmail(email)
+	if err != nil || u.Status != "active" {
+		return
+	}
+	to := u.Email
+	if a.channel == user.CodeBySMS {
+		to = u.Phone
+	}
+	if to == "" {
+		return
+	}
+	code, err := a.codes.CreateLoginCode(u.Id, client)
+	if err == user.ErrRateLimited {
+		a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, UserID: u.Id, Mode: a.Name()})
+	}
+	if err != nil {
+		return
+	}
+	a.sender.SendCode(to, a.channel, code)
+}
+
+// limited answers 429 when the limiter rejects ctx's client IP or key.
+func (a *Authenticator) limited(ctx router.Context, ip, key string) bool {
+	if user.Allowed(a.limiter, ip, key) {
+		return false
+	}
+	a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, UserID: key, Mode: a.Name()})
+	user.RespondError(ctx, 429, user.CodeRateLimited, user.ErrRateLimited)
+	return true
+}
+
+// Reaches reports whether u has the address this mode's channel sends to.
+func (a *Authenticator) Reaches(u user.User) bool {
+	if a.channel == user.CodeBySMS {
+		return u.Phone != ""
+	}
+	return u.Email != ""
+}
+
+// newClient returns the unguessable value a code gets bound to.
+func newClient() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+var (
+	_ user.Authenticator = (*Authenticator)(nil)
+	_ user.AddressLogin  = (*Authenticator)(nil)
+)
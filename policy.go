@@ -0,0 +1,228 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"unicode/utf8"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// PolicyReason is one rule a password broke. Its Code is the stable string a
+// UI switches on to show its own message.
+type PolicyReason uint8
+
+const (
+	ReasonTooShort PolicyReason = iota // shorter than MinLength
+	ReasonTooLong                      // longer than MaxLength
+	ReasonNoUpper                      // RequireUpper, no A-Z
+	ReasonNoLower                      // RequireLower, no a-z
+	ReasonNoDigit                      // RequireDigit, no 0-9
+	ReasonNoSymbol                     // RequireSymbol, nothing outside letters and digits
+	ReasonPersonal                     // RejectPersonal, contains the user's email or name
+	ReasonBreached                     // listed in Breached
+)
+
+var reasonCodes = []string{"too_short", "too_long", "no_upper", "no_lower", "no_digit", "no_symbol", "personal", "breached"}
+
+func (r PolicyReason) Code() string {
+	if int(r) < len(reasonCodes) {
+		return reasonCodes[r]
+	}
+	return "unknown"
+}
+
+// PolicyError lists every rule a password broke, not just the first, so a
+// form can flag them all at once. errors.Is(err, ErrWeakPassword) holds.
+type PolicyError struct {
+	Reasons []PolicyReason
+}
+
+func (e *PolicyError) Codes() []string {
+	codes := make([]string, len(e.Reasons))
+	for i, r := range e.Reasons {
+		codes[i] = r.Code()
+	}
+	return codes
+}
+
+func (e *PolicyError) Error() string {
+	return ErrWeakPassword.Error() + ": " + fmt.JoinSlice(e.Codes(), ",")
+}
+
+func (e *PolicyError) Unwrap() error { return ErrWeakPassword }
+
+func (e *PolicyError) IsNil() bool { return e == nil }
+
+func (e *PolicyError) EncodeFields(w model.FieldWriter) {
+	w.String("error", ErrWeakPassword.Error())
+	aw := w.Array("reasons", len(e.Reasons))
+	for _, c := range e.Codes() {
+		aw.String(c)
+	}
+	aw.Close()
+}
+
+// PasswordPolicy is the declarative password rule set authority applies on
+// every password write (Config.PasswordPolicy). It lives in the root package
+// so a WASM form can run the very same Check before submitting.
+type PasswordPolicy struct {
+	MinLength      int // in characters, not bytes; default and floor: 8
+	MaxLength      int // in characters; 0 = no maximum
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	RejectPersonal bool         // reject passwords containing the email's local part or a word of the name
+	Breached       *BreachedSet // nil = no breached/common-password list
+}
+
+// Check returns nil or a *PolicyError. personal is what RejectPersonal
+// compares against — typically the user's email and name.
+func (p *PasswordPolicy) Check(password string, personal ...string) error {
+	var reasons []PolicyReason
+	min := p.MinLength
+	if min < 8 {
+		min = 8
+	}
+	n := utf8.RuneCountInString(password)
+	if n < min {
+		reasons = append(reasons, ReasonTooShort)
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		reasons = append(reasons, ReasonTooLong)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		reasons = append(reasons, ReasonNoUpper)
+	}
+	if p.RequireLower && !lower {
+		reasons = append(reasons, ReasonNoLower)
+	}
+	if p.RequireDigit && !digit {
+		reasons = append(reasons, ReasonNoDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		reasons = append(reasons, ReasonNoSymbol)
+	}
+	if p.RejectPersonal && containsPersonal(password, personal) {
+		reasons = append(reasons, ReasonPersonal)
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		reasons = append(reasons, ReasonBreached)
+	}
+
+	if len(reasons) > 0 {
+		return &PolicyError{Reasons: reasons}
+	}
+	return nil
+}
+
+// containsPersonal looks for the local part of any email, or any word of a
+// name, inside the password. Fragments under 3 characters are too common to
+// mean anything.
+func containsPersonal(password string, personal []string) bool {
+	lower := fmt.ToLower(password)
+	for _, value := range personal {
+		value = fmt.ToLower(value)
+		if at := fmt.Index(value, "@"); at >= 0 {
+			value = value[:at]
+		}
+		for _, word := range fmt.Split(value, " ") {
+			if len(word) >= 3 && fmt.Contains(lower, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BreachedSet is a Bloom filter of known-bad passwords: compact enough to hold
+// a large breached/common list in memory, at the cost of rare false positives
+// (a strong password wrongly refused) and never a false negative. Entries are
+// compared case-insensitively. Fill it with Add, or on the server with
+// authority.LoadBreachedList. The zero BreachedSet has no bits: it contains
+// nothing and Add can't change that, so size one with NewBreachedSet.
+type BreachedSet struct {
+	bits   []uint64
+	m      uint64 // number of bits
+	hashes uint64
+}
+
+// NewBreachedSet sizes a filter for n entries at false-positive rate fp
+// (e.g. 0.001).
+func NewBreachedSet(n int, fp float64) *BreachedSet {
+	if n < 1 {
+		n = 1
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.001
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BreachedSet{bits: make([]uint64, (m+63)/64), m: m, hashes: k}
+}
+
+func (b *BreachedSet) Add(password string) {
+	if b.m == 0 {
+		return
+	}
+	h1, h2 := bloomHashes(fmt.ToLower(password))
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *BreachedSet) Contains(password string) bool {
+	if b.m == 0 {
+		return false
+	}
+	h1, h2 := bloomHashes(fmt.ToLower(password))
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes derives the two base hashes for double hashing from the two
+// halves of one SHA-256 digest, which are independent of each other — two
+// FNV passes over the same bytes are not. h2 is odd so every step moves.
+func bloomHashes(s string) (uint64, uint64) {
+	sum := sha256.Sum256([]byte(s))
+	return binary.LittleEndian.Uint64(sum[:8]), binary.LittleEndian.Uint64(sum[16:24]) | 1
+}
+
+// WritePasswordError answers 400 with a rejected password's error. A
+// *PolicyError goes out as {"error","reasons"} so the form can flag each rule;
+// anything else as plain text.
+func WritePasswordError(ctx router.Context, err error) {
+	if pe, ok := err.(*PolicyError); ok {
+		writeJSON(ctx, 400, pe)
+		return
+	}
+	ctx.WriteStatus(400)
+	ctx.Write([]byte(err.Error()))
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   int64 // ns of the last refill
+}
+
+// Limiter is the ready-made user.RateLimiter every login mode accepts through
+// its WithRateLimiter option: a token bucket per key, allowing burst attempts
+// at once, refilled at burst per window. It tracks at most maxKeys keys; when
+// full it drops the key seen longest ago, whose bucket is also the fullest.
+// Each call is O(1): a map finds the bucket, a list keeps them by last use.
+type Limiter struct {
+	mu      sync.Mutex
+	burst   float64
+	window  int64 // ns
+	maxKeys int
+	keys    map[string]*list.Element
+	lru     *list.List // of *bucket, most recently seen first
+}
+
+// New builds a Limiter. burst==0 defaults to 5, windowSeconds==0 to 60,
+// maxKeys==0 to 10000.
+func New(burst, windowSeconds, maxKeys int) *Limiter {
+	if burst == 0 {
+		burst = 5
+	}
+	if windowSeconds == 0 {
+		windowSeconds = 60
+	}
+	if maxKeys == 0 {
+		maxKeys = 10000
+	}
+	return &Limiter{
+		burst:   float64(burst),
+		window:  int64(windowSeconds) * 1e9,
+		maxKeys: maxKeys,
+		keys:    make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Allow spends one token from key's bucket, false when it is empty.
+func (l *Limiter) Allow(key string) bool {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.keys[key]; ok {
+		l.lru.MoveToFront(e)
+		b := e.Value.(*bucket)
+		l.refill(b, now)
+		if b.tokens < 1 {
+			return false
+		}
+		b.tokens--
+		return true
+	}
+
+	if l.lru.Len() >= l.maxKeys {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.keys, oldest.Value.(*bucket).key)
+	}
+	l.keys[key] = l.lru.PushFront(&bucket{key: key, tokens: l.burst - 1, last: now})
+	return true
+}
+
+func (l *Limiter) refill(b *bucket, now int64) {
+	b.tokens += float64(now-b.last) * l.burst / float64(l.window)
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+}
+
+var _ user.RateLimiter = (*Limiter)(nil)
@@ -0,0 +1,266 @@
+package user
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+)
+
+// Machine-readable failure codes a JSON login/logout response carries in
+// "error". The text in "message" is the usual translated error.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeSuspended          = "suspended"
+	CodeRateLimited        = "rate_limited"
+	CodeIPMismatch         = "ip_mismatch"
+	CodeAccountLocked      = "account_locked"
+	CodeEmailUnverified    = "email_unverified"
+	CodeInvalidState       = "invalid_state"
+	CodeInvalidToken       = "invalid_token"
+	CodeInvalidCode        = "invalid_code"
+	CodeMFARequired        = "mfa_required"
+	CodeIdentityLinked     = "identity_linked"
+	CodeAccountExists      = "account_exists"
+	CodeSignupClosed       = "signup_closed"
+	CodeServerError        = "server_error"
+)
+
+// WantsJSON reports whether the client asked for JSON (Accept:
+// application/json) — a WASM/SPA client — instead of the redirects an HTML
+// form follows.
+func WantsJSON(ctx router.Context) bool {
+	return fmt.Contains(ctx.GetHeader("Accept"), "application/json")
+}
+
+// LoginResult is the JSON body of a login or logout. On success it carries
+// the redirect target and, after a login, the ProfileDTO fields alongside it;
+// on failure only Code and Message.
+type LoginResult struct {
+	Redirect string
+	Profile  ProfileDTO
+	Code     string
+	Message  string
+}
+
+func (r *LoginResult) IsNil() bool { return r == nil }
+
+func (r *LoginResult) EncodeFields(w model.FieldWriter) {
+	if r.Code != "" {
+		w.String("error", r.Code)
+		w.String("message", r.Message)
+		return
+	}
+	w.String("redirect", r.Redirect)
+	if r.Profile.Id != "" {
+		r.Profile.EncodeFields(w)
+	}
+}
+
+func (r *LoginResult) DecodeFields(fr model.FieldReader) {
+	r.Code, _ = fr.String("error")
+	r.Message, _ = fr.String("message")
+	r.Redirect, _ = fr.String("redirect")
+	r.Profile.DecodeFields(fr)
+}
+
+// ProfileSource is optionally implemented by the IdentityStore a mode
+// receives (authority does): the same ProfileDTO the me op returns.
+type ProfileSource interface {
+	Profile(userID string) (ProfileDTO, error)
+}
+
+// ProfileOf builds u's ProfileDTO through store when it is a ProfileSource,
+// else from u's own fields.
+func ProfileOf(store IdentityStore, u User) ProfileDTO {
+	if ps, ok := store.(ProfileSource); ok {
+		if p, err := ps.Profile(u.Id); err == nil {
+			return p
+		}
+	}
+	return ProfileDTO{Id: u.Id, Name: u.Name, Email: u.Email, Avatar: u.Avatar}
+}
+
+// RespondLogin ends a successful login: a 302 to redirect for HTML forms, or
+// 200 with the redirect and u's ProfileDTO (ProfileOf store) for JSON clients.
+func RespondLogin(ctx router.Context, redirect string, store IdentityStore, u User) {
+	if WantsJSON(ctx) {
+		writeJSON(ctx, 200, &LoginResult{Redirect: redirect, Profile: ProfileOf(store, u)})
+		return
+	}
+	ctx.SetHeader("Location", redirect)
+	ctx.WriteStatus(302)
+}
+
+// RespondIssueError ends a login whose IssueSession failed. ErrMFARequired is
+// not a failure: the partial session is already set, so the client is sent on
+// to the second factor — a 303 to PathMFA for HTML forms, 401 mfa_required
+// for JSON clients.
+func RespondIssueError(ctx router.Context, err error) {
+	RespondIssueErrorNext(ctx, err, NextOr(ctx, ""))
+}
+
+// RespondIssueErrorNext is RespondIssueError for a login whose return path
+// isn't in this request (oauth2 keeps it with the state). next rides along
+// to PathMFA, so POST /mfa/verify?next= can finish the trip.
+func RespondIssueErrorNext(ctx router.Context, err error, next string) {
+	if err != ErrMFARequired {
+		RespondError(ctx, 500, CodeServerError, nil)
+		return
+	}
+	if WantsJSON(ctx) {
+		RespondError(ctx, 401, CodeMFARequired, err)
+		return
+	}
+	loc := PathMFA
+	if next != "" {
+		loc += "?next=" + escapeNext(next)
+	}
+	ctx.SetHeader("Location", loc)
+	ctx.WriteStatus(303)
+}
+
+// SafeNext returns next if a login may redirect there, else "". That is a
+// path on this origin ("/reports?id=3" — but not "//evil.com" or
+// "/\\evil.com", which browsers read as another host), or an absolute URL
+// on exactly one of origins, e.g. "https://admin.example.com".
+func SafeNext(next string, origins ...string) string {
+	for i := 0; i < len(next); i++ {
+		if c := next[i]; c < 0x20 || c == 0x7f || c == '\\' {
+			return ""
+		}
+	}
+	if fmt.HasPrefix(next, "/") {
+		if fmt.HasPrefix(next, "//") {
+			return ""
+		}
+		return next
+	}
+	for _, o := range origins {
+		if o == "" || !fmt.HasPrefix(next, o) {
+			continue
+		}
+		if len(next) == len(o) {
+			return next
+		}
+		switch next[len(o)] {
+		case '/', '?', '#':
+			return next
+		}
+	}
+	return ""
+}
+
+// NextOr is the request's ?next= when SafeNext (with origins) accepts it,
+// else fallback: where a login endpoint sends the user once it succeeds.
+func NextOr(ctx router.Context, fallback string, origins ...string) string {
+	next, ok := unescapeNext(QueryParam(ctx, "next"))
+	if !ok {
+		return fallback
+	}
+	if next = SafeNext(next, origins...); next == "" {
+		return fallback
+	}
+	return next
+}
+
+// unescapeNext percent-decodes a query value; ok is false if it is malformed.
+func unescapeNext(s string) (string, bool) {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '+':
+			b = append(b, ' ')
+		case c == '%':
+			if i+2 >= len(s) {
+				return "", false
+			}
+			hi, ok1 := unhex(s[i+1])
+			lo, ok2 := unhex(s[i+2])
+			if !ok1 || !ok2 {
+				return "", false
+			}
+			b = append(b, hi<<4|lo)
+			i += 2
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b), true
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// escapeNext percent-encodes s for a query value, leaving "/" readable.
+func escapeNext(s string) string {
+	const hex = "0123456789ABCDEF"
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b = append(b, c)
+		} else {
+			b = append(b, '%', hex[c>>4], hex[c&0x0F])
+		}
+	}
+	return string(b)
+}
+
+// Checked reads a checkbox the way both an HTML form ("on") and a JSON client
+// ("true", "1") send it.
+func Checked(v string) bool {
+	v = fmt.Convert(v).TrimSpace().ToLower().String()
+	return v == "on" || v == "true" || v == "1"
+}
+
+// RespondRedirect is RespondLogin without a user — logout.
+func RespondRedirect(ctx router.Context, redirect string) {
+	if WantsJSON(ctx) {
+		writeJSON(ctx, 200, &LoginResult{Redirect: redirect})
+		return
+	}
+	ctx.SetHeader("Location", redirect)
+	ctx.WriteStatus(302)
+}
+
+// RespondError ends a failed login: status with err's text for HTML forms, or
+// the same status with {"error": code, "message"} for JSON clients. A nil err
+// writes no text.
+func RespondError(ctx router.Context, status int, code string, err error) {
+	var msg string
+	if err != nil {
+		msg = err.Error()
+	}
+	if WantsJSON(ctx) {
+		writeJSON(ctx, status, &LoginResult{Code: code, Message: msg})
+		return
+	}
+	ctx.WriteStatus(status)
+	if msg != "" {
+		ctx.Write([]byte(msg))
+	}
+}
+
+// writeJSON sends v with an explicit status; ctx.Encode alone can't carry a
+// non-200 one.
+func writeJSON(ctx router.Context, status int, v model.Encodable) {
+	var body []byte
+	if err := json.Encode(v, &body); err != nil {
+		ctx.WriteStatus(500)
+		return
+	}
+	ctx.SetHeader("Content-Type", "application/json")
+	ctx.WriteStatus(status)
+	ctx.Write(body)
+}
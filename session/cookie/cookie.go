@@ -9,10 +9,11 @@ import (
 // cookie, backed by whatever SessionRepo the consumer injects (authority.Module
 // implements it with its own session table + in-memory cache).
 type Strategy struct {
-	repo       user.SessionRepo
-	name       string
-	ttl        int
-	trustProxy bool
+	repo        user.SessionRepo
+	name        string
+	ttl         int
+	rememberTTL int
+	trustProxy  bool
 }
 
 // New builds a cookie strategy. cookieName=="" defaults to "session"; ttl==0
@@ -27,16 +28,36 @@ func New(repo user.SessionRepo, cookieName string, ttl int, trustProxy bool) *St
 	return &Strategy{repo: repo, name: cookieName, ttl: ttl, trustProxy: trustProxy}
 }
 
-func (s *Strategy) Issue(ctx router.Context, userID string) error {
+// WithRememberTTL turns on remember-me (user.Config.RememberTTL): a session
+// issued WithRemember gets a cookie persisting ttl seconds, any other a
+// browser-session cookie. 0 = off.
+func (s *Strategy) WithRememberTTL(ttl int) *Strategy { s.rememberTTL = ttl; return s }
+
+// maxAge is the cookie lifetime for a session issued with o. A short-lived
+// session (o.TTL) gets a browser-session cookie.
+func (s *Strategy) maxAge(o user.SessionOptions) int {
+	switch {
+	case o.TTL > 0:
+		return 0
+	case s.rememberTTL == 0:
+		return s.ttl
+	case o.Remember:
+		return s.rememberTTL
+	default:
+		return 0
+	}
+}
+
+func (s *Strategy) Issue(ctx router.Context, userID string, opts ...user.SessionOption) error {
 	ip := user.ClientIP(ctx, s.trustProxy)
 	ua := ctx.GetHeader("User-Agent")
-	sess, err := s.repo.CreateSession(userID, ip, ua)
+	sess, err := s.repo.CreateSession(userID, ip, ua, opts...)
 	if err != nil {
 		return err
 	}
 	ctx.SetCookie(router.Cookie{
 		Name: s.name, Value: sess.Id, HttpOnly: true, Secure: true,
-		SameSite: router.SameSiteStrict, MaxAge: s.ttl, Path: "/",
+		SameSite: router.SameSiteStrict, MaxAge: s.maxAge(user.ApplySessionOptions(opts)), Path: "/",
 	})
 	return nil
 }
@@ -50,7 +71,16 @@ func (s *Strategy) Identify(ctx router.Context) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return sess.UserId, nil
+	return user.ScopedUserID(sess.Scope, sess.UserId), nil
+}
+
+// SessionID names the session row behind ctx's cookie, without validating it.
+func (s *Strategy) SessionID(ctx router.Context) (string, bool) {
+	c, ok := ctx.Cookie(s.name)
+	if !ok || c.Value == "" {
+		return "", false
+	}
+	return c.Value, true
 }
 
 func (s *Strategy) Revoke(ctx router.Context) error {
@@ -61,4 +91,7 @@ func (s *Strategy) Revoke(ctx router.Context) error {
 	return nil
 }
 
-var _ user.SessionStrategy = (*Strategy)(nil)
+var (
+	_ user.SessionStrategy = (*Strategy)(nil)
+	_ user.CurrentSession  = (*Strategy)(nil)
+)
@@ -21,12 +21,13 @@ var ErrJWTSecretRequired = fmt.Err("JWTSecret", "is", "required")
 // cookie.Strategy). bearer=true reads/writes via the "Authorization: Bearer"
 // header instead (API/MCP clients that can't use cookies) — call AsBearer().
 type Strategy struct {
-	secret     []byte
-	ttl        int
-	bearer     bool
-	cookieName string
-	notify     user.SecurityNotifier
-	users      user.IdentityStore
+	secret      []byte
+	ttl         int
+	rememberTTL int
+	bearer      bool
+	cookieName  string
+	notify      user.SecurityNotifier
+	users       user.IdentityStore
 }
 
 // New builds a JWT strategy. Fails fast if secret is empty — a JWT strategy with
@@ -44,12 +45,30 @@ func New(secret []byte, ttl int, notify user.SecurityNotifier, users user.Identi
 // WithCookieName overrides the cookie the JWT travels in (bearer mode ignores it).
 func (s *Strategy) WithCookieName(name string) *Strategy { s.cookieName = name; return s }
 
+// WithRememberTTL turns on remember-me: a session issued WithRemember gets a
+// token (and cookie) valid for ttl seconds; any other keeps the default ttl
+// in a browser-session cookie. 0 = off.
+func (s *Strategy) WithRememberTTL(ttl int) *Strategy { s.rememberTTL = ttl; return s }
+
 // AsBearer switches transport to the Authorization header — for stateless API
 // clients (MCP servers, IDEs, LLMs) that cannot use cookies.
 func (s *Strategy) AsBearer() *Strategy { s.bearer = true; return s }
 
-func (s *Strategy) Issue(ctx router.Context, userID string) error {
-	token, err := s.sign(userID, s.ttl)
+// Issue signs userID into the token's subject; a scoped session signs
+// user.ScopedUserID instead, which no older validator resolves to a user.
+func (s *Strategy) Issue(ctx router.Context, userID string, opts ...user.SessionOption) error {
+	o := user.ApplySessionOptions(opts)
+	ttl, maxAge := s.ttl, s.ttl
+	switch {
+	case o.TTL > 0:
+		ttl, maxAge = o.TTL, 0
+	case s.rememberTTL > 0:
+		maxAge = 0
+		if o.Remember {
+			ttl, maxAge = s.rememberTTL, s.rememberTTL
+		}
+	}
+	token, err := s.sign(user.ScopedUserID(o.Scope, userID), ttl)
 	if err != nil {
 		return err
 	}
@@ -58,7 +77,7 @@ func (s *Strategy) Issue(ctx router.Context, userID string) error {
 	}
 	ctx.SetCookie(router.Cookie{
 		Name: s.cookieName, Value: token, HttpOnly: true, Secure: true,
-		SameSite: router.SameSiteStrict, MaxAge: s.ttl, Path: "/",
+		SameSite: router.SameSiteStrict, MaxAge: maxAge, Path: "/",
 	})
 	return nil
 }
@@ -91,7 +110,8 @@ func (s *Strategy) Identify(ctx router.Context) (string, error) {
 		s.notify.Notify(user.SecurityEvent{Type: user.EventJWTTampered})
 		return "", errInvalidToken
 	}
-	u, err := s.users.UserByID(claims.Sub)
+	scope, userID := user.SplitScopedUserID(claims.Sub)
+	u, err := s.users.UserByID(userID)
 	if err != nil {
 		return "", err
 	}
@@ -99,7 +119,7 @@ func (s *Strategy) Identify(ctx router.Context) (string, error) {
 		s.notify.Notify(user.SecurityEvent{Type: user.EventNonActiveAccess, UserID: u.Id})
 		return "", user.ErrSuspended
 	}
-	return u.Id, nil
+	return user.ScopedUserID(scope, u.Id), nil
 }
 
 func (s *Strategy) Revoke(ctx router.Context) error {
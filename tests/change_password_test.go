@@ -0,0 +1,96 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestChangePassword(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	pub := &mockPublisher{}
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub, RevokeOnPasswordChange: true})
+	email := "change@test.com"
+	if err := m.Bootstrap(authority.Seed{Email: email, Password: "old-password", Name: "Change", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := m.GetUserByEmail(email)
+
+	reg := &mockOpRegistry{ops: make(map[string]*mockRoute)}
+	m.MountOps(reg)
+	route := reg.ops[user.OpChangePassword]
+	if route == nil {
+		t.Fatal("change_password op not registered")
+	}
+	anon := &mock.Context{}
+	route.handler(anon)
+	if anon.Status != 401 {
+		t.Fatalf("anonymous change_password: %d", anon.Status)
+	}
+
+	current, _ := m.CreateSession(u.Id, "1.1.1.1", "this browser")
+	other, _ := m.CreateSession(u.Id, "2.2.2.2", "other browser")
+
+	call := func(data *user.PasswordData) *mock.Context {
+		ctx := &mock.Context{}
+		ctx.SetUserID(u.Id)
+		ctx.SetCookie(router.Cookie{Name: "session", Value: current.Id})
+		json.Encode(data, &ctx.InBody)
+		route.handler(ctx)
+		return ctx
+	}
+
+	cases := []struct {
+		name   string
+		data   *user.PasswordData
+		status int
+		body   string
+	}{
+		{"Wrong current", &user.PasswordData{Current: "nope-nope", New: "new-password", Confirm: "new-password"}, 403, user.ErrInvalidCredentials.Error()},
+		{"Confirm mismatch", &user.PasswordData{Current: "old-password", New: "new-password", Confirm: "new-passw0rd"}, 400, user.ErrPasswordMismatch.Error()},
+		{"Weak new", &user.PasswordData{Current: "old-password", New: "short", Confirm: "short"}, 400, user.ErrWeakPassword.Error()},
+	}
+	for _, tc := range cases {
+		ctx := call(tc.data)
+		if ctx.Status != tc.status || string(ctx.ResponseBody()) != tc.body {
+			t.Errorf("%s: got %d %q", tc.name, ctx.Status, ctx.ResponseBody())
+		}
+	}
+	if _, err := m.GetSession(other.Id); err != nil {
+		t.Fatal("a rejected change revoked sessions")
+	}
+
+	ctx := call(&user.PasswordData{Current: "old-password", New: "new-password", Confirm: "new-password"})
+	if ctx.Status != 204 {
+		t.Fatalf("change: %d %q", ctx.Status, ctx.ResponseBody())
+	}
+	if _, err := m.Login(email, "new-password"); err != nil {
+		t.Errorf("login with new password: %v", err)
+	}
+	if _, err := m.GetSession(current.Id); err != nil {
+		t.Error("the caller's own session was revoked")
+	}
+	if _, err := m.GetSession(other.Id); err == nil {
+		t.Error("other session survived the change")
+	}
+
+	found := false
+	for _, e := range pub.SecurityEvents() {
+		if e.Type == user.EventPasswordChanged && e.UserID == u.Id {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("EventPasswordChanged not published")
+	}
+}
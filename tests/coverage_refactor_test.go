@@ -317,7 +317,7 @@ func TestCoverage_OAuth2(t *testing.T) {
 	}
 
 	r := &mock.Router{}
-	m.Enable(oauth2.New(m, m, m, []user.OAuthProvider{mockP}, oauth2.WithAfterLogin("/custom_after")))
+	m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{mockP}, oauth2.WithAfterLogin("/custom_after")))
 	m.MountAPI(r)
 
 	t.Run("Replay state token error", func(t *testing.T) {
@@ -358,10 +358,10 @@ func TestCoverage_OAuth2(t *testing.T) {
 		mockP2 := &MockProvider{
 			NameVal:         "covmock2",
 			ExchangeCodeVal: user.OAuthToken{AccessToken: "covtoken2"},
-			UserInfoVal:     user.OAuthUserInfo{ID: "cSubject2", Email: "link@test.com", Name: "Same Email OAuth"},
+			UserInfoVal:     user.OAuthUserInfo{ID: "cSubject2", Email: "link@test.com", Name: "Same Email OAuth", EmailVerified: true},
 		}
 
-		m.Enable(oauth2.New(m, m, m, []user.OAuthProvider{mockP2}))
+		m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{mockP2}))
 		m.MountAPI(r)
 
 		ctxBegin := &mock.Context{InMethod: "GET", InPath: "/oauth/covmock2"}
@@ -0,0 +1,140 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestEmailVerification(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	pub := &mockPublisher{}
+	mailer := &mockMailer{}
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub, CookieName: "sess"})
+	m.Enable(emailpassword.New(m, m, m,
+		emailpassword.WithRegister(m),
+		emailpassword.WithEmailVerificationLogin(m, mailer, "https://app.test"),
+	))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	email, pass := "pending@test.com", "password123"
+	ctx := postRegister(r, &user.RegisterData{Name: "Pending", Email: email, Password: pass})
+	if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathLogin {
+		t.Fatalf("register: %d %q", ctx.Status, ctx.GetHeader("Location"))
+	}
+	if _, ok := ctx.Cookie("sess"); ok {
+		t.Error("register issued a session for a pending account")
+	}
+	u, _ := m.GetUserByEmail(email)
+	if u.Status != "pending" {
+		t.Fatalf("expected pending status, got %q", u.Status)
+	}
+	if sent := mailer.wait(t, 1); len(sent) != 1 || sent[0].kind != user.MailVerifyEmail || sent[0].to != email {
+		t.Fatalf("unexpected mails: %+v", sent)
+	}
+
+	login := func(pass string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+		ctx.SetHeader("Content-Type", "application/json")
+		json.Encode(&user.LoginData{Email: email, Password: pass}, &ctx.InBody)
+		r.Invoke("POST", user.PathLogin, ctx)
+		return ctx
+	}
+
+	t.Run("Pending is reported only with the right password", func(t *testing.T) {
+		if ctx := login("wrong-password"); ctx.Status != 401 || string(ctx.ResponseBody()) != "access denied" {
+			t.Errorf("wrong password: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if ctx := login(pass); ctx.Status != 403 || string(ctx.ResponseBody()) != user.ErrEmailUnverified.Error() {
+			t.Errorf("right password: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		pendingAccesses := func() int {
+			n := 0
+			for _, e := range pub.SecurityEvents() {
+				if e.Type == user.EventPendingAccess && e.UserID == u.Id {
+					n++
+				}
+			}
+			return n
+		}
+		if pendingAccesses() != 1 {
+			t.Errorf("route: %d EventPendingAccess, want 1", pendingAccesses())
+		}
+		if _, err := m.Login(email, pass); err != user.ErrEmailUnverified {
+			t.Errorf("Module.Login: expected ErrEmailUnverified, got %v", err)
+		}
+		if pendingAccesses() != 2 {
+			t.Error("Module.Login published no EventPendingAccess")
+		}
+	})
+
+	t.Run("Resend replaces the link", func(t *testing.T) {
+		resend := func(email string) *mock.Context {
+			ctx := &mock.Context{InMethod: "POST", InPath: user.PathVerifyEmailResend}
+			ctx.SetHeader("Content-Type", "application/json")
+			json.Encode(&user.ForgotData{Email: email}, &ctx.InBody)
+			r.Invoke("POST", user.PathVerifyEmailResend, ctx)
+			return ctx
+		}
+		stale := mailer.lastToken()
+		for _, to := range []string{"nobody@test.com", email} {
+			if ctx := resend(to); ctx.Status != 202 || len(ctx.ResponseBody()) != 0 {
+				t.Errorf("resend to %s: %d %q", to, ctx.Status, ctx.ResponseBody())
+			}
+		}
+		mailer.wait(t, 2)
+		if sent := mailer.settle(); len(sent) != 2 || sent[1].kind != user.MailVerifyEmail || sent[1].to != email {
+			t.Fatalf("unexpected mails: %+v", sent)
+		}
+		old := &mock.Context{InMethod: "GET", InPath: user.PathVerifyEmail + "?token=" + stale}
+		r.Invoke("GET", user.PathVerifyEmail, old)
+		if old.Status != 400 {
+			t.Errorf("superseded link: %d", old.Status)
+		}
+	})
+
+	t.Run("Link activates the account once", func(t *testing.T) {
+		token := mailer.lastToken()
+		path := user.PathVerifyEmail + "?token=" + token
+		ctx := &mock.Context{InMethod: "GET", InPath: path}
+		r.Invoke("GET", user.PathVerifyEmail, ctx)
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathAfterLogin {
+			t.Fatalf("verify: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if c, ok := ctx.Cookie("sess"); !ok || c.Value == "" {
+			t.Error("the verified link issued no session")
+		}
+		if ctx := login(pass); ctx.Status != 302 {
+			t.Errorf("login after verification: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+
+		replay := &mock.Context{InMethod: "GET", InPath: path}
+		r.Invoke("GET", user.PathVerifyEmail, replay)
+		if replay.Status != 400 {
+			t.Errorf("replayed link: %d", replay.Status)
+		}
+	})
+
+	t.Run("Link does not lift a suspension", func(t *testing.T) {
+		token, err := m.CreateVerification(u.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.SuspendUser(u.Id)
+		if _, err := m.VerifyEmail(token); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := m.GetUser(u.Id); got.Status != "suspended" {
+			t.Errorf("expected suspended, got %q", got.Status)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestForcedPasswordChange(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess"})
+	m.Enable(emailpassword.New(m, m, m))
+	r := &mock.Router{}
+	m.MountAPI(r)
+	reg := &mockOpRegistry{ops: make(map[string]*mockRoute)}
+	m.MountOps(reg)
+
+	email := "temp@test.com"
+	if err := m.Bootstrap(authority.Seed{Email: email, Password: "temporary-1", Name: "Temp", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}, MustChangePassword: true}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := m.GetUserByEmail(email)
+
+	// identify runs the Authenticate middleware and returns what it set.
+	identify := func(cookie string) string {
+		ctx := &mock.Context{}
+		ctx.SetCookie(router.Cookie{Name: "sess", Value: cookie})
+		var id string
+		m.Authenticate()(func(c router.Context) { id = c.UserID() })(ctx)
+		return id
+	}
+	// me calls the op the way a request would: with the cookie, and with
+	// whatever Authenticate made of it.
+	me := func(cookie string) user.ProfileDTO {
+		ctx := &mock.Context{}
+		ctx.SetCookie(router.Cookie{Name: "sess", Value: cookie})
+		ctx.SetUserID(identify(cookie))
+		reg.ops[user.OpMe].handler(ctx)
+		var p user.ProfileDTO
+		json.Decode(ctx.ResponseBody(), &p)
+		return p
+	}
+
+	login := &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+	login.SetHeader("Content-Type", "application/json")
+	json.Encode(&user.LoginData{Email: email, Password: "temporary-1"}, &login.InBody)
+	r.Invoke("POST", user.PathLogin, login)
+	c, ok := login.Cookie("sess")
+	if login.Status != 302 || !ok {
+		t.Fatalf("login: %d", login.Status)
+	}
+
+	if got := identify(c.Value); got != "" {
+		t.Fatalf("restricted session set ctx.UserID %q", got)
+	}
+	if m.Can(user.ScopedUserID(user.OpChangePassword, u.Id), model.Resource("anything"), model.Read) {
+		t.Error("restricted session passed an authorization check")
+	}
+	if p := me(c.Value); p.Id != u.Id || !p.MustChangePassword {
+		t.Errorf("me for restricted session: %+v", p)
+	}
+
+	ctx := &mock.Context{}
+	ctx.SetCookie(router.Cookie{Name: "sess", Value: c.Value})
+	json.Encode(&user.PasswordData{Current: "temporary-1", New: "my-own-secret", Confirm: "my-own-secret"}, &ctx.InBody)
+	reg.ops[user.OpChangePassword].handler(ctx)
+	if ctx.Status != 204 {
+		t.Fatalf("change_password: %d %q", ctx.Status, ctx.ResponseBody())
+	}
+
+	if p := me(c.Value); p.Id != "" {
+		t.Error("restricted session still valid after the change")
+	}
+	full, ok := ctx.Cookie("sess")
+	if !ok || identify(full.Value) != u.Id {
+		t.Fatal("change_password did not issue a full session")
+	}
+	if p := me(full.Value); p.MustChangePassword {
+		t.Error("flag still set after the change")
+	}
+}
+
+func TestPasswordMaxAge(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, PasswordMaxAge: 1})
+	u, _ := m.CreateUser("aging@test.com", "Aging", "")
+	if err := m.SetPassword(u.Id, "fresh-password"); err != nil {
+		t.Fatal(err)
+	}
+	if m.MustChangePassword(u.Id) {
+		t.Fatal("fresh password flagged")
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if !m.MustChangePassword(u.Id) {
+		t.Error("password past PasswordMaxAge not flagged")
+	}
+	if err := m.SetPassword(u.Id, "newer-password"); err != nil {
+		t.Fatal(err)
+	}
+	if m.MustChangePassword(u.Id) {
+		t.Error("flag survived a new password")
+	}
+
+	// No password at all: nothing to expire, however long it has been.
+	sso, _ := m.CreateUser("sso@test.com", "Sso", "")
+	m.UpsertIdentity(sso.Id, "google", "sub-1", "sso@test.com")
+	m.MustChangePassword(sso.Id)
+	time.Sleep(1100 * time.Millisecond)
+	if m.MustChangePassword(sso.Id) {
+		t.Error("user without a password flagged")
+	}
+}
+
+func TestSetTemporaryPasswordOp(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess", RevokeOnPasswordChange: true})
+	m.Enable(emailpassword.New(m, m, m))
+	reg := &mockOpRegistry{ops: make(map[string]*mockRoute)}
+	m.MountOps(reg)
+	route := reg.ops[user.OpSetTemporaryPassword]
+	if route == nil || route.requiredRes != "users" || route.requiredAct != model.Update {
+		t.Fatal("set_temporary_password must require users:update")
+	}
+
+	u, _ := m.CreateUser("handed@test.com", "Handed", "")
+	old, _ := m.CreateSession(u.Id, "1.1.1.1", "old browser")
+	set := func(data *user.TemporaryPasswordData) *mock.Context {
+		ctx := &mock.Context{}
+		json.Encode(data, &ctx.InBody)
+		route.handler(ctx)
+		return ctx
+	}
+
+	if ctx := set(&user.TemporaryPasswordData{UserId: "nobody", Password: "temporary-1"}); ctx.Status != 404 {
+		t.Errorf("unknown user: %d", ctx.Status)
+	}
+	if ctx := set(&user.TemporaryPasswordData{UserId: u.Id, Password: "short"}); ctx.Status != 400 {
+		t.Errorf("weak password: %d", ctx.Status)
+	}
+	if ctx := set(&user.TemporaryPasswordData{UserId: u.Id, Password: "temporary-1"}); ctx.Status != 204 {
+		t.Fatalf("set: %d %q", ctx.Status, ctx.ResponseBody())
+	}
+	if !m.MustChangePassword(u.Id) {
+		t.Error("admin-set password not flagged")
+	}
+	if _, err := m.GetSession(old.Id); err == nil {
+		t.Error("existing session survived an admin-set password")
+	}
+	if _, err := m.Login("handed@test.com", "temporary-1"); err != nil {
+		t.Errorf("login with the handed-out password: %v", err)
+	}
+}
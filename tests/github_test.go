@@ -0,0 +1,140 @@
+//go:build !wasm
+
+package tests
+
+import (
+	stdjson "encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	"github.com/tinywasm/user/oauth2"
+	"github.com/tinywasm/user/oauth2/provider/github"
+)
+
+// testGitHub stands in for github.com and api.github.com: the token endpoint
+// answers JSON only when asked to, and errors with a 200 as GitHub does.
+type testGitHub struct {
+	srv *httptest.Server
+
+	mu     sync.Mutex
+	emails []map[string]any
+}
+
+func newTestGitHub(t *testing.T) *testGitHub {
+	t.Helper()
+	gh := &testGitHub{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Header.Get("Accept") != "application/json" {
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			w.Write([]byte("access_token=gho_x&token_type=bearer"))
+			return
+		}
+		if r.PostForm.Get("code") != "good" {
+			stdjson.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		stdjson.NewEncoder(w).Encode(map[string]string{"access_token": "gho_x", "token_type": "bearer", "scope": "read:user,user:email"})
+	})
+	authed := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer gho_x" {
+			w.WriteHeader(401)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/api/user", func(w http.ResponseWriter, r *http.Request) {
+		if authed(w, r) {
+			stdjson.NewEncoder(w).Encode(map[string]any{"id": 583231, "login": "octocat", "name": nil, "email": nil, "avatar_url": "https://avatars.example/u/583231"})
+		}
+	})
+	mux.HandleFunc("/api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		if authed(w, r) {
+			gh.mu.Lock()
+			defer gh.mu.Unlock()
+			stdjson.NewEncoder(w).Encode(gh.emails)
+		}
+	})
+	gh.srv = httptest.NewServer(mux)
+	t.Cleanup(gh.srv.Close)
+	return gh
+}
+
+func TestGitHubProvider(t *testing.T) {
+	gh := newTestGitHub(t)
+	p := &github.GitHubProvider{
+		ClientID: "client", ClientSecret: "secret", RedirectURL: "https://app.example.com/oauth/callback/github",
+		AuthURL: gh.srv.URL + "/login/oauth/authorize", TokenURL: gh.srv.URL + "/login/oauth/access_token", APIURL: gh.srv.URL + "/api",
+	}
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+	m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p}))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	login := func(t *testing.T, code string, emails ...map[string]any) *mock.Context {
+		t.Helper()
+		gh.mu.Lock()
+		gh.emails = emails
+		gh.mu.Unlock()
+		begin := &mock.Context{InMethod: "GET", InPath: "/oauth/github"}
+		r.Invoke("GET", "/oauth/github", begin)
+		loc, err := url.Parse(begin.GetHeader("Location"))
+		if begin.Status != 302 || err != nil || !strings.HasPrefix(loc.String(), gh.srv.URL+"/login/oauth/authorize?") {
+			t.Fatalf("begin: %d %q", begin.Status, begin.GetHeader("Location"))
+		}
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/github?state=" + url.QueryEscape(loc.Query().Get("state")) + "&code=" + code}
+		r.Invoke("GET", "/oauth/callback/github", cb)
+		return cb
+	}
+
+	t.Run("Private primary email", func(t *testing.T) {
+		cb := login(t, "good",
+			map[string]any{"email": "old@example.com", "primary": false, "verified": true},
+			map[string]any{"email": "octo@example.com", "primary": true, "verified": true},
+		)
+		if cb.Status != 302 {
+			t.Fatalf("login: %d %q", cb.Status, cb.ResponseBody())
+		}
+		u, err := m.UserByEmail("octo@example.com")
+		if err != nil || u.Name != "octocat" || u.Avatar != "https://avatars.example/u/583231" {
+			t.Errorf("user from profile: %+v %v", u, err)
+		}
+		if id, err := m.IdentityByProvider("github", "583231"); err != nil || id.UserId != u.Id {
+			t.Errorf("identity not keyed by numeric id: %+v %v", id, err)
+		}
+	})
+
+	t.Run("Unverified primary falls back to a verified one", func(t *testing.T) {
+		cb := login(t, "good",
+			map[string]any{"email": "new@example.com", "primary": true, "verified": false},
+			map[string]any{"email": "octo@example.com", "primary": false, "verified": true},
+		)
+		if cb.Status != 302 {
+			t.Fatalf("login: %d %q", cb.Status, cb.ResponseBody())
+		}
+		if _, err := m.UserByEmail("new@example.com"); err == nil {
+			t.Error("account created for an unverified address")
+		}
+	})
+
+	t.Run("No verified email", func(t *testing.T) {
+		if cb := login(t, "good", map[string]any{"email": "x@example.com", "primary": true, "verified": false}); cb.Status != 401 {
+			t.Errorf("accepted: %d", cb.Status)
+		}
+	})
+
+	t.Run("Bad code answered with 200", func(t *testing.T) {
+		if cb := login(t, "bad", map[string]any{"email": "octo@example.com", "primary": true, "verified": true}); cb.Status != 401 {
+			t.Errorf("accepted: %d", cb.Status)
+		}
+	})
+}
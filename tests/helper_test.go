@@ -4,7 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"sync"
+	"testing"
+	"time"
 
 	"github.com/tinywasm/events"
 	"github.com/tinywasm/model"
@@ -90,3 +93,61 @@ func (reg *mockOpRegistry) Op(name string, h router.HandlerFunc) router.Route {
 	reg.ops[name] = r
 	return r
 }
+
+type sentMail struct {
+	to   string
+	kind user.MailKind
+	link string
+}
+
+type mockMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+}
+
+func (m *mockMailer) Send(to string, kind user.MailKind, link string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMail{to: to, kind: kind, link: link})
+	return nil
+}
+
+// wait blocks until n mails have gone out — the public endpoints send after
+// answering — and returns what was sent.
+func (m *mockMailer) wait(t *testing.T, n int) []sentMail {
+	t.Helper()
+	for i := 0; ; i++ {
+		m.mu.Lock()
+		sent := append([]sentMail(nil), m.sent...)
+		m.mu.Unlock()
+		if len(sent) >= n {
+			return sent
+		}
+		if i == 200 {
+			t.Fatalf("waited for %d mails, got %+v", n, sent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// settle gives sends still in flight time to land and returns what was sent.
+func (m *mockMailer) settle() []sentMail {
+	time.Sleep(50 * time.Millisecond)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]sentMail(nil), m.sent...)
+}
+
+// lastToken returns the token query parameter of the most recent link.
+func (m *mockMailer) lastToken() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		return ""
+	}
+	link := m.sent[len(m.sent)-1].link
+	if i := strings.Index(link, "token="); i >= 0 {
+		return link[i+len("token="):]
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	magiclink "github.com/tinywasm/user/magic_link"
+	"github.com/tinywasm/user/oauth2"
+	"github.com/tinywasm/user/webauthn"
+)
+
+func TestIdentityLinking(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	pub := &mockPublisher{}
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub})
+	prov := &MockProvider{
+		NameVal:         "mock",
+		ExchangeCodeVal: user.OAuthToken{AccessToken: "mocktoken"},
+		UserInfoVal:     user.OAuthUserInfo{ID: "mock-1", Email: "elsewhere@example.com", Name: "Elsewhere"},
+	}
+	m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{prov}, oauth2.WithAfterLink("/settings")))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	owner, _ := m.CreateUser("owner@example.com", "Owner", "")
+	if err := m.SetPassword(owner.Id, "owner-password"); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := m.CreateUser("other@example.com", "Other", "")
+
+	begin := func(as string) *mock.Context {
+		ctx := &mock.Context{InMethod: "GET", InPath: "/oauth/link/mock"}
+		ctx.SetUserID(as)
+		r.Invoke("GET", "/oauth/link/mock", ctx)
+		return ctx
+	}
+	// link runs /oauth/link/mock as beginAs, then the callback the way the
+	// IdP's cross-site redirect arrives: no session, only the Lax link cookie
+	// — and not even that when sameBrowser is false.
+	link := func(t *testing.T, beginAs string, sameBrowser bool) *mock.Context {
+		t.Helper()
+		b := begin(beginAs)
+		if b.Status != 302 {
+			t.Fatalf("begin as %q: %d", beginAs, b.Status)
+		}
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/mock?state=" + strings.TrimPrefix(b.GetHeader("Location"), "http://mock/") + "&code=c"}
+		cb.SetHeader("Accept", "application/json")
+		if c, ok := b.Cookie(oauth2.LinkCookie); ok && sameBrowser {
+			cb.SetCookie(c)
+		}
+		r.Invoke("GET", "/oauth/callback/mock", cb)
+		return cb
+	}
+
+	t.Run("Link", func(t *testing.T) {
+		if ctx := begin(""); ctx.Status != 401 {
+			t.Errorf("anonymous link: %d", ctx.Status)
+		}
+		if ctx := begin(user.ScopedUserID(user.ScopeMFA, owner.Id)); ctx.Status != 401 {
+			t.Errorf("partial session link: %d", ctx.Status)
+		}
+		if ctx := link(t, owner.Id, false); ctx.Status != 401 {
+			t.Errorf("callback finished outside the browser that began it: %d", ctx.Status)
+		}
+		if _, err := m.IdentityByProvider("mock", "mock-1"); err == nil {
+			t.Fatal("identity linked by a foreign callback")
+		}
+
+		ctx := link(t, owner.Id, true)
+		if ctx.Status != 302 || ctx.GetHeader("Location") != "/settings" {
+			t.Fatalf("link: %d %q", ctx.Status, ctx.GetHeader("Location"))
+		}
+		if id, err := m.IdentityByProvider("mock", "mock-1"); err != nil || id.UserId != owner.Id {
+			t.Errorf("linked identity: %+v %v", id, err)
+		}
+		if _, err := m.UserByEmail("elsewhere@example.com"); err == nil {
+			t.Error("link created a user")
+		}
+		if !hasEvent(pub, user.EventIdentityLinked) {
+			t.Error("no EventIdentityLinked")
+		}
+
+		ctx = link(t, other.Id, true)
+		var res user.LoginResult
+		json.Decode(ctx.ResponseBody(), &res)
+		if ctx.Status != 409 || res.Code != user.CodeIdentityLinked {
+			t.Errorf("provider account of another user: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+	})
+
+	reg := &mockOpRegistry{ops: make(map[string]*mockRoute)}
+	m.MountOps(reg)
+	list := func() []*user.Identity {
+		ctx := &mock.Context{}
+		ctx.SetUserID(owner.Id)
+		reg.ops[user.OpListIdentities].handler(ctx)
+		if strings.Contains(string(ctx.ResponseBody()), "$2") {
+			t.Fatalf("password hash listed: %s", ctx.ResponseBody())
+		}
+		var ids user.IdentityList
+		json.Decode(ctx.ResponseBody(), &ids)
+		return ids
+	}
+	unlink := func(id string) *mock.Context {
+		ctx := &mock.Context{}
+		ctx.SetUserID(owner.Id)
+		json.Encode(&user.Identity{Id: id}, &ctx.InBody)
+		reg.ops[user.OpUnlinkIdentity].handler(ctx)
+		return ctx
+	}
+	idOf := func(provider string) string {
+		for _, i := range list() {
+			if i.Provider == provider {
+				return i.Id
+			}
+		}
+		t.Fatalf("no %s identity listed", provider)
+		return ""
+	}
+
+	t.Run("List and unlink", func(t *testing.T) {
+		if !reg.ops[user.OpListIdentities].authenticated || !reg.ops[user.OpUnlinkIdentity].authenticated {
+			t.Fatal("identity ops must be Authenticated")
+		}
+		if n := len(list()); n != 2 {
+			t.Fatalf("listed %d identities, want 2", n)
+		}
+		if ctx := unlink("no-such-id"); ctx.Status != 404 {
+			t.Errorf("unknown identity: %d", ctx.Status)
+		}
+		if ctx := unlink(idOf("email_password")); ctx.Status != 204 {
+			t.Fatalf("unlink password: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if _, err := m.Login("owner@example.com", "owner-password"); err == nil {
+			t.Error("password still logs in after unlink")
+		}
+		last := idOf("mock")
+		if ctx := unlink(last); ctx.Status != 409 || string(ctx.ResponseBody()) != user.ErrCannotUnlink.Error() {
+			t.Errorf("last login method: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+
+		// A mailed link still reaches the account, so the last identity can go.
+		m.Enable(magiclink.New(m, m, m, m, &mockMailer{}, "https://app.test"))
+		if ctx := unlink(last); ctx.Status != 204 {
+			t.Errorf("unlink with magic_link enabled: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if !hasEvent(pub, user.EventIdentityUnlinked) {
+			t.Error("no EventIdentityUnlinked")
+		}
+	})
+
+	t.Run("Passkey goes with its identity", func(t *testing.T) {
+		m.Enable(webauthn.New(m, m, m, m, m, webauthn.RelyingParty{ID: "app.test", Name: "App", Origin: "https://app.test"}))
+		if err := m.SaveCredential(user.WebAuthnCredential{Id: "cred-1", UserId: owner.Id, PublicKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.UnlinkIdentity(owner.Id, "webauthn"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.CredentialByID("cred-1"); err == nil {
+			t.Error("credential survived its identity")
+		}
+		if !hasEvent(pub, user.EventMFADisabled) {
+			t.Error("removing the last passkey factor reported no EventMFADisabled")
+		}
+	})
+}
+
+func hasEvent(pub *mockPublisher, typ user.SecurityEventType) bool {
+	for _, e := range pub.SecurityEvents() {
+		if e.Type == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	trustedip "github.com/tinywasm/user/trusted_ip"
+)
+
+func TestJSONResponses(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess"})
+	m.Enable(emailpassword.New(m, m, m))
+	m.Enable(trustedip.New(m, m, m, m, true))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	email := "spa@test.com"
+	if err := m.Bootstrap(authority.Seed{Email: email, Password: "password123", Name: "Spa", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := m.GetUserByEmail(email)
+	m.RegisterLAN(u.Id, "12345678-5")
+	m.AssignLANIP(u.Id, "10.0.0.1", "Office")
+
+	post := func(path, body string, accept bool) (*mock.Context, user.LoginResult) {
+		ctx := &mock.Context{InMethod: "POST", InPath: path, InBody: []byte(body)}
+		ctx.SetHeader("Content-Type", "application/json")
+		if accept {
+			ctx.SetHeader("Accept", "application/json")
+		}
+		ctx.SetValue("RemoteAddr", "10.0.0.2:1234")
+		r.Invoke("POST", path, ctx)
+		var res user.LoginResult
+		if accept {
+			if err := json.Decode(ctx.ResponseBody(), &res); err != nil {
+				t.Fatalf("%s: body is not JSON: %q", path, ctx.ResponseBody())
+			}
+		}
+		return ctx, res
+	}
+	loginBody := func(pass string) string {
+		var s string
+		json.Encode(&user.LoginData{Email: email, Password: pass}, &s)
+		return s
+	}
+
+	t.Run("Login success carries profile and redirect", func(t *testing.T) {
+		ctx, res := post(user.PathLogin, loginBody("password123"), true)
+		if ctx.Status != 200 || ctx.GetHeader("Location") != "" {
+			t.Fatalf("status %d, location %q", ctx.Status, ctx.GetHeader("Location"))
+		}
+		if res.Redirect != user.PathAfterLogin || res.Profile.Id != u.Id || len(res.Profile.Permissions) != 1 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		if _, ok := ctx.Cookie("sess"); !ok {
+			t.Error("session cookie missing")
+		}
+	})
+
+	t.Run("Login failure carries a code", func(t *testing.T) {
+		ctx, res := post(user.PathLogin, loginBody("wrong-password"), true)
+		if ctx.Status != 401 || res.Code != user.CodeInvalidCredentials || res.Message != user.ErrInvalidCredentials.Error() {
+			t.Errorf("got %d %+v", ctx.Status, res)
+		}
+	})
+
+	t.Run("HTML forms still redirect", func(t *testing.T) {
+		ctx, _ := post(user.PathLogin, loginBody("password123"), false)
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathAfterLogin {
+			t.Errorf("got %d %q", ctx.Status, ctx.GetHeader("Location"))
+		}
+	})
+
+	t.Run("Trusted IP mismatch", func(t *testing.T) {
+		ctx, res := post("/login/rut", `{"rut":"12345678-5"}`, true)
+		if ctx.Status != 401 || res.Code != user.CodeIPMismatch {
+			t.Errorf("got %d %+v", ctx.Status, res)
+		}
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLogout}
+		ctx.SetHeader("Accept", "application/json")
+		ctx.SetCookie(router.Cookie{Name: "sess", Value: "whatever"})
+		r.Invoke("POST", user.PathLogout, ctx)
+		var res user.LoginResult
+		json.Decode(ctx.ResponseBody(), &res)
+		if ctx.Status != 200 || res.Redirect != user.PathLogin {
+			t.Errorf("got %d %+v", ctx.Status, res)
+		}
+	})
+}
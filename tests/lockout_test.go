@@ -0,0 +1,142 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/orm"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestAccountLockout(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	db := newTestDB(t)
+	pub := &mockPublisher{}
+	cfg := user.Config{IDs: testIDs, Events: pub, LockThreshold: 3, LockWindow: 60, LockMaxWindow: 3600}
+	m, _ := authority.New(db, cfg)
+	m.Enable(emailpassword.New(m, m, m, emailpassword.WithLockout(m)))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	email, pass := "locked@test.com", "password123"
+	if err := m.Bootstrap(authority.Seed{Email: email, Password: pass, Name: "Locked", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := m.GetUserByEmail(email)
+
+	login := func(email, pass string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+		ctx.SetValue("RemoteAddr", "9.9.9.9:1234")
+		ctx.SetHeader("Content-Type", "application/json")
+		json.Encode(&user.LoginData{Email: email, Password: pass}, &ctx.InBody)
+		r.Invoke("POST", user.PathLogin, ctx)
+		return ctx
+	}
+	lockedFor := func(key string) int64 {
+		rows, _ := user.ReadAllLoginLock(db.Query(&user.LoginLock{}).Where(user.LoginLock_.Identifier).Eq(key))
+		if len(rows) == 0 {
+			return 0
+		}
+		return rows[0].LockedUntil - time.Now().Unix()
+	}
+
+	t.Run("Locks after the threshold", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if ctx := login(email, "wrong-password"); ctx.Status != 401 {
+				t.Fatalf("attempt %d: expected 401, got %d", i+1, ctx.Status)
+			}
+		}
+		ctx := login(email, pass)
+		if ctx.Status != 423 || string(ctx.ResponseBody()) != user.ErrAccountLocked.Error() {
+			t.Fatalf("right password while locked: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if _, err := m.Login(email, pass); err != user.ErrAccountLocked {
+			t.Errorf("Module.Login while locked: %v", err)
+		}
+
+		found := false
+		for _, e := range pub.SecurityEvents() {
+			if e.Type == user.EventAccountLocked && e.UserID == email && e.IP == "9.9.9.9" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("EventAccountLocked not published")
+		}
+	})
+
+	t.Run("Survives a restart", func(t *testing.T) {
+		m2, _ := authority.New(db, cfg)
+		if !m2.Locked(email) {
+			t.Error("lock lost when the module was rebuilt")
+		}
+	})
+
+	t.Run("Unknown emails lock too", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			login("ghost@test.com", "whatever-pass")
+		}
+		if ctx := login("ghost@test.com", "whatever-pass"); ctx.Status != 423 {
+			t.Errorf("expected 423 for a locked unknown email, got %d", ctx.Status)
+		}
+	})
+
+	t.Run("Windows grow exponentially", func(t *testing.T) {
+		if w := lockedFor(email); w < 55 || w > 60 {
+			t.Fatalf("first lock should last ~60s, got %d", w)
+		}
+		// Let the first lock run out without forgetting it.
+		db.Update(&user.LoginLock{Identifier: email, LockCount: 1, LockedUntil: time.Now().Unix() - 1, UpdatedAt: time.Now().Unix() - 61},
+			orm.Eq(user.LoginLock_.Identifier, email))
+		for i := 0; i < 3; i++ {
+			login(email, "wrong-password")
+		}
+		if w := lockedFor(email); w < 115 || w > 120 {
+			t.Errorf("second lock should last ~120s, got %d", w)
+		}
+	})
+
+	t.Run("UnlockUser clears the lock", func(t *testing.T) {
+		if err := m.UnlockUser(u.Id); err != nil {
+			t.Fatal(err)
+		}
+		if ctx := login(email, pass); ctx.Status != 302 {
+			t.Errorf("login after unlock: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+	})
+
+	t.Run("Concurrent failures all count", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.RecordFailure("burst@test.com", "9.9.9.9")
+			}()
+		}
+		wg.Wait()
+		if !m.Locked("burst@test.com") {
+			t.Error("simultaneous failures overwrote each other's count")
+		}
+	})
+
+	t.Run("Success resets the count", func(t *testing.T) {
+		login(email, "wrong-password")
+		login(email, "wrong-password")
+		login(email, pass)
+		login(email, "wrong-password")
+		if m.Locked(email) {
+			t.Error("failures before a successful login still counted")
+		}
+	})
+}
@@ -0,0 +1,95 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	"github.com/tinywasm/user/oauth2"
+)
+
+func TestSafeNext(t *testing.T) {
+	origins := []string{"https://admin.example.com"}
+	cases := []struct {
+		next string
+		ok   bool
+	}{
+		{"/reports?id=3", true},
+		{"https://admin.example.com/users", true},
+		{"https://admin.example.com", true},
+		{"", false},
+		{"//evil.com", false},
+		{"/\\evil.com", false},
+		{"https://evil.com", false},
+		{"https://admin.example.com.evil.com", false},
+		{"https://admin.example.com@evil.com", false},
+		{"javascript:alert(1)", false},
+		{"/a\r\nSet-Cookie: x=1", false},
+	}
+	for _, tc := range cases {
+		if got := user.SafeNext(tc.next, origins...); (got != "") != tc.ok {
+			t.Errorf("SafeNext(%q) = %q, want ok=%v", tc.next, got, tc.ok)
+		}
+	}
+}
+
+func TestOAuthNext(t *testing.T) {
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+	p := &MockProvider{NameVal: "idp", UserInfoVal: user.OAuthUserInfo{ID: "sub-1", Email: "next@example.com", EmailVerified: true}}
+	m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p}, oauth2.WithNextOrigins("https://admin.example.com")))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	login := func(query string) *mock.Context {
+		begin := &mock.Context{InMethod: "GET", InPath: "/oauth/idp" + query}
+		r.Invoke("GET", "/oauth/idp", begin)
+		state := strings.TrimPrefix(begin.GetHeader("Location"), "http://mock/")
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/idp?state=" + state + "&code=c"}
+		r.Invoke("GET", "/oauth/callback/idp", cb)
+		return cb
+	}
+
+	cases := []struct{ query, want string }{
+		{"?next=%2Freports%3Fid%3D3", "/reports?id=3"},
+		{"?next=https%3A%2F%2Fadmin.example.com%2Fusers", "https://admin.example.com/users"},
+		{"?next=https%3A%2F%2Fevil.com", user.PathAfterLogin},
+		{"?next=%2F%2Fevil.com", user.PathAfterLogin},
+		{"", user.PathAfterLogin},
+	}
+	for _, tc := range cases {
+		cb := login(tc.query)
+		if cb.Status != 302 || cb.GetHeader("Location") != tc.want {
+			t.Errorf("next %q: %d %q, want %q", tc.query, cb.Status, cb.GetHeader("Location"), tc.want)
+		}
+	}
+}
+
+func TestLoginNext(t *testing.T) {
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+	m.Enable(emailpassword.New(m, m, m))
+	u, _ := m.CreateUser("next@example.com", "Next", "")
+	m.SetPassword(u.Id, "password123")
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	var body string
+	json.Encode(&user.LoginData{Email: "next@example.com", Password: "password123"}, &body)
+
+	for path, want := range map[string]string{
+		user.PathLogin + "?next=%2Fx":                   "/x",
+		user.PathLogin + "?next=https%3A%2F%2Fevil.com": user.PathAfterLogin,
+	} {
+		ctx := &mock.Context{InMethod: "POST", InPath: path, InBody: []byte(body)}
+		ctx.SetHeader("Content-Type", "application/json")
+		r.Invoke("POST", user.PathLogin, ctx)
+		if ctx.Status != 302 || ctx.GetHeader("Location") != want {
+			t.Errorf("%s: %d %q, want %q", path, ctx.Status, ctx.GetHeader("Location"), want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	magiclink "github.com/tinywasm/user/magic_link"
+)
+
+func TestMagicLink(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	setup := func(t *testing.T, cfg user.Config) (*authority.Module, *mock.Router, *mockMailer) {
+		mailer := &mockMailer{}
+		cfg.IDs = testIDs
+		cfg.CookieName = "test_session"
+		m, _ := authority.New(newTestDB(t), cfg)
+		m.Enable(magiclink.New(m, m, m, m, mailer, "https://app.test"))
+		r := &mock.Router{}
+		m.MountAPI(r)
+		if err := m.Bootstrap(authority.Seed{Email: "link@test.com", Password: "password123", Name: "Link", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}}); err != nil {
+			t.Fatal(err)
+		}
+		return m, r, mailer
+	}
+	request := func(r *mock.Router, email string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathMagicLink}
+		ctx.SetHeader("Content-Type", "application/json")
+		json.Encode(&user.ForgotData{Email: email}, &ctx.InBody)
+		r.Invoke("POST", user.PathMagicLink, ctx)
+		return ctx
+	}
+	follow := func(r *mock.Router, token string) *mock.Context {
+		path := user.PathMagicLinkVerify + "?token=" + token
+		ctx := &mock.Context{InMethod: "GET", InPath: path}
+		r.Invoke("GET", user.PathMagicLinkVerify, ctx)
+		return ctx
+	}
+
+	t.Run("Link logs in once", func(t *testing.T) {
+		_, r, mailer := setup(t, user.Config{})
+		if ctx := request(r, "link@test.com"); ctx.Status != 202 {
+			t.Fatalf("request status %d", ctx.Status)
+		}
+		sent := mailer.wait(t, 1)
+		if len(sent) != 1 || sent[0].kind != user.MailMagicLink {
+			t.Fatalf("unexpected mails: %+v", sent)
+		}
+		if !strings.HasPrefix(sent[0].link, "https://app.test"+user.PathMagicLinkVerify+"?token=") {
+			t.Errorf("unexpected link %q", sent[0].link)
+		}
+		token := mailer.lastToken()
+
+		ctx := follow(r, token)
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathAfterLogin {
+			t.Fatalf("verify: %d %q", ctx.Status, ctx.GetHeader("Location"))
+		}
+		if c, ok := ctx.Cookie("test_session"); !ok || c.Value == "" {
+			t.Error("no session cookie issued")
+		}
+		if ctx := follow(r, token); ctx.Status != 401 {
+			t.Errorf("link reused: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Only the latest link works", func(t *testing.T) {
+		_, r, mailer := setup(t, user.Config{})
+		request(r, "link@test.com")
+		mailer.wait(t, 1)
+		stale := mailer.lastToken()
+		request(r, "link@test.com")
+		mailer.wait(t, 2)
+		if ctx := follow(r, stale); ctx.Status != 401 {
+			t.Errorf("superseded link accepted: %d", ctx.Status)
+		}
+		if ctx := follow(r, mailer.lastToken()); ctx.Status != 302 {
+			t.Errorf("latest link rejected: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Expired link", func(t *testing.T) {
+		_, r, mailer := setup(t, user.Config{MagicLinkTTL: -1})
+		request(r, "link@test.com")
+		mailer.wait(t, 1)
+		if ctx := follow(r, mailer.lastToken()); ctx.Status != 401 {
+			t.Errorf("expired link accepted: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Unknown and suspended look the same", func(t *testing.T) {
+		m, r, mailer := setup(t, user.Config{})
+		if ctx := request(r, "nobody@test.com"); ctx.Status != 202 || len(ctx.ResponseBody()) != 0 {
+			t.Errorf("unknown: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		u, _ := m.GetUserByEmail("link@test.com")
+		m.SuspendUser(u.Id)
+		if ctx := request(r, "link@test.com"); ctx.Status != 202 {
+			t.Errorf("suspended: %d", ctx.Status)
+		}
+		if sent := mailer.settle(); len(sent) != 0 {
+			t.Errorf("mail sent: %+v", sent)
+		}
+	})
+
+	t.Run("Suspended after mailing", func(t *testing.T) {
+		m, r, mailer := setup(t, user.Config{})
+		request(r, "link@test.com")
+		mailer.wait(t, 1)
+		u, _ := m.GetUserByEmail("link@test.com")
+		m.SuspendUser(u.Id)
+		if ctx := follow(r, mailer.lastToken()); ctx.Status != 401 {
+			t.Errorf("suspended user logged in: %d", ctx.Status)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		_, r, mailer := setup(t, user.Config{})
+		request(r, "link@test.com")
+		mailer.wait(t, 1)
+		path := user.PathMagicLinkVerify + "?token=" + mailer.lastToken()
+		ctx := &mock.Context{InMethod: "GET", InPath: path}
+		ctx.SetHeader("Accept", "application/json")
+		r.Invoke("GET", user.PathMagicLinkVerify, ctx)
+		if ctx.Status != 200 || !strings.Contains(string(ctx.ResponseBody()), "link@test.com") {
+			t.Errorf("json verify: %d %s", ctx.Status, ctx.ResponseBody())
+		}
+	})
+}
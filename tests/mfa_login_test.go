@@ -0,0 +1,201 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	"github.com/tinywasm/user/totp"
+)
+
+func TestTwoPhaseLogin(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess", LockThreshold: 3})
+	m.Enable(emailpassword.New(m, m, m), totp.New(m, m, m, "Acme"))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	grants := []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}
+	if err := m.Bootstrap(authority.Seed{Email: "mfa@test.com", Password: "password123", Name: "Mfa", Role: "admin", Grants: grants}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Bootstrap(authority.Seed{Email: "plain@test.com", Password: "password123", Name: "Plain", Role: "admin", Grants: grants}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := m.GetUserByEmail("mfa@test.com")
+	secret, _ := totp.NewSecret()
+	m.SaveTOTPSecret(u.Id, secret)
+	m.ConfirmTOTP(u.Id, 0)
+	step := totp.Step(time.Now() / 1e9)
+
+	login := func(email string, accept bool) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+		ctx.SetHeader("Content-Type", "application/json")
+		if accept {
+			ctx.SetHeader("Accept", "application/json")
+		}
+		json.Encode(&user.LoginData{Email: email, Password: "password123"}, &ctx.InBody)
+		r.Invoke("POST", user.PathLogin, ctx)
+		return ctx
+	}
+	verify := func(cookie, code string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathMFAVerify, InBody: []byte(`{"code":"` + code + `"}`)}
+		ctx.SetHeader("Content-Type", "application/json")
+		if cookie != "" {
+			ctx.SetCookie(router.Cookie{Name: "sess", Value: cookie})
+		}
+		r.Invoke("POST", user.PathMFAVerify, ctx)
+		return ctx
+	}
+	identify := func(cookie string) string {
+		ctx := &mock.Context{}
+		ctx.SetCookie(router.Cookie{Name: "sess", Value: cookie})
+		var id string
+		m.Authenticate()(func(c router.Context) { id = c.UserID() })(ctx)
+		return id
+	}
+	codeAt := func(s int64) string {
+		c, _ := totp.Code(secret, s)
+		return c
+	}
+
+	t.Run("No factor, no second step", func(t *testing.T) {
+		ctx := login("plain@test.com", false)
+		c, _ := ctx.Cookie("sess")
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathAfterLogin || identify(c.Value) == "" {
+			t.Errorf("plain login: %d %q", ctx.Status, ctx.GetHeader("Location"))
+		}
+	})
+
+	var partial string
+
+	t.Run("Password only buys a partial session", func(t *testing.T) {
+		ctx := login("mfa@test.com", false)
+		if ctx.Status != 303 || ctx.GetHeader("Location") != user.PathMFA {
+			t.Fatalf("form login: %d %q", ctx.Status, ctx.GetHeader("Location"))
+		}
+		ctx = login("mfa@test.com", true)
+		var res user.LoginResult
+		json.Decode(ctx.ResponseBody(), &res)
+		if ctx.Status != 401 || res.Code != user.CodeMFARequired {
+			t.Fatalf("json login: %d %+v", ctx.Status, res)
+		}
+		c, ok := ctx.Cookie("sess")
+		if !ok || c.Value == "" || c.MaxAge != 0 {
+			t.Fatalf("partial cookie: %+v", c)
+		}
+		partial = c.Value
+
+		if id := identify(partial); id != "" {
+			t.Errorf("Authenticate set %q for a partial session", id)
+		}
+		sess, err := m.GetSession(partial)
+		if err != nil || sess.Scope != user.ScopeMFA || sess.ExpiresAt-sess.CreatedAt != 300 {
+			t.Errorf("partial session row: %+v %v", sess, err)
+		}
+	})
+
+	t.Run("Wrong code keeps it partial", func(t *testing.T) {
+		if ctx := verify(partial, "nope"); ctx.Status != 401 {
+			t.Errorf("wrong code: %d", ctx.Status)
+		}
+		if ctx := verify("", codeAt(step)); ctx.Status != 401 {
+			t.Errorf("code without a partial session: %d", ctx.Status)
+		}
+		if _, err := m.GetSession(partial); err != nil {
+			t.Errorf("partial session gone after a wrong code: %v", err)
+		}
+	})
+
+	t.Run("Right code upgrades", func(t *testing.T) {
+		ctx := verify(partial, codeAt(step))
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathAfterLogin {
+			t.Fatalf("verify: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		c, _ := ctx.Cookie("sess")
+		if c.Value == partial || identify(c.Value) != u.Id {
+			t.Errorf("no full session issued: %+v", c)
+		}
+		if _, err := m.GetSession(partial); err == nil {
+			t.Error("partial session survived the upgrade")
+		}
+		if ctx := verify(partial, codeAt(step+1)); ctx.Status != 401 {
+			t.Errorf("spent partial session verified again: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Wrong codes count toward the lockout", func(t *testing.T) {
+		c, _ := login("mfa@test.com", true).Cookie("sess")
+		for i := 0; i < 3; i++ {
+			verify(c.Value, "nope")
+		}
+		if ctx := verify(c.Value, codeAt(step+1)); ctx.Status != 423 {
+			t.Errorf("locked account verified: %d", ctx.Status)
+		}
+	})
+}
+
+func TestMFAAttemptCaps(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	// No LockThreshold: the caps hold on their own.
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess", MFAAttempts: 3, MFAUserAttempts: 5})
+	m.Enable(emailpassword.New(m, m, m), totp.New(m, m, m, "Acme"))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	grants := []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}
+	if err := m.Bootstrap(authority.Seed{Email: "caps@test.com", Password: "password123", Name: "Caps", Role: "admin", Grants: grants}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := m.GetUserByEmail("caps@test.com")
+	secret, _ := totp.NewSecret()
+	m.SaveTOTPSecret(u.Id, secret)
+	m.ConfirmTOTP(u.Id, 0)
+	code, _ := totp.Code(secret, totp.Step(time.Now()/1e9))
+
+	partial := func() string {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+		ctx.SetHeader("Content-Type", "application/json")
+		ctx.SetHeader("Accept", "application/json")
+		json.Encode(&user.LoginData{Email: "caps@test.com", Password: "password123"}, &ctx.InBody)
+		r.Invoke("POST", user.PathLogin, ctx)
+		c, _ := ctx.Cookie("sess")
+		return c.Value
+	}
+	verify := func(cookie, code string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathMFAVerify, InBody: []byte(`{"code":"` + code + `"}`)}
+		ctx.SetHeader("Content-Type", "application/json")
+		ctx.SetCookie(router.Cookie{Name: "sess", Value: cookie})
+		r.Invoke("POST", user.PathMFAVerify, ctx)
+		return ctx
+	}
+
+	first := partial()
+	for i := 0; i < 3; i++ {
+		if ctx := verify(first, "000000x"); ctx.Status != 401 {
+			t.Fatalf("wrong code %d: %d", i, ctx.Status)
+		}
+	}
+	if _, err := m.GetSession(first); err == nil {
+		t.Error("partial session outlived MFAAttempts wrong codes")
+	}
+
+	second := partial()
+	for i := 0; i < 2; i++ {
+		verify(second, "000000x")
+	}
+	if ctx := verify(second, code); ctx.Status != 429 {
+		t.Errorf("past MFAUserAttempts: %d, want 429", ctx.Status)
+	}
+}
@@ -0,0 +1,138 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	"github.com/tinywasm/user/oauth2"
+)
+
+func TestOAuthLinkPolicy(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []oauth2.Option
+		verified bool
+		status   int
+	}{
+		{"Default, unverified", nil, false, 409},
+		{"Default, verified", nil, true, 302},
+		{"Never", []oauth2.Option{oauth2.WithLinkPolicy(oauth2.LinkNever)}, true, 409},
+		{"Always", []oauth2.Option{oauth2.WithLinkPolicy(oauth2.LinkAlways)}, false, 302},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+			local, _ := m.CreateUser("taken@example.com", "Local", "")
+			p := &MockProvider{NameVal: "idp", UserInfoVal: user.OAuthUserInfo{ID: "sub-1", Email: "taken@example.com", EmailVerified: tc.verified}}
+			m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p}, tc.opts...))
+			r := &mock.Router{}
+			m.MountAPI(r)
+
+			begin := &mock.Context{InMethod: "GET", InPath: "/oauth/idp"}
+			r.Invoke("GET", "/oauth/idp", begin)
+			state := strings.TrimPrefix(begin.GetHeader("Location"), "http://mock/")
+			cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/idp?state=" + state + "&code=c"}
+			r.Invoke("GET", "/oauth/callback/idp", cb)
+
+			if cb.Status != tc.status {
+				t.Fatalf("callback: %d %q, want %d", cb.Status, cb.ResponseBody(), tc.status)
+			}
+			id, err := m.IdentityByProvider("idp", "sub-1")
+			if tc.status == 409 {
+				if err == nil {
+					t.Error("identity linked despite the policy")
+				}
+				if string(cb.ResponseBody()) != user.ErrEmailTaken.Error() {
+					t.Errorf("conflict body: %q", cb.ResponseBody())
+				}
+				return
+			}
+			if err != nil || id.UserId != local.Id {
+				t.Errorf("not linked to the existing account: %+v %v", id, err)
+			}
+		})
+	}
+}
+
+func TestOAuthAccountStatus(t *testing.T) {
+	setup := func(t *testing.T, verified bool, opts ...oauth2.Option) (*authority.Module, user.User, func() *mock.Context) {
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+		local, _ := m.CreateUser("taken@example.com", "Local", "")
+		p := &MockProvider{NameVal: "idp", UserInfoVal: user.OAuthUserInfo{ID: "sub-1", Email: "taken@example.com", EmailVerified: verified}}
+		m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p}, opts...))
+		r := &mock.Router{}
+		m.MountAPI(r)
+		login := func() *mock.Context {
+			begin := &mock.Context{InMethod: "GET", InPath: "/oauth/idp"}
+			r.Invoke("GET", "/oauth/idp", begin)
+			state := strings.TrimPrefix(begin.GetHeader("Location"), "http://mock/")
+			cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/idp?state=" + state + "&code=c"}
+			cb.SetHeader("Accept", "application/json")
+			r.Invoke("GET", "/oauth/callback/idp", cb)
+			return cb
+		}
+		return m, local, login
+	}
+	code := func(ctx *mock.Context) string {
+		var res user.LoginResult
+		json.Decode(ctx.ResponseBody(), &res)
+		return res.Code
+	}
+
+	t.Run("A verified email claims a pending sign-up", func(t *testing.T) {
+		m, local, login := setup(t, true)
+		m.SetPassword(local.Id, "squatter-password")
+		if _, err := m.CreateVerification(local.Id); err != nil {
+			t.Fatal(err)
+		}
+		if cb := login(); cb.Status != 200 {
+			t.Fatalf("callback: %d %q", cb.Status, cb.ResponseBody())
+		}
+		if u, _ := m.UserByID(local.Id); u.Status != "active" {
+			t.Errorf("status %q, want active", u.Status)
+		}
+		if _, err := m.IdentityFor(local.Id, "email_password"); err == nil {
+			t.Error("the pre-verification password survived the claim")
+		}
+		if _, err := m.Login("taken@example.com", "squatter-password"); err == nil {
+			t.Error("squatter's password still logs in")
+		}
+	})
+
+	t.Run("An unverified email never joins a pending sign-up", func(t *testing.T) {
+		m, local, login := setup(t, false, oauth2.WithLinkPolicy(oauth2.LinkAlways))
+		m.CreateVerification(local.Id)
+		if cb := login(); cb.Status != 403 || code(cb) != user.CodeEmailUnverified {
+			t.Fatalf("callback: %d %q", cb.Status, cb.ResponseBody())
+		}
+		if _, err := m.IdentityByProvider("idp", "sub-1"); err == nil {
+			t.Error("identity linked into a pending account")
+		}
+	})
+
+	t.Run("Suspended", func(t *testing.T) {
+		m, local, login := setup(t, true)
+		m.SuspendUser(local.Id)
+		if cb := login(); cb.Status != 401 || code(cb) != user.CodeSuspended {
+			t.Fatalf("by email: %d %q", cb.Status, cb.ResponseBody())
+		}
+		if _, err := m.IdentityByProvider("idp", "sub-1"); err == nil {
+			t.Error("identity linked into a suspended account")
+		}
+
+		m.ReactivateUser(local.Id)
+		if cb := login(); cb.Status != 200 {
+			t.Fatalf("active: %d %q", cb.Status, cb.ResponseBody())
+		}
+		m.SuspendUser(local.Id)
+		if cb := login(); cb.Status != 401 || code(cb) != user.CodeSuspended {
+			t.Errorf("by linked identity: %d %q", cb.Status, cb.ResponseBody())
+		}
+	})
+}
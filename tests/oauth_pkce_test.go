@@ -0,0 +1,54 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	"github.com/tinywasm/user/oauth2"
+)
+
+func TestOAuthPKCE(t *testing.T) {
+	login := func(t *testing.T, opts ...oauth2.Option) (*MockProvider, *mock.Context) {
+		t.Helper()
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+		p := &MockProvider{NameVal: "pkce", UserInfoVal: user.OAuthUserInfo{ID: "sub", Email: "pkce@example.com", Name: "Pkce"}}
+		m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p}, opts...))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		begin := &mock.Context{InMethod: "GET", InPath: "/oauth/pkce"}
+		r.Invoke("GET", "/oauth/pkce", begin)
+		state := strings.TrimPrefix(begin.GetHeader("Location"), "http://mock/")
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/pkce?state=" + state + "&code=c"}
+		r.Invoke("GET", "/oauth/callback/pkce", cb)
+		return p, cb
+	}
+
+	t.Run("S256 by default", func(t *testing.T) {
+		p, cb := login(t)
+		if cb.Status != 302 {
+			t.Fatalf("callback: %d %q", cb.Status, cb.ResponseBody())
+		}
+		if len(p.Verifier) != 43 || p.Challenge != oauth2.S256(p.Verifier) {
+			t.Errorf("challenge %q does not match verifier %q", p.Challenge, p.Verifier)
+		}
+	})
+
+	t.Run("Opt out", func(t *testing.T) {
+		p, cb := login(t, oauth2.WithPKCE(false))
+		if cb.Status != 302 || p.Challenge != "" || p.Verifier != "" {
+			t.Errorf("PKCE sent anyway: %d %q %q", cb.Status, p.Challenge, p.Verifier)
+		}
+	})
+
+	t.Run("RFC 7636 vector", func(t *testing.T) {
+		if got := oauth2.S256("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+			t.Errorf("S256 = %q", got)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	"github.com/tinywasm/user/oauth2"
+)
+
+func TestOAuthProvisioning(t *testing.T) {
+	// login runs one provider login for info against a fresh module, after
+	// setup has had its chance to seed it.
+	login := func(t *testing.T, info user.OAuthUserInfo, setup func(m *authority.Module) []oauth2.Option) (*authority.Module, *mockPublisher, *mock.Context) {
+		t.Helper()
+		pub := &mockPublisher{}
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub})
+		opts := setup(m)
+		info.ID = "sub-1"
+		m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{&MockProvider{NameVal: "idp", UserInfoVal: info}}, opts...))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		begin := &mock.Context{InMethod: "GET", InPath: "/oauth/idp"}
+		r.Invoke("GET", "/oauth/idp", begin)
+		state := strings.TrimPrefix(begin.GetHeader("Location"), "http://mock/")
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/idp?state=" + state + "&code=c"}
+		r.Invoke("GET", "/oauth/callback/idp", cb)
+		return m, pub, cb
+	}
+	opts := func(o ...oauth2.Option) func(*authority.Module) []oauth2.Option {
+		return func(*authority.Module) []oauth2.Option { return o }
+	}
+
+	cases := []struct {
+		name   string
+		info   user.OAuthUserInfo
+		setup  func(m *authority.Module) []oauth2.Option
+		status int
+	}{
+		{"Provisioning off", user.OAuthUserInfo{Email: "new@acme.com", EmailVerified: true}, opts(oauth2.WithProvisioning(false)), 403},
+		{"Provisioning off, existing account", user.OAuthUserInfo{Email: "old@acme.com", EmailVerified: true}, func(m *authority.Module) []oauth2.Option {
+			m.CreateUser("old@acme.com", "Old", "")
+			return []oauth2.Option{oauth2.WithProvisioning(false)}
+		}, 302},
+		{"Allowed domain", user.OAuthUserInfo{Email: "bob@ACME.com", EmailVerified: true}, opts(oauth2.WithAllowedDomains("acme.com")), 302},
+		{"Other domain", user.OAuthUserInfo{Email: "eve@evil.com", EmailVerified: true}, opts(oauth2.WithAllowedDomains("acme.com")), 403},
+		{"Subdomain", user.OAuthUserInfo{Email: "eve@evil.acme.com", EmailVerified: true}, opts(oauth2.WithAllowedDomains("acme.com")), 403},
+		{"Unverified email in allowed domain", user.OAuthUserInfo{Email: "eve@acme.com"}, opts(oauth2.WithAllowedDomains("acme.com")), 403},
+		{"Allowed tenant", user.OAuthUserInfo{Email: "bob@x.com", Tenant: "t-1"}, opts(oauth2.WithAllowedTenants("t-1")), 302},
+		{"Other tenant", user.OAuthUserInfo{Email: "eve@x.com", Tenant: "t-2"}, opts(oauth2.WithAllowedTenants("t-1")), 403},
+		{"Personal account", user.OAuthUserInfo{Email: "eve@x.com"}, opts(oauth2.WithAllowedTenants("t-1")), 403},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, pub, cb := login(t, tc.info, tc.setup)
+			if cb.Status != tc.status {
+				t.Fatalf("callback: %d %q, want %d", cb.Status, cb.ResponseBody(), tc.status)
+			}
+			if tc.status != 403 {
+				return
+			}
+			if string(cb.ResponseBody()) != user.ErrSignupClosed.Error() {
+				t.Errorf("refusal body: %q", cb.ResponseBody())
+			}
+			if _, err := m.UserByEmail(tc.info.Email); err == nil {
+				t.Error("account created")
+			}
+			if !hasEvent(pub, user.EventProvisionDenied) {
+				t.Error("no EventProvisionDenied")
+			}
+		})
+	}
+
+	t.Run("Default role", func(t *testing.T) {
+		m, _, cb := login(t, user.OAuthUserInfo{Email: "staff@acme.com", EmailVerified: true}, func(m *authority.Module) []oauth2.Option {
+			if err := m.CreateRole("role_staff", "staff", "Staff", "Auto-provisioned"); err != nil {
+				t.Fatal(err)
+			}
+			return []oauth2.Option{oauth2.WithDefaultRole(m, "role_staff")}
+		})
+		if cb.Status != 302 {
+			t.Fatalf("callback: %d %q", cb.Status, cb.ResponseBody())
+		}
+		u, _ := m.UserByEmail("staff@acme.com")
+		full, err := m.GetUser(u.Id)
+		if err != nil || len(full.Roles) != 1 || full.Roles[0].Code != "staff" {
+			t.Errorf("roles of the new account: %+v %v", full.Roles, err)
+		}
+	})
+
+	t.Run("Failed default role rolls the account back", func(t *testing.T) {
+		m, _, cb := login(t, user.OAuthUserInfo{Email: "norole@acme.com", EmailVerified: true}, func(m *authority.Module) []oauth2.Option {
+			return []oauth2.Option{oauth2.WithDefaultRole(failingRoles{}, "role_staff")}
+		})
+		if cb.Status != 500 {
+			t.Fatalf("callback: %d %q", cb.Status, cb.ResponseBody())
+		}
+		if _, err := m.UserByEmail("norole@acme.com"); err == nil {
+			t.Error("account without its role left behind")
+		}
+	})
+}
+
+type failingRoles struct{}
+
+func (failingRoles) AssignRole(userID, roleID string) error { return errors.New("role store down") }
@@ -0,0 +1,300 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	stdjson "encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	stdtime "time"
+
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	"github.com/tinywasm/user/oauth2"
+	"github.com/tinywasm/user/oauth2/provider/google"
+	"github.com/tinywasm/user/oauth2/provider/oidc"
+)
+
+// testIdP is a local OpenID Provider stand-in: discovery, a JWKS with one RSA
+// and one P-256 key, and a token endpoint answering whatever id_token the test
+// queued.
+type testIdP struct {
+	srv    *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu        sync.Mutex
+	idToken   string
+	challenge string
+	jwksHits  int
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{}
+	idp.rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	idp.ecKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		stdjson.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.srv.URL,
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	})
+	// Entra ID's multi-tenant endpoint publishes an issuer template.
+	mux.HandleFunc("/common/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		stdjson.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.srv.URL + "/{tenantid}/v2.0",
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		idp.jwksHits++
+		idp.mu.Unlock()
+		x, y := make([]byte, 32), make([]byte, 32)
+		idp.ecKey.X.FillBytes(x)
+		idp.ecKey.Y.FillBytes(y)
+		stdjson.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "r1", "use": "sig", "n": b64(idp.rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(idp.rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "e1", "crv": "P-256", "x": b64(x), "y": b64(y)},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("client_id") != "client" || b64(sum[:]) != idp.challenge {
+			w.WriteHeader(400)
+			return
+		}
+		stdjson.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": idp.idToken})
+	})
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+// sign builds a compact JWS over claims; alg "none" leaves it unsigned.
+func (idp *testIdP) sign(alg, kid string, claims map[string]any) string {
+	header, _ := stdjson.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := stdjson.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	h := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, idp.rsaKey, crypto.SHA256, h[:])
+	case "ES256":
+		r, s, _ := ecdsa.Sign(rand.Reader, idp.ecKey, h[:])
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCProvider(t *testing.T) {
+	idp := newTestIdP(t)
+	p := &oidc.Provider{Issuer: idp.srv.URL, ClientID: "client", ClientSecret: "secret", RedirectURL: "https://app.example.com/oauth/callback/sso", ProviderName: "sso"}
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+	m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p}))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	// login runs begin and callback, letting edit shape the id_token claims
+	// the IdP then issues for this login's nonce.
+	login := func(t *testing.T, alg, kid string, edit func(c map[string]any)) *mock.Context {
+		t.Helper()
+		begin := &mock.Context{InMethod: "GET", InPath: "/oauth/sso"}
+		r.Invoke("GET", "/oauth/sso", begin)
+		loc, err := url.Parse(begin.GetHeader("Location"))
+		if begin.Status != 302 || err != nil || !strings.HasPrefix(loc.String(), idp.srv.URL+"/authorize?") {
+			t.Fatalf("begin: %d %q", begin.Status, begin.GetHeader("Location"))
+		}
+		q := loc.Query()
+		if q.Get("nonce") == "" || q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+			t.Fatalf("authorization request: %v", q)
+		}
+
+		claims := map[string]any{
+			"iss": idp.srv.URL, "sub": "u-42", "aud": "client", "exp": stdtime.Now().Add(stdtime.Minute).Unix(),
+			"nonce": q.Get("nonce"), "email": "sso@example.com", "given_name": "Sso", "family_name": "User",
+		}
+		if edit != nil {
+			edit(claims)
+		}
+		idp.mu.Lock()
+		idp.challenge = q.Get("code_challenge")
+		idp.idToken = idp.sign(alg, kid, claims)
+		idp.mu.Unlock()
+
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/sso?state=" + url.QueryEscape(q.Get("state")) + "&code=c"}
+		r.Invoke("GET", "/oauth/callback/sso", cb)
+		return cb
+	}
+
+	t.Run("Valid id_token", func(t *testing.T) {
+		if cb := login(t, "RS256", "r1", nil); cb.Status != 302 {
+			t.Fatalf("RS256 login: %d %q", cb.Status, cb.ResponseBody())
+		}
+		u, err := m.UserByEmail("sso@example.com")
+		if err != nil || u.Name != "Sso User" {
+			t.Errorf("user from claims: %+v %v", u, err)
+		}
+		if _, err := m.IdentityByProvider("sso", "u-42"); err != nil {
+			t.Errorf("identity not keyed by sub: %v", err)
+		}
+		if cb := login(t, "ES256", "e1", func(c map[string]any) { c["aud"] = []string{"client", "other"}; c["azp"] = "client" }); cb.Status != 302 {
+			t.Errorf("ES256 login with azp: %d %q", cb.Status, cb.ResponseBody())
+		}
+		if idp.jwksHits != 1 {
+			t.Errorf("JWKS fetched %d times, want cached", idp.jwksHits)
+		}
+	})
+
+	rejects := []struct {
+		name     string
+		alg, kid string
+		edit     func(c map[string]any)
+	}{
+		{"Wrong nonce", "RS256", "r1", func(c map[string]any) { c["nonce"] = "replayed" }},
+		{"Missing nonce", "RS256", "r1", func(c map[string]any) { delete(c, "nonce") }},
+		{"Wrong audience", "RS256", "r1", func(c map[string]any) { c["aud"] = "someone-else" }},
+		{"Several audiences, no azp", "RS256", "r1", func(c map[string]any) { c["aud"] = []string{"client", "other"} }},
+		{"Wrong issuer", "RS256", "r1", func(c map[string]any) { c["iss"] = "https://evil.example" }},
+		{"Expired", "RS256", "r1", func(c map[string]any) { c["exp"] = stdtime.Now().Add(-stdtime.Hour).Unix() }},
+		{"Unsigned", "none", "r1", nil},
+		{"Key of another type", "ES256", "r1", nil},
+		{"Unknown key", "RS256", "r9", nil},
+	}
+	for _, tc := range rejects {
+		t.Run(tc.name, func(t *testing.T) {
+			if cb := login(t, tc.alg, tc.kid, tc.edit); cb.Status != 401 {
+				t.Errorf("accepted: %d", cb.Status)
+			}
+		})
+	}
+
+	t.Run("Discovery down", func(t *testing.T) {
+		down := &oidc.Provider{Issuer: "http://127.0.0.1:1", ClientID: "client", ProviderName: "down"}
+		r := &mock.Router{}
+		oauth2.New(m, m, m, m, []user.OAuthProvider{down}).Mount(r)
+		ctx := &mock.Context{InMethod: "GET", InPath: "/oauth/down"}
+		r.Invoke("GET", "/oauth/down", ctx)
+		if ctx.Status != 502 {
+			t.Errorf("begin without discovery: %d", ctx.Status)
+		}
+	})
+}
+
+func TestOIDCEntra(t *testing.T) {
+	idp := newTestIdP(t)
+	p := &oidc.Provider{Issuer: idp.srv.URL + "/common", ClientID: "client", ClientSecret: "secret", RedirectURL: "https://app.example.com/oauth/callback/entra", ProviderName: "entra", Entra: true}
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+	m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p}))
+	r := &mock.Router{}
+	m.MountAPI(r)
+	owner, _ := m.CreateUser("ent@example.com", "Owner", "")
+
+	login := func(t *testing.T, edit func(c map[string]any)) *mock.Context {
+		t.Helper()
+		begin := &mock.Context{InMethod: "GET", InPath: "/oauth/entra"}
+		r.Invoke("GET", "/oauth/entra", begin)
+		loc, err := url.Parse(begin.GetHeader("Location"))
+		if begin.Status != 302 || err != nil {
+			t.Fatalf("begin: %d %q", begin.Status, begin.GetHeader("Location"))
+		}
+		q := loc.Query()
+		claims := map[string]any{
+			"iss": idp.srv.URL + "/t-1/v2.0", "tid": "t-1", "sub": "pairwise", "oid": "o-1", "aud": "client",
+			"exp": stdtime.Now().Add(stdtime.Minute).Unix(), "nonce": q.Get("nonce"), "email": "ent@example.com", "name": "Ent",
+		}
+		if edit != nil {
+			edit(claims)
+		}
+		idp.mu.Lock()
+		idp.challenge = q.Get("code_challenge")
+		idp.idToken = idp.sign("RS256", "r1", claims)
+		idp.mu.Unlock()
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/entra?state=" + url.QueryEscape(q.Get("state")) + "&code=c"}
+		r.Invoke("GET", "/oauth/callback/entra", cb)
+		return cb
+	}
+
+	if cb := login(t, func(c map[string]any) { c["iss"] = idp.srv.URL + "/t-2/v2.0" }); cb.Status != 401 {
+		t.Errorf("issuer of another tenant: %d", cb.Status)
+	}
+	if cb := login(t, func(c map[string]any) { delete(c, "tid") }); cb.Status != 401 {
+		t.Errorf("no tid: %d", cb.Status)
+	}
+	// Without xms_edov the email is just what the tenant's admin typed.
+	if cb := login(t, nil); cb.Status != 409 {
+		t.Errorf("unverified email joined an account: %d %q", cb.Status, cb.ResponseBody())
+	}
+	if cb := login(t, func(c map[string]any) { c["xms_edov"] = true }); cb.Status != 302 {
+		t.Fatalf("verified login: %d %q", cb.Status, cb.ResponseBody())
+	}
+	if i, err := m.IdentityByProvider("entra", "o-1"); err != nil || i.UserId != owner.Id {
+		t.Errorf("identity not keyed by oid on the matching account: %+v %v", i, err)
+	}
+}
+
+func TestProviderJSONHelpers(t *testing.T) {
+	obj := ` { "name": "a \\\" , b", "nested": {"email_verified": true, "x": [1, "]"]},
+		"email_verified" : true, "flag": "true", "keys": [ {"kid": "k1"}, {"kid": "k}2"} ], "n": -1.5e3 }`
+
+	for _, tc := range []struct{ name, want string }{
+		{"email_verified", "true"},
+		{"flag", `"true"`},
+		{"n", "-1.5e3"},
+		{"nested", `{"email_verified": true, "x": [1, "]"]}`},
+	} {
+		if got, ok := google.MemberHelper(obj, tc.name); !ok || got != tc.want {
+			t.Errorf("member %s: %q %v, want %q", tc.name, got, ok, tc.want)
+		}
+	}
+	if _, ok := google.MemberHelper(obj, "x"); ok {
+		t.Error("a nested member was read as a top-level one")
+	}
+	if !google.BoolHelper(obj, "email_verified") || google.BoolHelper(obj, "flag") || google.BoolHelper(obj, "missing") {
+		t.Error("BoolHelper must take only a literal true")
+	}
+
+	raw, _ := google.MemberHelper(obj, "keys")
+	if elems, ok := google.ElementsHelper(raw); !ok || len(elems) != 2 || elems[1] != `{"kid": "k}2"}` {
+		t.Errorf("elements: %q %v", elems, ok)
+	}
+	if elems, ok := google.ElementsHelper("[]"); !ok || len(elems) != 0 {
+		t.Errorf("empty array: %q %v", elems, ok)
+	}
+	for _, bad := range []string{``, `[`, `{"a" 1}`, `{"a": "open}`, `"str"`} {
+		if _, ok := google.MemberHelper(bad, "a"); ok {
+			t.Errorf("member of %q", bad)
+		}
+		if _, ok := google.ElementsHelper(bad); ok {
+			t.Errorf("elements of %q", bad)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	otpcode "github.com/tinywasm/user/otp_code"
+)
+
+type sentCode struct {
+	to      string
+	channel user.CodeChannel
+	code    string
+}
+
+type mockCodeSender struct {
+	mu   sync.Mutex
+	sent []sentCode
+}
+
+func (s *mockCodeSender) SendCode(to string, channel user.CodeChannel, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, sentCode{to: to, channel: channel, code: code})
+	return nil
+}
+
+// wait blocks until n codes have gone out and returns what was sent.
+func (s *mockCodeSender) wait(t *testing.T, n int) []sentCode {
+	t.Helper()
+	for i := 0; ; i++ {
+		s.mu.Lock()
+		sent := append([]sentCode(nil), s.sent...)
+		s.mu.Unlock()
+		if len(sent) >= n {
+			return sent
+		}
+		if i == 200 {
+			t.Fatalf("waited for %d codes, got %+v", n, sent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// settle gives sends still in flight time to land and returns what was sent.
+func (s *mockCodeSender) settle() []sentCode {
+	time.Sleep(50 * time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sentCode(nil), s.sent...)
+}
+
+func (s *mockCodeSender) last() sentCode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) == 0 {
+		return sentCode{}
+	}
+	return s.sent[len(s.sent)-1]
+}
+
+func TestOTPCode(t *testing.T) {
+	setup := func(t *testing.T, cfg user.Config, opts ...otpcode.Option) (*authority.Module, *mock.Router, *mockCodeSender) {
+		sender := &mockCodeSender{}
+		cfg.IDs = testIDs
+		cfg.CookieName = "test_session"
+		m, _ := authority.New(newTestDB(t), cfg)
+		m.Enable(otpcode.New(m, m, m, m, sender, opts...))
+		r := &mock.Router{}
+		m.MountAPI(r)
+		if _, err := m.CreateUser("field@test.com", "Field", "+56911111111"); err != nil {
+			t.Fatal(err)
+		}
+		return m, r, sender
+	}
+	// request returns the client cookie the response set.
+	request := func(r *mock.Router, email string) (*mock.Context, string) {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLoginCode}
+		ctx.SetHeader("Content-Type", "application/json")
+		json.Encode(&user.ForgotData{Email: email}, &ctx.InBody)
+		r.Invoke("POST", user.PathLoginCode, ctx)
+		c, _ := ctx.Cookie(otpcode.ClientCookie)
+		return ctx, c.Value
+	}
+	redeem := func(r *mock.Router, client, code string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLoginCodeVerify}
+		ctx.SetHeader("Content-Type", "application/json")
+		ctx.InBody = []byte(`{"code":"` + code + `"}`)
+		if client != "" {
+			ctx.SetCookie(router.Cookie{Name: otpcode.ClientCookie, Value: client})
+		}
+		r.Invoke("POST", user.PathLoginCodeVerify, ctx)
+		return ctx
+	}
+
+	t.Run("Code logs in once", func(t *testing.T) {
+		_, r, sender := setup(t, user.Config{})
+		ctx, client := request(r, "field@test.com")
+		if ctx.Status != 202 || client == "" {
+			t.Fatalf("request: %d, client %q", ctx.Status, client)
+		}
+		sender.wait(t, 1)
+		sent := sender.last()
+		if sent.to != "field@test.com" || sent.channel != user.CodeByEmail || len(sent.code) != 6 {
+			t.Fatalf("unexpected code: %+v", sent)
+		}
+
+		ctx = redeem(r, client, sent.code)
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathAfterLogin {
+			t.Fatalf("redeem: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if c, ok := ctx.Cookie("test_session"); !ok || c.Value == "" {
+			t.Error("no session cookie issued")
+		}
+		if ctx := redeem(r, client, sent.code); ctx.Status != 401 {
+			t.Errorf("code reused: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Bound to the requesting client", func(t *testing.T) {
+		_, r, sender := setup(t, user.Config{})
+		_, client := request(r, "field@test.com")
+		_, other := request(r, "nobody@test.com")
+		code := sender.wait(t, 1)[0].code
+		if ctx := redeem(r, other, code); ctx.Status != 401 {
+			t.Errorf("code accepted from another client: %d", ctx.Status)
+		}
+		if ctx := redeem(r, "", code); ctx.Status != 401 {
+			t.Errorf("code accepted without a client: %d", ctx.Status)
+		}
+		if ctx := redeem(r, client, code); ctx.Status != 302 {
+			t.Errorf("own client rejected: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Attempts burn the code", func(t *testing.T) {
+		_, r, sender := setup(t, user.Config{LoginCodeAttempts: 3})
+		_, client := request(r, "field@test.com")
+		code := sender.wait(t, 1)[0].code
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		for i := 0; i < 3; i++ {
+			if ctx := redeem(r, client, wrong); ctx.Status != 401 {
+				t.Fatalf("wrong code %d: %d", i, ctx.Status)
+			}
+		}
+		if ctx := redeem(r, client, code); ctx.Status != 401 {
+			t.Errorf("code survived its attempts: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Sends per user are capped", func(t *testing.T) {
+		_, r, sender := setup(t, user.Config{LoginCodeSends: 2})
+		for i := 0; i < 3; i++ {
+			if ctx, _ := request(r, "field@test.com"); ctx.Status != 202 {
+				t.Fatalf("request %d: %d", i, ctx.Status)
+			}
+			if i < 2 {
+				sender.wait(t, i+1)
+			}
+		}
+		if sent := sender.settle(); len(sent) != 2 {
+			t.Errorf("sent %d codes, want LoginCodeSends=2", len(sent))
+		}
+	})
+
+	t.Run("Expired code", func(t *testing.T) {
+		_, r, sender := setup(t, user.Config{LoginCodeTTL: -1})
+		_, client := request(r, "field@test.com")
+		if ctx := redeem(r, client, sender.wait(t, 1)[0].code); ctx.Status != 401 {
+			t.Errorf("expired code accepted: %d", ctx.Status)
+		}
+	})
+
+	t.Run("SMS goes to the phone", func(t *testing.T) {
+		_, r, sender := setup(t, user.Config{}, otpcode.WithChannel(user.CodeBySMS))
+		_, client := request(r, "field@test.com")
+		sent := sender.wait(t, 1)[0]
+		if sent.to != "+56911111111" || sent.channel != user.CodeBySMS {
+			t.Fatalf("unexpected code: %+v", sent)
+		}
+		if ctx := redeem(r, client, sent.code); ctx.Status != 302 {
+			t.Errorf("redeem: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Unknown and suspended look the same", func(t *testing.T) {
+		m, r, sender := setup(t, user.Config{})
+		if ctx, client := request(r, "nobody@test.com"); ctx.Status != 202 || client == "" {
+			t.Errorf("unknown: %d", ctx.Status)
+		}
+		u, _ := m.GetUserByEmail("field@test.com")
+		m.SuspendUser(u.Id)
+		if ctx, _ := request(r, "field@test.com"); ctx.Status != 202 {
+			t.Errorf("suspended: %d", ctx.Status)
+		}
+		if sent := sender.settle(); len(sent) != 0 {
+			t.Errorf("code sent: %+v", sent)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+	t.Cleanup(func() { emailpassword.Hasher = emailpassword.Bcrypt{} })
+	fastArgon := emailpassword.Argon2id{Time: 1, Memory: 8 * 1024, Threads: 1}
+
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+	u, err := m.CreateUser("hasher@test.com", "Hasher", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := func() string {
+		id, err := m.IdentityFor(u.Id, "email_password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id.ProviderId
+	}
+
+	emailpassword.Hasher = emailpassword.Bcrypt{}
+	if err := m.SetPassword(u.Id, "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Raised bcrypt cost is applied on login", func(t *testing.T) {
+		emailpassword.Hasher = emailpassword.Bcrypt{Cost: bcrypt.MinCost + 1}
+		if _, err := m.Login("hasher@test.com", "password123"); err != nil {
+			t.Fatal(err)
+		}
+		if h := stored(); !strings.HasPrefix(h, "$2a$05$") {
+			t.Errorf("expected cost 5 after rehash, got %q", h)
+		}
+	})
+
+	t.Run("Migrates bcrypt to Argon2id on login", func(t *testing.T) {
+		emailpassword.Hasher = fastArgon
+		if _, err := m.Login("hasher@test.com", "wrong-password"); err == nil {
+			t.Fatal("wrong password accepted")
+		}
+		if h := stored(); !strings.HasPrefix(h, "$2a$") {
+			t.Fatalf("failed login must not rehash, got %q", h)
+		}
+		if _, err := m.Login("hasher@test.com", "password123"); err != nil {
+			t.Fatal(err)
+		}
+		migrated := stored()
+		if !strings.HasPrefix(migrated, "$argon2id$v=19$m=8192,t=1,p=1$") {
+			t.Fatalf("expected argon2id hash, got %q", migrated)
+		}
+		if _, err := m.Login("hasher@test.com", "password123"); err != nil {
+			t.Fatal("login after migration:", err)
+		}
+		if stored() != migrated {
+			t.Error("an up-to-date hash was rehashed again")
+		}
+	})
+
+	t.Run("Old algorithms keep verifying", func(t *testing.T) {
+		emailpassword.Hasher = emailpassword.Bcrypt{}
+		h := stored()
+		if err := emailpassword.VerifyPassword(h, "password123"); err != nil {
+			t.Errorf("argon2id hash rejected once bcrypt is current again: %v", err)
+		}
+		if err := emailpassword.VerifyPassword("$md5$whatever", "password123"); err == nil {
+			t.Error("unknown hash format accepted")
+		}
+	})
+
+	t.Run("No silent truncation", func(t *testing.T) {
+		long := strings.Repeat("a", 72)
+		if _, err := (emailpassword.Bcrypt{}).Hash(long + "b"); err != user.ErrPasswordTooLong {
+			t.Errorf("bcrypt: expected ErrPasswordTooLong, got %v", err)
+		}
+		h, err := fastArgon.Hash(long + "b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fastArgon.Verify(h, long+"c") == nil {
+			t.Error("argon2id ignored bytes past 72")
+		}
+	})
+}
+
+// sliceHasher is bcrypt behind a type that can't be compared with ==.
+type sliceHasher struct {
+	emailpassword.Bcrypt
+	hashed *int
+	_      []byte
+}
+
+func (h sliceHasher) Hash(password string) (string, error) {
+	*h.hashed++
+	return h.Bcrypt.Hash(password)
+}
+
+func TestDummyCompare(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+	t.Cleanup(func() { emailpassword.Hasher = emailpassword.Bcrypt{} })
+
+	hashed := 0
+	emailpassword.Hasher = sliceHasher{hashed: &hashed}
+	emailpassword.DummyCompare("password123")
+	emailpassword.DummyCompare("password123")
+	if hashed > 1 {
+		t.Errorf("dummy hash made %d times for one hasher", hashed)
+	}
+
+	hashed = 0
+	emailpassword.Hasher = sliceHasher{Bcrypt: emailpassword.Bcrypt{Cost: bcrypt.MinCost + 1}, hashed: &hashed}
+	emailpassword.DummyCompare("password123")
+	if hashed != 1 {
+		t.Errorf("raised cost didn't regenerate the dummy hash: %d", hashed)
+	}
+}
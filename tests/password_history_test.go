@@ -0,0 +1,91 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestPasswordHistory(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	seed := func(t *testing.T, cfg user.Config) (*authority.Module, string) {
+		cfg.IDs = testIDs
+		m, _ := authority.New(newTestDB(t), cfg)
+		email := "history@test.com"
+		if err := m.Bootstrap(authority.Seed{Email: email, Password: "password-a", Name: "History", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}}); err != nil {
+			t.Fatal(err)
+		}
+		u, _ := m.GetUserByEmail(email)
+		return m, u.Id
+	}
+
+	t.Run("Refuses the last N passwords", func(t *testing.T) {
+		m, id := seed(t, user.Config{PasswordHistory: 2})
+		steps := []struct {
+			password string
+			want     error
+		}{
+			{"password-a", user.ErrPasswordReused}, // current
+			{"password-b", nil},
+			{"password-a", user.ErrPasswordReused}, // still within 2
+			{"password-c", nil},
+			{"password-a", nil}, // pruned out
+		}
+		for i, s := range steps {
+			if err := m.SetPassword(id, s.password); err != s.want {
+				t.Fatalf("step %d (%s): expected %v, got %v", i, s.password, s.want, err)
+			}
+		}
+		if _, err := m.Login("history@test.com", "password-a"); err != nil {
+			t.Errorf("login with final password: %v", err)
+		}
+	})
+
+	t.Run("A reused password keeps the reset link", func(t *testing.T) {
+		m, id := seed(t, user.Config{PasswordHistory: 2})
+		token, err := m.CreateResetToken(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.ResetPassword(token, "password-a"); err != user.ErrPasswordReused {
+			t.Fatalf("expected ErrPasswordReused, got %v", err)
+		}
+		if _, err := m.ResetPassword(token, "password-b"); err != nil {
+			t.Errorf("link spent by the refused attempt: %v", err)
+		}
+	})
+
+	t.Run("Off by default", func(t *testing.T) {
+		m, id := seed(t, user.Config{})
+		if err := m.SetPassword(id, "password-a"); err != nil {
+			t.Errorf("reuse refused without PasswordHistory: %v", err)
+		}
+	})
+
+	t.Run("Minimum age holds back change_password only", func(t *testing.T) {
+		m, id := seed(t, user.Config{PasswordMinAge: 3600})
+		reg := &mockOpRegistry{ops: make(map[string]*mockRoute)}
+		m.MountOps(reg)
+
+		ctx := &mock.Context{}
+		ctx.SetUserID(id)
+		json.Encode(&user.PasswordData{Current: "password-a", New: "password-b", Confirm: "password-b"}, &ctx.InBody)
+		reg.ops[user.OpChangePassword].handler(ctx)
+		if ctx.Status != 400 || string(ctx.ResponseBody()) != user.ErrPasswordTooRecent.Error() {
+			t.Errorf("got %d %q", ctx.Status, ctx.ResponseBody())
+		}
+
+		if err := m.SetPassword(id, "password-b"); err != nil {
+			t.Errorf("admin set held back by min age: %v", err)
+		}
+	})
+}
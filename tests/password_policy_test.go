@@ -0,0 +1,141 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	t.Run("Reports every broken rule", func(t *testing.T) {
+		p := &user.PasswordPolicy{MaxLength: 10, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+		err := p.Check("abc")
+		var pe *user.PolicyError
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected *PolicyError, got %v", err)
+		}
+		if got := strings.Join(pe.Codes(), ","); got != "too_short,no_upper,no_digit,no_symbol" {
+			t.Errorf("codes: %s", got)
+		}
+		if !errors.Is(err, user.ErrWeakPassword) {
+			t.Error("PolicyError must unwrap to ErrWeakPassword")
+		}
+		if err := p.Check("Abcdefg1!"); err != nil {
+			t.Errorf("compliant password rejected: %v", err)
+		}
+		if err := p.Check("Abcdefghij1!"); err == nil || !strings.Contains(err.Error(), "too_long") {
+			t.Errorf("expected too_long, got %v", err)
+		}
+	})
+
+	t.Run("MinLength never drops below 8", func(t *testing.T) {
+		p := &user.PasswordPolicy{MinLength: 4}
+		if err := p.Check("abcdefg"); err == nil {
+			t.Error("7-char password accepted")
+		}
+	})
+
+	t.Run("Lengths count characters, not bytes", func(t *testing.T) {
+		p := &user.PasswordPolicy{MaxLength: 10}
+		if err := p.Check("ñandúñandú"); err != nil { // 10 characters, 14 bytes
+			t.Errorf("10-character password refused: %v", err)
+		}
+		if err := p.Check("ñandúñ"); err == nil { // 6 characters, 8 bytes
+			t.Error("6-character password accepted")
+		}
+	})
+
+	t.Run("Rejects personal data", func(t *testing.T) {
+		p := &user.PasswordPolicy{RejectPersonal: true}
+		cases := []struct {
+			password string
+			reject   bool
+		}{
+			{"jdoe-2024!", true},   // email local part
+			{"xxMARTINEZxx", true}, // word of the name
+			{"unrelated-phrase", false},
+			{"aliceli!!", false}, // "li" is under 3 chars
+		}
+		for _, tc := range cases {
+			err := p.Check(tc.password, "jdoe@test.com", "Ana Li Martinez")
+			if (err != nil) != tc.reject {
+				t.Errorf("%q: reject=%v, got %v", tc.password, tc.reject, err)
+			}
+		}
+	})
+
+	t.Run("Breached list from file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "common.txt")
+		os.WriteFile(path, []byte("# top passwords\npassword123\n\nQwertyuiop\n"), 0o600)
+		set, err := authority.LoadBreachedList(path, 0.001)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !set.Contains("password123") || !set.Contains("qwertyuiop") {
+			t.Error("listed password not found")
+		}
+		if set.Contains("correct horse battery staple") {
+			t.Error("unlisted password reported as breached")
+		}
+		if _, err := authority.LoadBreachedList(filepath.Join(t.TempDir(), "missing"), 0.001); err == nil {
+			t.Error("missing file should fail")
+		}
+	})
+
+	t.Run("Zero BreachedSet is empty", func(t *testing.T) {
+		var zero user.BreachedSet
+		zero.Add("password123")
+		if zero.Contains("password123") {
+			t.Error("zero set reported a password as breached")
+		}
+		p := user.PasswordPolicy{Breached: &zero}
+		if err := p.Check("password123", "", ""); err != nil {
+			t.Errorf("zero set refused a password: %v", err)
+		}
+	})
+
+	t.Run("Authority enforces it with the user's own data", func(t *testing.T) {
+		set := user.NewBreachedSet(1, 0.001)
+		set.Add("password123")
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, PasswordPolicy: &user.PasswordPolicy{RejectPersonal: true, Breached: set}})
+		u, err := m.CreateUser("carla@test.com", "Carla Ruiz", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetPassword(u.Id, "password123"); err == nil || !strings.Contains(err.Error(), "breached") {
+			t.Errorf("breached password: %v", err)
+		}
+		if err := m.SetPassword(u.Id, "ruiz-family-2024"); err == nil || !strings.Contains(err.Error(), "personal") {
+			t.Errorf("personal password: %v", err)
+		}
+		if err := m.SetPassword(u.Id, "tangerine-orbit"); err != nil {
+			t.Errorf("good password: %v", err)
+		}
+	})
+
+	t.Run("Register returns structured reasons", func(t *testing.T) {
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, PasswordPolicy: &user.PasswordPolicy{RequireDigit: true, RejectPersonal: true}})
+		m.Enable(emailpassword.New(m, m, m, emailpassword.WithRegister(m)))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		ctx := postRegister(r, &user.RegisterData{Name: "Bruno", Email: "bruno@test.com", Password: "brunobruno"})
+		body := string(ctx.ResponseBody())
+		if ctx.Status != 400 || !strings.Contains(body, `"reasons"`) ||
+			!strings.Contains(body, "no_digit") || !strings.Contains(body, "personal") {
+			t.Errorf("got %d %s", ctx.Status, body)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func TestPasswordReset(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	pub := &mockPublisher{}
+	mailer := &mockMailer{}
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub})
+	m.Enable(emailpassword.New(m, m, m, emailpassword.WithPasswordReset(m, mailer, "https://app.test/reset")))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	email := "forgot@test.com"
+	if err := m.Bootstrap(authority.Seed{Email: email, Password: "old-password", Name: "Forgot", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := m.GetUserByEmail(email)
+	sess, err := m.CreateSession(u.Id, "1.1.1.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forgot := func(email string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathPasswordForgot}
+		ctx.SetHeader("Content-Type", "application/json")
+		json.Encode(&user.ForgotData{Email: email}, &ctx.InBody)
+		r.Invoke("POST", user.PathPasswordForgot, ctx)
+		return ctx
+	}
+	reset := func(token, password string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathPasswordReset}
+		ctx.SetHeader("Content-Type", "application/json")
+		json.Encode(&user.ResetData{Token: token, Password: password}, &ctx.InBody)
+		r.Invoke("POST", user.PathPasswordReset, ctx)
+		return ctx
+	}
+
+	t.Run("Unknown email looks the same", func(t *testing.T) {
+		ctx := forgot("nobody@test.com")
+		if ctx.Status != 202 || len(ctx.ResponseBody()) != 0 {
+			t.Errorf("got %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if sent := mailer.settle(); len(sent) != 0 {
+			t.Errorf("mail sent for unknown email: %+v", sent)
+		}
+	})
+
+	t.Run("Only the latest link works", func(t *testing.T) {
+		if ctx := forgot(email); ctx.Status != 202 {
+			t.Fatalf("forgot status %d", ctx.Status)
+		}
+		mailer.wait(t, 1)
+		stale := mailer.lastToken()
+		forgot(email)
+		sent := mailer.wait(t, 2)
+		if len(sent) != 2 || sent[1].to != email || sent[1].kind != user.MailPasswordReset {
+			t.Fatalf("unexpected mails: %+v", sent)
+		}
+		if !strings.HasPrefix(sent[1].link, "https://app.test/reset?token=") {
+			t.Errorf("unexpected link %q", sent[1].link)
+		}
+		if ctx := reset(stale, "new-password"); ctx.Status != 400 {
+			t.Errorf("superseded token accepted: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Weak password keeps the token", func(t *testing.T) {
+		token := mailer.lastToken()
+		ctx := reset(token, "short")
+		if ctx.Status != 400 || string(ctx.ResponseBody()) != user.ErrWeakPassword.Error() {
+			t.Errorf("got %d %q", ctx.Status, ctx.ResponseBody())
+		}
+
+		ctx = reset(token, "new-password")
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathLogin {
+			t.Fatalf("reset: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if _, err := m.Login(email, "new-password"); err != nil {
+			t.Errorf("login with new password: %v", err)
+		}
+		if _, err := m.Login(email, "old-password"); err == nil {
+			t.Error("old password still accepted")
+		}
+		if _, err := m.GetSession(sess.Id); err == nil {
+			t.Error("existing session survived the reset")
+		}
+
+		found := false
+		for _, e := range pub.SecurityEvents() {
+			if e.Type == user.EventPasswordReset && e.UserID == u.Id {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("EventPasswordReset not published")
+		}
+
+		if ctx := reset(token, "another-password"); ctx.Status != 400 || string(ctx.ResponseBody()) != user.ErrInvalidToken.Error() {
+			t.Errorf("token reused: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+	})
+
+	t.Run("Concurrent resets spend the token once", func(t *testing.T) {
+		forgot(email)
+		mailer.wait(t, 3)
+		token := mailer.lastToken()
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		won := 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := m.ResetPassword(token, "racing-password"); err == nil {
+					mu.Lock()
+					won++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if won != 1 {
+			t.Errorf("%d resets spent one token, want 1", won)
+		}
+	})
+}
@@ -53,7 +53,7 @@ func testWidgets(t *testing.T) {
 		data     model.Fielder
 		expected int
 	}{
-		{"LoginData", &user.LoginData{}, 2},
+		{"LoginData", &user.LoginData{}, 3},
 		{"RegisterData", &user.RegisterData{}, 4},
 		{"ProfileData", &user.ProfileData{}, 2},
 		{"PasswordData", &user.PasswordData{}, 3},
@@ -0,0 +1,162 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	"github.com/tinywasm/user/oauth2"
+)
+
+// refreshingProvider is a MockProvider whose token endpoint also takes
+// refresh tokens.
+type refreshingProvider struct {
+	*MockProvider
+	refreshedWith string
+	next          user.OAuthToken
+	// hold, when set, parks RefreshToken until it is closed; entered reports
+	// that a refresh has started.
+	hold    chan struct{}
+	entered chan struct{}
+}
+
+func (p *refreshingProvider) RefreshToken(refreshToken string) (user.OAuthToken, error) {
+	if p.hold != nil {
+		p.entered <- struct{}{}
+		<-p.hold
+	}
+	p.refreshedWith = refreshToken
+	return p.next, nil
+}
+
+func TestProviderTokens(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	if _, err := authority.New(newTestDB(t), user.Config{IDs: testIDs, TokenKey: key[:16]}); err == nil {
+		t.Error("accepted a 16-byte TokenKey")
+	}
+
+	db := newTestDB(t)
+	m, _ := authority.New(db, user.Config{IDs: testIDs, TokenKey: key})
+	refreshing := &refreshingProvider{
+		MockProvider: &MockProvider{
+			NameVal:         "api",
+			ExchangeCodeVal: user.OAuthToken{AccessToken: "at-1", RefreshToken: "rt-1", ExpiresIn: 3600},
+			UserInfoVal:     user.OAuthUserInfo{ID: "sub-1", Email: "api@example.com"},
+		},
+		next: user.OAuthToken{AccessToken: "at-2", ExpiresIn: 3600},
+	}
+	plain := &MockProvider{
+		NameVal:         "plain",
+		ExchangeCodeVal: user.OAuthToken{AccessToken: "pt-1", RefreshToken: "prt-1", ExpiresIn: 3600},
+		UserInfoVal:     user.OAuthUserInfo{ID: "sub-2", Email: "api@example.com", EmailVerified: true},
+	}
+	a := oauth2.New(m, m, m, m, []user.OAuthProvider{refreshing, plain}, oauth2.WithTokenStore(m))
+	m.Enable(a)
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	login := func(t *testing.T, provider string) {
+		t.Helper()
+		begin := &mock.Context{InMethod: "GET", InPath: "/oauth/" + provider}
+		r.Invoke("GET", "/oauth/"+provider, begin)
+		state := strings.TrimPrefix(begin.GetHeader("Location"), "http://mock/")
+		cb := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/" + provider + "?state=" + state + "&code=c"}
+		r.Invoke("GET", "/oauth/callback/"+provider, cb)
+		if cb.Status != 302 {
+			t.Fatalf("%s login: %d %q", provider, cb.Status, cb.ResponseBody())
+		}
+	}
+	login(t, "api")
+	login(t, "plain")
+	u, _ := m.UserByEmail("api@example.com")
+
+	t.Run("Encrypted at rest", func(t *testing.T) {
+		rows, err := user.ReadAllProviderToken(db.Query(&user.ProviderToken{}))
+		if err != nil || len(rows) != 2 {
+			t.Fatalf("stored rows: %d %v", len(rows), err)
+		}
+		for _, row := range rows {
+			if strings.Contains(row.AccessToken+row.RefreshToken, "t-1") {
+				t.Errorf("token stored in clear: %+v", row)
+			}
+		}
+	})
+
+	t.Run("Fresh token", func(t *testing.T) {
+		tok, err := a.TokenFor(u.Id, "api")
+		if err != nil || tok.AccessToken != "at-1" || refreshing.refreshedWith != "" {
+			t.Errorf("TokenFor: %+v %v (refreshed with %q)", tok, err, refreshing.refreshedWith)
+		}
+	})
+
+	t.Run("Refresh when expired", func(t *testing.T) {
+		// An expired access token; the stored refresh token is kept.
+		if err := m.SaveProviderToken(u.Id, "api", user.OAuthToken{AccessToken: "at-old", Expiry: 1}); err != nil {
+			t.Fatal(err)
+		}
+		tok, err := a.TokenFor(u.Id, "api")
+		if err != nil || tok.AccessToken != "at-2" || refreshing.refreshedWith != "rt-1" {
+			t.Fatalf("TokenFor: %+v %v (refreshed with %q)", tok, err, refreshing.refreshedWith)
+		}
+		stored, _ := m.ProviderToken(u.Id, "api")
+		if stored.AccessToken != "at-2" || stored.RefreshToken != "rt-1" || stored.Expiry == 0 {
+			t.Errorf("after refresh: %+v", stored)
+		}
+	})
+
+	t.Run("Expired, provider can't refresh", func(t *testing.T) {
+		m.SaveProviderToken(u.Id, "plain", user.OAuthToken{AccessToken: "pt-old", Expiry: 1})
+		if _, err := a.TokenFor(u.Id, "plain"); err != user.ErrSessionExpired {
+			t.Errorf("TokenFor: %v", err)
+		}
+	})
+
+	t.Run("A slow refresh holds up only its own token", func(t *testing.T) {
+		m.SaveProviderToken(u.Id, "api", user.OAuthToken{AccessToken: "at-old", RefreshToken: "rt-1", Expiry: 1})
+		refreshing.hold, refreshing.entered = make(chan struct{}), make(chan struct{})
+		done := make(chan error)
+		go func() {
+			_, err := a.TokenFor(u.Id, "api")
+			done <- err
+		}()
+		<-refreshing.entered
+
+		other := make(chan error)
+		go func() {
+			_, err := a.TokenFor(u.Id, "plain")
+			other <- err
+		}()
+		select {
+		case err := <-other:
+			if err != user.ErrSessionExpired {
+				t.Errorf("plain TokenFor: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Error("plain token waited on the api refresh")
+		}
+
+		close(refreshing.hold)
+		if err := <-done; err != nil {
+			t.Errorf("api TokenFor: %v", err)
+		}
+		refreshing.hold = nil
+	})
+
+	t.Run("Gone with the identity", func(t *testing.T) {
+		if err := m.UnlinkIdentity(u.Id, "plain"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.TokenFor(u.Id, "plain"); err == nil {
+			t.Error("token outlived its identity")
+		}
+		rows, _ := user.ReadAllProviderToken(db.Query(&user.ProviderToken{}))
+		if len(rows) != 1 {
+			t.Errorf("%d rows left, want 1", len(rows))
+		}
+	})
+}
@@ -0,0 +1,119 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	"github.com/tinywasm/user/oauth2"
+	"github.com/tinywasm/user/ratelimit"
+	trustedip "github.com/tinywasm/user/trusted_ip"
+)
+
+func TestRateLimiter(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	t.Run("Token bucket", func(t *testing.T) {
+		l := ratelimit.New(2, 1, 0)
+		if !l.Allow("a") || !l.Allow("a") {
+			t.Fatal("burst not honoured")
+		}
+		if l.Allow("a") {
+			t.Fatal("third attempt inside the window allowed")
+		}
+		if !l.Allow("b") {
+			t.Error("keys are not independent")
+		}
+		time.Sleep(600 * time.Millisecond)
+		if !l.Allow("a") {
+			t.Error("bucket did not refill")
+		}
+	})
+
+	t.Run("Bounded memory", func(t *testing.T) {
+		l := ratelimit.New(1, 60, 2)
+		l.Allow("a")
+		l.Allow("b")
+		l.Allow("c") // evicts "a", the key seen longest ago
+		if !l.Allow("a") {
+			t.Error("evicted key should start over")
+		}
+		if l.Allow("c") {
+			t.Error("a tracked key lost its state")
+		}
+	})
+
+	rejected := func(t *testing.T, pub *mockPublisher, ctx *mock.Context, mode string) {
+		t.Helper()
+		if ctx.Status != 429 {
+			t.Fatalf("expected 429, got %d", ctx.Status)
+		}
+		for _, e := range pub.SecurityEvents() {
+			if e.Type == user.EventRateLimited && e.Mode == mode {
+				return
+			}
+		}
+		t.Errorf("EventRateLimited with Mode %q not published", mode)
+	}
+
+	t.Run("email_password keys by email across IPs", func(t *testing.T) {
+		pub := &mockPublisher{}
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub})
+		m.Enable(emailpassword.New(m, m, m, emailpassword.WithRateLimiter(ratelimit.New(2, 60, 0))))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		// Case and padding don't buy a fresh bucket.
+		var ctx *mock.Context
+		for i, email := range []string{"target@test.com", "Target@Test.com", " TARGET@test.com"} {
+			ctx = &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+			ctx.SetValue("RemoteAddr", []string{"1.0.0.1", "1.0.0.2", "1.0.0.3"}[i]+":1234")
+			json.Encode(&user.LoginData{Email: email, Password: "guess-guess"}, &ctx.InBody)
+			r.Invoke("POST", user.PathLogin, ctx)
+		}
+		rejected(t, pub, ctx, "email_password")
+	})
+
+	t.Run("trusted_ip", func(t *testing.T) {
+		pub := &mockPublisher{}
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub})
+		m.Enable(trustedip.New(m, m, m, m, false, trustedip.WithRateLimiter(ratelimit.New(1, 60, 0))))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		var ctx *mock.Context
+		for i := 0; i < 2; i++ {
+			ctx = &mock.Context{InMethod: "POST", InPath: "/login/rut"}
+			ctx.SetValue("RemoteAddr", "2.0.0.1:1234")
+			ctx.InBody = []byte(`{"rut":"12345678-5"}`)
+			r.Invoke("POST", "/login/rut", ctx)
+		}
+		rejected(t, pub, ctx, "trusted_ip")
+	})
+
+	t.Run("oauth2", func(t *testing.T) {
+		pub := &mockPublisher{}
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub})
+		p := &MockProvider{NameVal: "rl"}
+		m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{p},
+			oauth2.WithRateLimiter(ratelimit.New(1, 60, 0))))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		first := &mock.Context{InMethod: "GET", InPath: "/oauth/rl"}
+		r.Invoke("GET", "/oauth/rl", first)
+		if first.Status != 302 {
+			t.Fatalf("first begin: %d", first.Status)
+		}
+		ctx := &mock.Context{InMethod: "GET", InPath: "/oauth/callback/rl?state=x&code=y"}
+		r.Invoke("GET", "/oauth/callback/rl", ctx)
+		rejected(t, pub, ctx, "oauth2")
+	})
+}
@@ -85,9 +85,8 @@ func TestTools_Me(t *testing.T) {
 		if route == nil {
 			t.Fatal("me op not registered")
 		}
-		if !route.authenticated {
-			t.Error("me op should be authenticated")
-		}
+		// Public to the router (a change_password session has no
+		// ctx.UserID); the handler itself refuses anonymous callers below.
 
 		ctx := &mock.Context{}
 		ctx.SetUserID(u.Id)
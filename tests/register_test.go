@@ -0,0 +1,126 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+)
+
+func postRegister(r *mock.Router, data *user.RegisterData) *mock.Context {
+	ctx := &mock.Context{InMethod: "POST", InPath: user.PathRegister}
+	ctx.SetHeader("Content-Type", "application/json")
+	json.Encode(data, &ctx.InBody)
+	r.Invoke("POST", user.PathRegister, ctx)
+	return ctx
+}
+
+func TestRegister(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	t.Run("Not mounted by default", func(t *testing.T) {
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs})
+		m.Enable(emailpassword.New(m, m, m))
+		r := &mock.Router{}
+		m.MountAPI(r)
+		for _, info := range r.Routes() {
+			if info.Path == user.PathRegister {
+				t.Fatal("POST /register mounted without WithRegister")
+			}
+		}
+	})
+
+	t.Run("Creates the account and hides taken emails", func(t *testing.T) {
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess"})
+		m.Enable(emailpassword.New(m, m, m, emailpassword.WithRegister(m)))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		data := &user.RegisterData{Name: "New", Email: "new@test.com", Password: "password123"}
+		first := postRegister(r, data)
+		if first.Status != 302 || first.GetHeader("Location") != user.PathLogin {
+			t.Fatalf("register: status %d, location %q", first.Status, first.GetHeader("Location"))
+		}
+		if _, ok := first.Cookie("sess"); ok {
+			t.Error("register issued a session")
+		}
+		if _, err := m.Login("new@test.com", "password123"); err != nil {
+			t.Fatalf("login after register: %v", err)
+		}
+
+		again := postRegister(r, &user.RegisterData{Name: "Other", Email: "new@test.com", Password: "another-pass"})
+		if again.Status != first.Status || again.GetHeader("Location") != first.GetHeader("Location") ||
+			string(again.ResponseBody()) != string(first.ResponseBody()) {
+			t.Errorf("taken email distinguishable: %d %q %q", again.Status, again.GetHeader("Location"), again.ResponseBody())
+		}
+		if _, err := m.Login("new@test.com", "another-pass"); err == nil {
+			t.Error("second registration overwrote the existing password")
+		}
+	})
+
+	t.Run("Rejects invalid payloads", func(t *testing.T) {
+		m, _ := authority.New(newTestDB(t), user.Config{
+			IDs: testIDs,
+			OnPasswordValidate: func(p string) error {
+				if p == "password123" {
+					return user.ErrWeakPassword
+				}
+				return nil
+			},
+		})
+		m.Enable(emailpassword.New(m, m, m, emailpassword.WithRegister(m)))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		cases := []struct {
+			name string
+			data *user.RegisterData
+			want error
+		}{
+			{"Missing name", &user.RegisterData{Email: "a@test.com", Password: "long-enough"}, user.ErrNameRequired},
+			{"Bad email", &user.RegisterData{Name: "A", Email: "not-an-email", Password: "long-enough"}, user.ErrInvalidEmail},
+			{"Short password", &user.RegisterData{Name: "A", Email: "a@test.com", Password: "short"}, user.ErrWeakPassword},
+			{"OnPasswordValidate", &user.RegisterData{Name: "A", Email: "a@test.com", Password: "password123"}, user.ErrWeakPassword},
+		}
+		for _, tc := range cases {
+			ctx := postRegister(r, tc.data)
+			if ctx.Status != 400 || string(ctx.ResponseBody()) != tc.want.Error() {
+				t.Errorf("%s: got %d %q", tc.name, ctx.Status, ctx.ResponseBody())
+			}
+		}
+		// Over bcrypt's 72 bytes: refused by policy before the account exists,
+		// not by the hasher after.
+		long := postRegister(r, &user.RegisterData{Name: "A", Email: "a@test.com", Password: strings.Repeat("x", 73)})
+		if long.Status != 400 || !strings.Contains(string(long.ResponseBody()), "too_long") {
+			t.Errorf("73-byte password: got %d %q", long.Status, long.ResponseBody())
+		}
+		if _, err := m.GetUserByEmail("a@test.com"); err == nil {
+			t.Error("rejected registration still created the user")
+		}
+	})
+
+	t.Run("Login on verify never tells new from taken", func(t *testing.T) {
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess"})
+		m.CreateUser("taken@test.com", "Taken", "")
+		m.Enable(emailpassword.New(m, m, m, emailpassword.WithRegister(m), emailpassword.WithEmailVerificationLogin(m, &mockMailer{}, "https://app.test"), emailpassword.WithAfterLogin("/home")))
+		r := &mock.Router{}
+		m.MountAPI(r)
+
+		for _, email := range []string{"auto@test.com", "taken@test.com"} {
+			ctx := postRegister(r, &user.RegisterData{Name: "Auto", Email: email, Password: "password123"})
+			if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathLogin {
+				t.Errorf("%s: status %d, location %q", email, ctx.Status, ctx.GetHeader("Location"))
+			}
+			if _, ok := ctx.Cookie("sess"); ok {
+				t.Errorf("%s: register issued a session", email)
+			}
+		}
+	})
+}
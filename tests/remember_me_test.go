@@ -0,0 +1,118 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	"github.com/tinywasm/user/session/jwt"
+)
+
+func TestRememberMe(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	const short, long = 3600, 30 * 86400
+	setup := func(t *testing.T) *authority.Module {
+		m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess", TokenTTL: short, RememberTTL: long})
+		m.Enable(emailpassword.New(m, m, m))
+		if err := m.Bootstrap(authority.Seed{Email: "keep@test.com", Password: "password123", Name: "Keep", Role: "admin", Grants: []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}}); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	login := func(t *testing.T, m *authority.Module, remember string) router.Cookie {
+		r := &mock.Router{}
+		m.MountAPI(r)
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+		ctx.SetHeader("Content-Type", "application/json")
+		json.Encode(&user.LoginData{Email: "keep@test.com", Password: "password123", Remember: remember}, &ctx.InBody)
+		r.Invoke("POST", user.PathLogin, ctx)
+		c, ok := ctx.Cookie("sess")
+		if ctx.Status != 302 || !ok {
+			t.Fatalf("login: %d", ctx.Status)
+		}
+		return c
+	}
+
+	t.Run("Cookie strategy", func(t *testing.T) {
+		m := setup(t)
+		cases := []struct {
+			remember string
+			maxAge   int
+			ttl      int64
+		}{
+			{"", 0, short},
+			{"on", long, long},
+			{"true", long, long},
+		}
+		for _, tc := range cases {
+			c := login(t, m, tc.remember)
+			if c.MaxAge != tc.maxAge {
+				t.Errorf("remember=%q: cookie MaxAge %d, want %d", tc.remember, c.MaxAge, tc.maxAge)
+			}
+			sess, err := m.GetSession(c.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sess.ExpiresAt - sess.CreatedAt; got != tc.ttl {
+				t.Errorf("remember=%q: session ttl %d, want %d", tc.remember, got, tc.ttl)
+			}
+			rotated, err := m.RotateSession(c.Value, "1.1.1.1", "ua")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rotated.ExpiresAt - rotated.CreatedAt; got != tc.ttl {
+				t.Errorf("remember=%q: rotated ttl %d, want %d", tc.remember, got, tc.ttl)
+			}
+		}
+	})
+
+	t.Run("Forced change keeps it", func(t *testing.T) {
+		m := setup(t)
+		reg := &mockOpRegistry{ops: make(map[string]*mockRoute)}
+		m.MountOps(reg)
+		u, _ := m.GetUserByEmail("keep@test.com")
+		if err := m.SetTemporaryPassword(u.Id, "password123"); err != nil {
+			t.Fatal(err)
+		}
+		c := login(t, m, "on")
+
+		ctx := &mock.Context{}
+		ctx.SetCookie(router.Cookie{Name: "sess", Value: c.Value})
+		json.Encode(&user.PasswordData{Current: "password123", New: "my-own-secret", Confirm: "my-own-secret"}, &ctx.InBody)
+		reg.ops[user.OpChangePassword].handler(ctx)
+		if ctx.Status != 204 {
+			t.Fatalf("change_password: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		full, _ := ctx.Cookie("sess")
+		if full.MaxAge != long {
+			t.Errorf("full session cookie MaxAge %d, want %d", full.MaxAge, long)
+		}
+		if sess, err := m.GetSession(full.Value); err != nil || sess.ExpiresAt-sess.CreatedAt != long {
+			t.Errorf("full session: %+v %v, want ttl %d", sess, err, long)
+		}
+	})
+
+	t.Run("JWT strategy", func(t *testing.T) {
+		m := setup(t)
+		strategy, err := jwt.New([]byte("secret"), short, m, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.SetStrategy(strategy.WithCookieName("sess").WithRememberTTL(long))
+		if c := login(t, m, ""); c.MaxAge != 0 {
+			t.Errorf("short session: MaxAge %d, want browser-session cookie", c.MaxAge)
+		}
+		if c := login(t, m, "1"); c.MaxAge != long {
+			t.Errorf("remembered session: MaxAge %d, want %d", c.MaxAge, long)
+		}
+	})
+}
@@ -254,15 +254,26 @@ func testSessions(t *testing.T) {
 	}
 }
 
+// MockProvider records the PKCE pair it was handed and, like a real IdP,
+// refuses an exchange whose verifier doesn't match the last challenge.
 type MockProvider struct {
 	NameVal         string
 	ExchangeCodeVal user.OAuthToken
 	UserInfoVal     user.OAuthUserInfo
+	Challenge       string
+	Verifier        string
 }
 
-func (m *MockProvider) Name() string                    { return m.NameVal }
-func (m *MockProvider) AuthCodeURL(state string) string { return "http://mock/" + state }
-func (m *MockProvider) ExchangeCode(code string) (user.OAuthToken, error) {
+func (m *MockProvider) Name() string { return m.NameVal }
+func (m *MockProvider) AuthCodeURL(state, challenge string) string {
+	m.Challenge = challenge
+	return "http://mock/" + state
+}
+func (m *MockProvider) ExchangeCode(code, verifier string) (user.OAuthToken, error) {
+	m.Verifier = verifier
+	if m.Challenge != "" && oauth2.S256(verifier) != m.Challenge {
+		return user.OAuthToken{}, user.ErrInvalidCredentials
+	}
 	return m.ExchangeCodeVal, nil
 }
 func (m *MockProvider) GetUserInfo(token user.OAuthToken) (user.OAuthUserInfo, error) {
@@ -282,7 +293,7 @@ func testOAuth(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	m.Enable(oauth2.New(m, m, m, []user.OAuthProvider{mockP}))
+	m.Enable(oauth2.New(m, m, m, m, []user.OAuthProvider{mockP}))
 
 	r := &mock.Router{}
 	m.MountAPI(r)
@@ -0,0 +1,217 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	"github.com/tinywasm/user/totp"
+)
+
+type enrollmentResult struct{ secret, uri string }
+
+func (e *enrollmentResult) IsNil() bool { return e == nil }
+func (e *enrollmentResult) DecodeFields(r model.FieldReader) {
+	e.secret, _ = r.String("secret")
+	e.uri, _ = r.String("uri")
+}
+
+type recoveryResult struct{ codes []string }
+
+func (c *recoveryResult) IsNil() bool { return c == nil }
+func (c *recoveryResult) DecodeFields(r model.FieldReader) {
+	if a, ok := r.Array("recovery_codes"); ok {
+		for i := 0; i < a.Len(); i++ {
+			c.codes = append(c.codes, a.String(i))
+		}
+	}
+}
+
+// TestTOTPVectors checks the RFC 6238 appendix B SHA-1 vectors, truncated to
+// six digits.
+func TestTOTPVectors(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range cases {
+		got, err := totp.Code(secret, totp.Step(tc.unix))
+		if err != nil || got != tc.want {
+			t.Errorf("T=%d: got %q (%v), want %q", tc.unix, got, err, tc.want)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	pub := &mockPublisher{}
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, Events: pub})
+	f := totp.New(m, m, m, "Acme")
+	m.Enable(f)
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	u, err := m.CreateUser("mfa@test.com", "Mfa", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(path, callerID, body string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: path, InBody: []byte(body)}
+		ctx.SetHeader("Content-Type", "application/json")
+		ctx.SetUserID(callerID)
+		r.Invoke("POST", path, ctx)
+		return ctx
+	}
+	now := func() int64 { return totp.Step(time.Now() / 1e9) }
+	codeAt := func(secret string, step int64) string {
+		c, _ := totp.Code(secret, step)
+		return c
+	}
+
+	var secret string
+	var recovery []string
+	var confirmedAt int64
+
+	t.Run("Enroll and confirm", func(t *testing.T) {
+		ctx := post(user.PathTOTPEnroll, u.Id, "")
+		var e enrollmentResult
+		if err := json.Decode(ctx.ResponseBody(), &e); err != nil || e.secret == "" {
+			t.Fatalf("enroll: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if !strings.HasPrefix(e.uri, "otpauth://totp/Acme:mfa@test.com?") || !strings.Contains(e.uri, "secret="+e.secret) {
+			t.Errorf("unexpected uri %q", e.uri)
+		}
+		secret = e.secret
+
+		if err := f.Verify(u.Id, codeAt(secret, now())); err == nil {
+			t.Error("unconfirmed secret verified")
+		}
+		if ctx := post(user.PathTOTPConfirm, u.Id, `{"code":"000000x"}`); ctx.Status != 400 {
+			t.Errorf("wrong confirm code: %d", ctx.Status)
+		}
+		confirmedAt = now()
+		ctx = post(user.PathTOTPConfirm, u.Id, `{"code":"`+codeAt(secret, confirmedAt)+`"}`)
+		var rc recoveryResult
+		if err := json.Decode(ctx.ResponseBody(), &rc); err != nil || len(rc.codes) != 10 {
+			t.Fatalf("confirm: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		recovery = rc.codes
+
+		if ctx := post(user.PathTOTPEnroll, u.Id, ""); ctx.Status != 409 {
+			t.Errorf("re-enroll over an active factor: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Replay and drift", func(t *testing.T) {
+		if err := f.Verify(u.Id, codeAt(secret, confirmedAt)); err == nil {
+			t.Error("the confirming code was accepted again")
+		}
+		next := codeAt(secret, confirmedAt+1)
+		if err := f.Verify(u.Id, next); err != nil {
+			t.Errorf("code one period ahead rejected: %v", err)
+		}
+		if err := f.Verify(u.Id, next); err == nil {
+			t.Error("code replayed")
+		}
+		if err := f.Verify(u.Id, codeAt(secret, now()+3)); err == nil {
+			t.Error("code outside the window accepted")
+		}
+	})
+
+	t.Run("Concurrent logins spend a step once", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		won := 0
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := m.UseTOTPStep(u.Id, confirmedAt+10); err == nil {
+					mu.Lock()
+					won++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if won != 1 {
+			t.Errorf("%d logins spent one step, want 1", won)
+		}
+	})
+
+	t.Run("Recovery codes are single-use", func(t *testing.T) {
+		if err := f.Verify(u.Id, recovery[0]); err != nil {
+			t.Errorf("recovery code rejected: %v", err)
+		}
+		if err := f.Verify(u.Id, recovery[0]); err == nil {
+			t.Error("recovery code reused")
+		}
+		typed := strings.ToUpper(strings.Replace(recovery[1], "-", " ", 1))
+		if err := f.Verify(u.Id, typed); err != nil {
+			t.Errorf("recovery code typed loosely rejected: %v", err)
+		}
+	})
+
+	t.Run("Restricted session can't manage", func(t *testing.T) {
+		if ctx := post(user.PathTOTPDisable, user.ScopedUserID(user.OpChangePassword, u.Id), `{"code":"`+recovery[2]+`"}`); ctx.Status != 401 {
+			t.Errorf("scoped disable: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Disable needs a code", func(t *testing.T) {
+		if ctx := post(user.PathTOTPDisable, u.Id, `{"code":"nope"}`); ctx.Status != 400 {
+			t.Errorf("disable without a valid code: %d", ctx.Status)
+		}
+		if ctx := post(user.PathTOTPDisable, u.Id, `{"code":"`+recovery[2]+`"}`); ctx.Status != 204 {
+			t.Fatalf("disable: %d", ctx.Status)
+		}
+		if _, _, err := m.TOTPSecret(u.Id); err == nil {
+			t.Error("secret survived disable")
+		}
+		if err := f.Verify(u.Id, recovery[3]); err == nil {
+			t.Error("recovery code survived disable")
+		}
+	})
+
+	t.Run("Admin reset", func(t *testing.T) {
+		m.SaveTOTPSecret(u.Id, secret)
+		m.ConfirmTOTP(u.Id, 0)
+
+		reg := &mockOpRegistry{ops: make(map[string]*mockRoute)}
+		m.MountOps(reg)
+		route := reg.ops[user.OpResetMFA]
+		if route == nil || route.requiredRes != "users" || route.requiredAct != model.Update {
+			t.Fatalf("reset_mfa not registered or wrongly gated: %+v", route)
+		}
+		ctx := &mock.Context{}
+		json.Encode(&user.User{Id: u.Id}, &ctx.InBody)
+		route.handler(ctx)
+		if _, _, err := m.TOTPSecret(u.Id); err == nil {
+			t.Error("secret survived admin reset")
+		}
+		found := false
+		for _, e := range pub.SecurityEvents() {
+			if e.Type == user.EventMFADisabled && e.UserID == u.Id && e.Mode == "" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("EventMFADisabled not reported for the reset")
+		}
+	})
+}
@@ -0,0 +1,468 @@
+//go:build !wasm
+
+package tests
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	stdtime "time"
+
+	"github.com/tinywasm/crypto/bcrypt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/router/mock"
+	"github.com/tinywasm/user"
+	"github.com/tinywasm/user/authority"
+	emailpassword "github.com/tinywasm/user/email_password"
+	"github.com/tinywasm/user/webauthn"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// Minimal CBOR encoding for the software authenticator below.
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+	return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, uint64(len(b))), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, uint64(len(s))), s...) }
+
+// cborMap takes encoded keys and values alternately.
+func cborMap(kv ...[]byte) []byte {
+	out := cborHead(5, uint64(len(kv)/2))
+	for _, b := range kv {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func cborArray(items ...[]byte) []byte {
+	out := cborHead(4, uint64(len(items)))
+	for _, b := range items {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// softAuthenticator is a passkey held in memory: it answers create() and get()
+// the way a platform authenticator would.
+type softAuthenticator struct {
+	id     []byte
+	alg    int64
+	key    crypto.Signer
+	origin string
+	count  uint32
+}
+
+func newSoftAuthenticator(t *testing.T, alg int64) *softAuthenticator {
+	t.Helper()
+	s := &softAuthenticator{id: make([]byte, 16), alg: alg, origin: testOrigin}
+	rand.Read(s.id)
+	var err error
+	switch alg {
+	case webauthn.AlgES256:
+		s.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case webauthn.AlgRS256:
+		s.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case webauthn.AlgEdDSA:
+		_, s.key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (s *softAuthenticator) coseKey() []byte {
+	switch pub := s.key.Public().(type) {
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return cborMap(cborInt(1), cborInt(2), cborInt(3), cborInt(s.alg), cborInt(-1), cborInt(1), cborInt(-2), cborBytes(x), cborInt(-3), cborBytes(y))
+	case *rsa.PublicKey:
+		return cborMap(cborInt(1), cborInt(3), cborInt(3), cborInt(s.alg), cborInt(-1), cborBytes(pub.N.Bytes()), cborInt(-2), cborBytes(big.NewInt(int64(pub.E)).Bytes()))
+	case ed25519.PublicKey:
+		return cborMap(cborInt(1), cborInt(1), cborInt(3), cborInt(s.alg), cborInt(-1), cborInt(6), cborInt(-2), cborBytes(pub))
+	}
+	return nil
+}
+
+func signWith(key crypto.Signer, data []byte) []byte {
+	var sig []byte
+	h := sha256.Sum256(data)
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		sig, _ = ecdsa.SignASN1(rand.Reader, k, h[:])
+	case *rsa.PrivateKey:
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h[:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, data)
+	}
+	return sig
+}
+
+func (s *softAuthenticator) clientData(typ, challenge string) []byte {
+	return []byte(`{"type":"` + typ + `","challenge":"` + challenge + `","origin":"` + s.origin + `","crossOrigin":false}`)
+}
+
+func authenticatorData(flags byte, count uint32, attested []byte) []byte {
+	h := sha256.Sum256([]byte(testRPID))
+	out := append(h[:], flags)
+	out = binary.BigEndian.AppendUint32(out, count)
+	return append(out, attested...)
+}
+
+// create answers navigator.credentials.create. format is "none", "packed"
+// (self attestation), "x5c" (packed, signed by a generated attestation
+// certificate) or "forged" (self attestation signed by some other key).
+func (s *softAuthenticator) create(t *testing.T, challenge, format string) string {
+	t.Helper()
+	attested := append(make([]byte, 16), byte(len(s.id)>>8), byte(len(s.id)))
+	attested = append(append(attested, s.id...), s.coseKey()...)
+	authData := authenticatorData(0x45, s.count, attested)
+	cd := s.clientData("webauthn.create", challenge)
+	cdHash := sha256.Sum256(cd)
+	signed := append(append([]byte{}, authData...), cdHash[:]...)
+
+	stmt := cborMap()
+	switch format {
+	case "none":
+	case "packed":
+		stmt = cborMap(cborText("alg"), cborInt(s.alg), cborText("sig"), cborBytes(signWith(s.key, signed)))
+		format = "packed"
+	case "forged":
+		otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		stmt = cborMap(cborText("alg"), cborInt(s.alg), cborText("sig"), cborBytes(signWith(otherKey, signed)))
+		format = "packed"
+	case "x5c":
+		attKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "Soft Authenticator Attestation"},
+			NotBefore:             stdtime.Now().Add(-stdtime.Hour),
+			NotAfter:              stdtime.Now().Add(stdtime.Hour),
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &attKey.PublicKey, attKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmt = cborMap(cborText("alg"), cborInt(webauthn.AlgES256), cborText("sig"), cborBytes(signWith(attKey, signed)), cborText("x5c"), cborArray(cborBytes(der)))
+		format = "packed"
+	}
+	att := cborMap(cborText("fmt"), cborText(format), cborText("attStmt"), stmt, cborText("authData"), cborBytes(authData))
+	return `{"id":"` + b64(s.id) + `","client_data":"` + b64(cd) + `","attestation_object":"` + b64(att) + `"}`
+}
+
+// get answers navigator.credentials.get, bumping the sign count first.
+func (s *softAuthenticator) get(challenge, userHandle string, flags byte) string {
+	s.count++
+	authData := authenticatorData(flags, s.count, nil)
+	cd := s.clientData("webauthn.get", challenge)
+	cdHash := sha256.Sum256(cd)
+	sig := signWith(s.key, append(append([]byte{}, authData...), cdHash[:]...))
+	return `{"id":"` + b64(s.id) + `","client_data":"` + b64(cd) + `","authenticator_data":"` + b64(authData) +
+		`","signature":"` + b64(sig) + `","user_handle":"` + userHandle + `"}`
+}
+
+type ceremonyOptions struct {
+	challenge, userID, userVerification string
+	algs, credentials                   []string
+}
+
+func (o *ceremonyOptions) IsNil() bool { return o == nil }
+func (o *ceremonyOptions) DecodeFields(r model.FieldReader) {
+	o.challenge, _ = r.String("challenge")
+	o.userID, _ = r.String("user_id")
+	o.userVerification, _ = r.String("user_verification")
+	read := func(name string) (out []string) {
+		if a, ok := r.Array(name); ok {
+			for i := 0; i < a.Len(); i++ {
+				out = append(out, a.String(i))
+			}
+		}
+		return out
+	}
+	o.algs = read("algs")
+	o.credentials = append(read("exclude_credentials"), read("allow_credentials")...)
+}
+
+func TestWebAuthn(t *testing.T) {
+	emailpassword.DefaultHashCost = bcrypt.MinCost
+
+	pub := &mockPublisher{}
+	m, _ := authority.New(newTestDB(t), user.Config{IDs: testIDs, CookieName: "sess", Events: pub})
+	m.Enable(emailpassword.New(m, m, m), webauthn.New(m, m, m, m, m, webauthn.RelyingParty{ID: testRPID, Name: "Acme", Origin: testOrigin}))
+	r := &mock.Router{}
+	m.MountAPI(r)
+
+	grants := []model.Grant{{Resource: model.Wildcard, Actions: model.AllActions}}
+	for _, email := range []string{"pk@test.com", "other@test.com"} {
+		if err := m.Bootstrap(authority.Seed{Email: email, Password: "password123", Name: "Pk", Role: "admin", Grants: grants}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, _ := m.GetUserByEmail("pk@test.com")
+	other, _ := m.GetUserByEmail("other@test.com")
+
+	post := func(path, callerID, cookie, body string) *mock.Context {
+		ctx := &mock.Context{InMethod: "POST", InPath: path, InBody: []byte(body)}
+		ctx.SetHeader("Content-Type", "application/json")
+		ctx.SetUserID(callerID)
+		if cookie != "" {
+			ctx.SetCookie(router.Cookie{Name: "sess", Value: cookie})
+		}
+		r.Invoke("POST", path, ctx)
+		return ctx
+	}
+	begin := func(path, callerID, cookie string) ceremonyOptions {
+		t.Helper()
+		ctx := post(path, callerID, cookie, "")
+		var o ceremonyOptions
+		if err := json.Decode(ctx.ResponseBody(), &o); err != nil || o.challenge == "" {
+			t.Fatalf("%s: %d %q", path, ctx.Status, ctx.ResponseBody())
+		}
+		return o
+	}
+	register := func(s *softAuthenticator, userID, format string) *mock.Context {
+		o := begin(user.PathWebAuthnRegisterBegin, userID, "")
+		return post(user.PathWebAuthnRegisterFinish, userID, "", s.create(t, o.challenge, format))
+	}
+	identify := func(cookie string) string {
+		ctx := &mock.Context{}
+		ctx.SetCookie(router.Cookie{Name: "sess", Value: cookie})
+		var id string
+		m.Authenticate()(func(c router.Context) { id = c.UserID() })(ctx)
+		return id
+	}
+
+	es256 := newSoftAuthenticator(t, webauthn.AlgES256)
+	eddsa := newSoftAuthenticator(t, webauthn.AlgEdDSA)
+	rs256 := newSoftAuthenticator(t, webauthn.AlgRS256)
+	handle := b64([]byte(u.Id))
+
+	t.Run("Register", func(t *testing.T) {
+		o := begin(user.PathWebAuthnRegisterBegin, u.Id, "")
+		if o.userID != handle || len(o.algs) != 3 || o.algs[0] != "-7" || len(o.credentials) != 0 {
+			t.Errorf("creation options: %+v", o)
+		}
+		if ctx := post(user.PathWebAuthnRegisterFinish, u.Id, "", es256.create(t, o.challenge, "none")); ctx.Status != 201 {
+			t.Fatalf("none attestation: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if ctx := register(eddsa, u.Id, "packed"); ctx.Status != 201 {
+			t.Errorf("packed self attestation: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		if ctx := register(rs256, other.Id, "x5c"); ctx.Status != 201 {
+			t.Errorf("packed x5c attestation: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+
+		if o := begin(user.PathWebAuthnRegisterBegin, u.Id, ""); len(o.credentials) != 2 {
+			t.Errorf("exclude_credentials: %v", o.credentials)
+		}
+		found := false
+		ids, _ := m.GetUserIdentities(u.Id)
+		for _, i := range ids {
+			if i.Provider == "webauthn" && i.ProviderId == b64(es256.id) {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("credential not recorded as an identity")
+		}
+	})
+
+	t.Run("Registration rejects", func(t *testing.T) {
+		if ctx := register(es256, u.Id, "none"); ctx.Status != 409 {
+			t.Errorf("duplicate credential: %d", ctx.Status)
+		}
+		if ctx := register(newSoftAuthenticator(t, webauthn.AlgES256), user.ScopedUserID(user.OpChangePassword, u.Id), "none"); ctx.Status != 401 {
+			t.Errorf("scoped session registered: %d", ctx.Status)
+		}
+
+		fresh := newSoftAuthenticator(t, webauthn.AlgES256)
+		o := begin(user.PathWebAuthnRegisterBegin, u.Id, "")
+		if ctx := post(user.PathWebAuthnRegisterFinish, other.Id, "", fresh.create(t, o.challenge, "none")); ctx.Status != 400 {
+			t.Errorf("another user's challenge: %d", ctx.Status)
+		}
+		if ctx := post(user.PathWebAuthnRegisterFinish, u.Id, "", fresh.create(t, o.challenge, "none")); ctx.Status != 400 {
+			t.Errorf("challenge reused: %d", ctx.Status)
+		}
+
+		fresh.origin = "https://evil.example"
+		if ctx := register(fresh, u.Id, "none"); ctx.Status != 400 {
+			t.Errorf("foreign origin: %d", ctx.Status)
+		}
+		fresh.origin = testOrigin
+
+		if ctx := register(fresh, u.Id, "forged"); ctx.Status != 400 {
+			t.Errorf("self attestation signed by another key: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Passwordless login", func(t *testing.T) {
+		o := begin(user.PathWebAuthnLoginBegin, "", "")
+		if o.userVerification != "required" || len(o.credentials) != 0 {
+			t.Errorf("request options: %+v", o)
+		}
+		ctx := post(user.PathWebAuthnLoginFinish, "", "", es256.get(o.challenge, handle, 0x05))
+		if ctx.Status != 302 || ctx.GetHeader("Location") != user.PathAfterLogin {
+			t.Fatalf("login: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		c, _ := ctx.Cookie("sess")
+		if identify(c.Value) != u.Id {
+			t.Errorf("session not for the credential's user: %+v", c)
+		}
+
+		o = begin(user.PathWebAuthnLoginBegin, "", "")
+		if ctx := post(user.PathWebAuthnLoginFinish, "", "", eddsa.get(o.challenge, "", 0x05)); ctx.Status != 302 {
+			t.Errorf("EdDSA login without a user handle: %d", ctx.Status)
+		}
+		o = begin(user.PathWebAuthnLoginBegin, "", "")
+		if ctx := post(user.PathWebAuthnLoginFinish, "", "", rs256.get(o.challenge, b64([]byte(other.Id)), 0x05)); ctx.Status != 302 {
+			t.Errorf("RS256 login: %d", ctx.Status)
+		}
+	})
+
+	t.Run("Login rejects", func(t *testing.T) {
+		o := begin(user.PathWebAuthnLoginBegin, "", "")
+		if ctx := post(user.PathWebAuthnLoginFinish, "", "", es256.get(o.challenge, handle, 0x01)); ctx.Status != 401 {
+			t.Errorf("passwordless without user verification: %d", ctx.Status)
+		}
+		if ctx := post(user.PathWebAuthnLoginFinish, "", "", es256.get(o.challenge, handle, 0x05)); ctx.Status != 401 {
+			t.Errorf("challenge reused: %d", ctx.Status)
+		}
+		o = begin(user.PathWebAuthnLoginBegin, "", "")
+		if ctx := post(user.PathWebAuthnLoginFinish, "", "", es256.get(o.challenge, b64([]byte(other.Id)), 0x05)); ctx.Status != 401 {
+			t.Errorf("user handle of another user: %d", ctx.Status)
+		}
+
+		impostor := newSoftAuthenticator(t, webauthn.AlgES256)
+		impostor.id = es256.id
+		o = begin(user.PathWebAuthnLoginBegin, "", "")
+		if ctx := post(user.PathWebAuthnLoginFinish, "", "", impostor.get(o.challenge, handle, 0x05)); ctx.Status != 401 {
+			t.Errorf("signature by the wrong key: %d", ctx.Status)
+		}
+
+		clone := *es256
+		o = begin(user.PathWebAuthnLoginBegin, "", "")
+		post(user.PathWebAuthnLoginFinish, "", "", es256.get(o.challenge, handle, 0x05))
+		o = begin(user.PathWebAuthnLoginBegin, "", "")
+		if ctx := post(user.PathWebAuthnLoginFinish, "", "", clone.get(o.challenge, handle, 0x05)); ctx.Status != 401 {
+			t.Errorf("stale sign count: %d", ctx.Status)
+		}
+		found := false
+		for _, e := range pub.SecurityEvents() {
+			if e.Type == user.EventCredentialCloned && e.UserID == u.Id {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("EventCredentialCloned not reported")
+		}
+	})
+
+	t.Run("Second factor", func(t *testing.T) {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathLogin}
+		ctx.SetHeader("Content-Type", "application/json")
+		ctx.SetHeader("Accept", "application/json")
+		json.Encode(&user.LoginData{Email: "pk@test.com", Password: "password123"}, &ctx.InBody)
+		r.Invoke("POST", user.PathLogin, ctx)
+		c, _ := ctx.Cookie("sess")
+		if ctx.Status != 401 || identify(c.Value) != "" {
+			t.Fatalf("password alone: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		partial := c.Value
+
+		o := begin(user.PathWebAuthnLoginBegin, "", partial)
+		if len(o.credentials) != 2 {
+			t.Errorf("allow_credentials: %v", o.credentials)
+		}
+		if ctx := post(user.PathWebAuthnLoginFinish, "", partial, rs256.get(o.challenge, "", 0x01)); ctx.Status != 401 {
+			t.Errorf("another user's passkey finished the login: %d", ctx.Status)
+		}
+
+		o = begin(user.PathWebAuthnLoginBegin, "", partial)
+		ctx = post(user.PathWebAuthnLoginFinish, "", partial, eddsa.get(o.challenge, "", 0x01))
+		if ctx.Status != 302 {
+			t.Fatalf("second step: %d %q", ctx.Status, ctx.ResponseBody())
+		}
+		c, _ = ctx.Cookie("sess")
+		if c.Value == partial || identify(c.Value) != u.Id {
+			t.Errorf("no full session issued: %+v", c)
+		}
+		if _, err := m.GetSession(partial); err == nil {
+			t.Error("partial session survived")
+		}
+	})
+}
+
+func TestWebAuthnAnonymousChallenges(t *testing.T) {
+	setup := func(cfg user.Config) *mock.Router {
+		cfg.IDs = testIDs
+		m, _ := authority.New(newTestDB(t), cfg)
+		m.Enable(webauthn.New(m, m, m, m, m, webauthn.RelyingParty{ID: testRPID, Name: "Acme", Origin: testOrigin}))
+		r := &mock.Router{}
+		m.MountAPI(r)
+		return r
+	}
+	begin := func(r *mock.Router) int {
+		ctx := &mock.Context{InMethod: "POST", InPath: user.PathWebAuthnLoginBegin}
+		r.Invoke("POST", user.PathWebAuthnLoginBegin, ctx)
+		return ctx.Status
+	}
+
+	t.Run("Capped while outstanding", func(t *testing.T) {
+		r := setup(user.Config{AnonymousChallenges: 2})
+		for i := 0; i < 2; i++ {
+			if status := begin(r); status == 429 {
+				t.Fatalf("begin %d refused", i)
+			}
+		}
+		if status := begin(r); status != 429 {
+			t.Errorf("third outstanding challenge: %d, want 429", status)
+		}
+	})
+
+	t.Run("Expired ones make room", func(t *testing.T) {
+		r := setup(user.Config{AnonymousChallenges: 2, ChallengeTTL: -1})
+		for i := 0; i < 4; i++ {
+			if status := begin(r); status == 429 {
+				t.Fatalf("begin %d refused", i)
+			}
+		}
+	})
+}
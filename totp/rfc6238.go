@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"net/url"
+
+	"github.com/tinywasm/fmt"
+)
+
+// Period and Digits are the RFC 6238 defaults every authenticator app
+// assumes when the otpauth URI doesn't say otherwise.
+const (
+	Period = 30
+	Digits = 6
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160-bit key, base32 encoded the way authenticator
+// apps expect it.
+func NewSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Step is the RFC 6238 time counter for a unix time.
+func Step(unix int64) int64 { return unix / Period }
+
+// Code is the HOTP value (RFC 4226) of secret at step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(fmt.Convert(secret).ToUpper().String())
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+
+	b := make([]byte, Digits)
+	for i := Digits - 1; i >= 0; i-- {
+		b[i] = '0' + byte(v%10)
+		v /= 10
+	}
+	return string(b), nil
+}
+
+// Match looks for code within window steps either side of unix and returns
+// the step it matched. Every candidate is compared in constant time.
+func Match(secret, code string, unix int64, window int) (int64, bool) {
+	now := Step(unix)
+	var found int64
+	ok := false
+	for d := -int64(window); d <= int64(window); d++ {
+		want, err := Code(secret, now+d)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 && !ok {
+			found, ok = now+d, true
+		}
+	}
+	return found, ok
+}
+
+// URI is the otpauth:// key URI an authenticator app reads from a QR code.
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
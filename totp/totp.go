@@ -0,0 +1,206 @@
+package totp
+
+import (
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/time"
+	"github.com/tinywasm/user"
+)
+
+// Factor is the TOTP second factor. It mounts the enrollment routes a signed-in
+// user manages it through, and Verify checks a code (or a recovery code) for
+// authority's POST /mfa/verify.
+type Factor struct {
+	users      user.IdentityStore
+	store      user.TOTPStore
+	notify     user.SecurityNotifier
+	issuer     string
+	window     int
+	trustProxy bool
+}
+
+type Option func(*Factor)
+
+// WithWindow accepts codes that many periods early or late, for clock drift.
+// Default 1.
+func WithWindow(steps int) Option { return func(f *Factor) { f.window = steps } }
+
+func WithTrustProxy(v bool) Option { return func(f *Factor) { f.trustProxy = v } }
+
+// New builds the factor. issuer is the app name the authenticator app shows
+// next to the account (the user's email).
+func New(users user.IdentityStore, store user.TOTPStore, notify user.SecurityNotifier, issuer string, opts ...Option) *Factor {
+	f := &Factor{users: users, store: store, notify: notify, issuer: issuer, window: 1}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+func (f *Factor) Name() string { return "totp" }
+
+type codeData struct{ Code string }
+
+func (d *codeData) IsNil() bool                      { return d == nil }
+func (d *codeData) DecodeFields(r model.FieldReader) { d.Code, _ = r.String("code") }
+
+// Enrollment is the answer to POST /mfa/totp/enroll: the secret for manual
+// entry and the otpauth URI for a QR code.
+type Enrollment struct {
+	Secret string
+	URI    string
+}
+
+func (e *Enrollment) IsNil() bool { return e == nil }
+func (e *Enrollment) EncodeFields(w model.FieldWriter) {
+	w.String("secret", e.Secret)
+	w.String("uri", e.URI)
+}
+
+// RecoveryCodes is the answer to POST /mfa/totp/confirm — the only time the
+// codes are ever shown.
+type RecoveryCodes []string
+
+func (c *RecoveryCodes) IsNil() bool { return c == nil }
+func (c *RecoveryCodes) EncodeFields(w model.FieldWriter) {
+	aw := w.Array("recovery_codes", len(*c))
+	for _, code := range *c {
+		aw.String(code)
+	}
+	aw.Close()
+}
+
+// Mount serves enrollment for the signed-in user: enroll mints a pending
+// secret, confirm activates it with a first code, disable turns it off with a
+// code or a recovery code.
+func (f *Factor) Mount(r router.Router) {
+	r.Post(user.PathTOTPEnroll, func(ctx router.Context) {
+		userID := caller(ctx)
+		if userID == "" {
+			ctx.WriteStatus(401)
+			return
+		}
+		if _, confirmed, err := f.store.TOTPSecret(userID); err == nil && confirmed {
+			user.RespondError(ctx, 409, user.CodeInvalidRequest, user.ErrMFAEnabled)
+			return
+		}
+		secret, err := NewSecret()
+		if err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		if err := f.store.SaveTOTPSecret(userID, secret); err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		u, err := f.users.UserByID(userID)
+		if err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		ctx.Encode(&Enrollment{Secret: secret, URI: URI(f.issuer, u.Email, secret)})
+	}).Authenticated()
+
+	r.Post(user.PathTOTPConfirm, func(ctx router.Context) {
+		userID := caller(ctx)
+		if userID == "" {
+			ctx.WriteStatus(401)
+			return
+		}
+		data := &codeData{}
+		if err := ctx.Decode(data); err != nil {
+			ctx.WriteStatus(400)
+			return
+		}
+		secret, confirmed, err := f.store.TOTPSecret(userID)
+		if err != nil || confirmed {
+			user.RespondError(ctx, 409, user.CodeInvalidRequest, user.ErrMFAEnabled)
+			return
+		}
+		step, ok := Match(secret, clean(data.Code), time.Now()/1e9, f.window)
+		if !ok {
+			f.report(ctx, userID)
+			user.RespondError(ctx, 400, user.CodeInvalidCode, user.ErrInvalidCode)
+			return
+		}
+		codes, err := f.store.ConfirmTOTP(userID, step)
+		if err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		rc := RecoveryCodes(codes)
+		ctx.Encode(&rc)
+	}).Authenticated()
+
+	r.Post(user.PathTOTPDisable, func(ctx router.Context) {
+		userID := caller(ctx)
+		if userID == "" {
+			ctx.WriteStatus(401)
+			return
+		}
+		data := &codeData{}
+		if err := ctx.Decode(data); err != nil {
+			ctx.WriteStatus(400)
+			return
+		}
+		if err := f.Verify(userID, data.Code); err != nil {
+			f.report(ctx, userID)
+			user.RespondError(ctx, 400, user.CodeInvalidCode, user.ErrInvalidCode)
+			return
+		}
+		if err := f.store.DisableTOTP(userID); err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		f.notify.Notify(user.SecurityEvent{Type: user.EventMFADisabled, UserID: userID, Mode: f.Name()})
+		ctx.WriteStatus(204)
+	}).Authenticated()
+}
+
+// Enrolled makes Factor a user.SecondFactor: once a user confirms it, their
+// logins go through POST /mfa/verify.
+func (f *Factor) Enrolled(userID string) bool {
+	_, confirmed, err := f.store.TOTPSecret(userID)
+	return err == nil && confirmed
+}
+
+// Verify accepts a current code, at most once per period, or else one of the
+// user's unused recovery codes.
+func (f *Factor) Verify(userID, code string) error {
+	secret, confirmed, err := f.store.TOTPSecret(userID)
+	if err != nil || !confirmed {
+		return user.ErrInvalidCode
+	}
+	code = clean(code)
+	if step, ok := Match(secret, code, time.Now()/1e9, f.window); ok {
+		return f.store.UseTOTPStep(userID, step)
+	}
+	if err := f.store.UseRecoveryCode(userID, code); err != nil {
+		return user.ErrInvalidCode
+	}
+	return nil
+}
+
+func (f *Factor) report(ctx router.Context, userID string) {
+	f.notify.Notify(user.SecurityEvent{Type: user.EventMFAFailed, IP: user.ClientIP(ctx, f.trustProxy), UserID: userID, Mode: f.Name()})
+}
+
+// caller is the signed-in user managing their factor. A restricted session
+// (see user.ScopedUserID) can't.
+func caller(ctx router.Context) string {
+	scope, userID := user.SplitScopedUserID(ctx.UserID())
+	if scope != "" {
+		return ""
+	}
+	return userID
+}
+
+func clean(code string) string {
+	return fmt.Convert(code).TrimSpace().Replace(" ", "").String()
+}
+
+var (
+	_ user.Authenticator = (*Factor)(nil)
+	_ user.CodeFactor    = (*Factor)(nil)
+)
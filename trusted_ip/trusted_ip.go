@@ -14,12 +14,17 @@ type Authenticator struct {
 	notify     user.SecurityNotifier
 	trustProxy bool
 	afterLogin string
+	limiter    user.RateLimiter
 }
 
 type Option func(*Authenticator)
 
 func WithAfterLogin(path string) Option { return func(a *Authenticator) { a.afterLogin = path } }
 
+// WithRateLimiter limits POST /login/rut per client IP and per RUT (e.g.
+// ratelimit.New).
+func WithRateLimiter(l user.RateLimiter) Option { return func(a *Authenticator) { a.limiter = l } }
+
 // New builds the trusted-IP mode. trustProxy is required (not an Option): this
 // mode's entire security property is "the request's real IP is on the
 // allowlist" — silently defaulting it to false behind a real proxy would make
@@ -49,46 +54,52 @@ func (a *Authenticator) Mount(r router.Router) {
 		ip := user.ClientIP(ctx, a.trustProxy)
 		data := &loginRUTData{}
 		if err := ctx.Decode(data); err != nil {
-			ctx.WriteStatus(400)
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, nil)
 			return
 		}
 
 		normalized, err := ValidateRUT(data.RUT)
+		key := normalized
+		if err != nil {
+			key = data.RUT
+		}
+		if !user.Allowed(a.limiter, ip, key) {
+			a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, UserID: key, Mode: a.Name()})
+			user.RespondError(ctx, 429, user.CodeRateLimited, user.ErrRateLimited)
+			return
+		}
+
 		if err != nil {
-			ctx.WriteStatus(401)
-			ctx.Write([]byte(err.Error()))
+			user.RespondError(ctx, 401, user.CodeInvalidCredentials, err)
 			return
 		}
 
 		identity, err := a.store.IdentityByProvider("trusted_ip", normalized)
 		if err != nil {
-			ctx.WriteStatus(401)
-			ctx.Write([]byte(user.ErrInvalidCredentials.Error()))
+			user.RespondError(ctx, 401, user.CodeInvalidCredentials, user.ErrInvalidCredentials)
 			return
 		}
 		u, err := a.store.UserByID(identity.UserId)
 		if err != nil {
-			ctx.WriteStatus(401)
+			user.RespondError(ctx, 401, user.CodeInvalidCredentials, nil)
 			return
 		}
 		if u.Status != "active" {
 			a.notify.Notify(user.SecurityEvent{Type: user.EventNonActiveAccess, UserID: u.Id})
-			ctx.WriteStatus(401)
+			user.RespondError(ctx, 401, user.CodeSuspended, nil)
 			return
 		}
 		if !a.trusted.IsTrustedIP(u.Id, ip) {
 			a.notify.Notify(user.SecurityEvent{Type: user.EventIPMismatch, UserID: u.Id, IP: ip})
-			ctx.WriteStatus(401)
-			ctx.Write([]byte(user.ErrInvalidCredentials.Error()))
+			user.RespondError(ctx, 401, user.CodeIPMismatch, user.ErrInvalidCredentials)
 			return
 		}
 
 		if err := a.sessions.IssueSession(ctx, u.Id); err != nil {
-			ctx.WriteStatus(500)
+			user.RespondIssueError(ctx, err)
 			return
 		}
-		ctx.SetHeader("Location", afterLogin)
-		ctx.WriteStatus(302)
+		user.RespondLogin(ctx, user.NextOr(ctx, afterLogin), a.store, u)
 	}).Public()
 }
 
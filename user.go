@@ -20,6 +20,23 @@ var (
 	ErrInvalidRUT         = fmt.Err("rut", "invalid")               // EN: Rut Invalid                      / ES: Rut Inválido
 	ErrRUTTaken           = fmt.Err("rut", "registered")            // EN: Rut Registered                   / ES: Rut Registrado
 	ErrIPTaken            = fmt.Err("ip", "registered")             // EN: Ip Registered                    / ES: Ip Registrado
+	ErrInvalidEmail       = fmt.Err("email", "invalid")             // EN: Email Invalid                    / ES: Correo electrónico Inválido
+	ErrNameRequired       = fmt.Err("name", "required")             // EN: Name Required                    / ES: Nombre Requerido
+	ErrInvalidToken       = fmt.Err("token", "invalid")             // EN: Token Invalid                    / ES: Token Inválido
+	ErrEmailUnverified    = fmt.Err("email", "unverified")          // EN: Email Unverified                 / ES: Correo electrónico No verificado
+	ErrPasswordMismatch   = fmt.Err("password", "mismatch")         // EN: Password Mismatch                / ES: Contraseña No coincide
+	ErrPasswordTooLong    = fmt.Err("password", "too", "long")      // EN: Password Too Long                / ES: Contraseña Demasiado Larga
+	ErrAccountLocked      = fmt.Err("account", "locked")            // EN: Account Locked                   / ES: Cuenta Bloqueada
+	ErrRateLimited        = fmt.Err("too", "many", "attempts")      // EN: Too Many Attempts                / ES: Demasiados Intentos
+	ErrPasswordReused     = fmt.Err("password", "reused")           // EN: Password Reused                  / ES: Contraseña Reutilizada
+	ErrPasswordTooRecent  = fmt.Err("password", "too", "recent")    // EN: Password Too Recent              / ES: Contraseña Demasiado Reciente
+	ErrInvalidCode        = fmt.Err("code", "invalid")              // EN: Code Invalid                     / ES: Código Inválido
+	ErrMFAEnabled         = fmt.Err("mfa", "enabled")               // EN: Mfa Enabled                      / ES: Mfa Habilitado
+	ErrMFARequired        = fmt.Err("mfa", "required")              // EN: Mfa Required                     / ES: Mfa Requerido
+	ErrInvalidAttestation = fmt.Err("attestation", "invalid")       // EN: Attestation Invalid              / ES: Atestación Inválida
+	ErrIdentityLinked     = fmt.Err("identity", "linked")           // EN: Identity Linked                  / ES: Identidad Vinculada
+	ErrSignupClosed       = fmt.Err("signup", "closed")             // EN: Signup Closed                    / ES: Registro Cerrado
+	ErrSignupFailed       = fmt.Err("signup", "failed")             // EN: Signup Failed                    / ES: Registro Fallido
 )
 
 type SecurityEventType uint8
@@ -34,7 +51,17 @@ const (
 	EventUnauthorizedAccess                          // validateSession: cookie present but session invalid
 	EventAccessDenied                                // AccessCheck: RBAC denied with valid session
 	EventPermissionCorrupt                           // HasPermission: permissions.action is not a CRUD string
-	EventRateLimited                                 // POST /login: Config.RateLimit rejected the attempt before bcrypt
+	EventRateLimited                                 // any mode: its rate limiter rejected the attempt before any credential check; Mode says which
+	EventPasswordReset                               // POST /password/reset: password replaced via a reset token, sessions revoked
+	EventPendingAccess                               // Login: right password on an account whose email is not verified yet
+	EventPasswordChanged                             // change_password: the user replaced their own password
+	EventAccountLocked                               // Login: too many consecutive failures for one email; UserID carries that email
+	EventMFAFailed                                   // a second factor was wrong or replayed; Mode says which
+	EventMFADisabled                                 // a second factor was turned off, by its user or by an admin's reset_mfa
+	EventCredentialCloned                            // webauthn: an assertion's sign count didn't move forward; the authenticator may be cloned
+	EventIdentityLinked                              // oauth2: a signed-in user linked another provider to their account; Provider says which
+	EventIdentityUnlinked                            // unlink_identity: a user removed one of their login methods; Provider says which
+	EventProvisionDenied                             // oauth2: an unknown user was refused an account (provisioning off, domain or tenant not allowed); UserID carries the email
 )
 
 type SecurityEvent struct {
@@ -43,6 +70,7 @@ type SecurityEvent struct {
 	UserID    string // empty if user not yet identified
 	Provider  string // OAuth provider name, for OAuth events
 	Resource  string // RBAC resource, for EventAccessDenied
+	Mode      string // Authenticator.Name() of the reporting mode, empty for authority's own events
 	Timestamp int64  // time.Now().Unix()
 }
 
@@ -52,6 +80,7 @@ func (e *SecurityEvent) EncodeFields(w model.FieldWriter) {
 	w.String("user_id", e.UserID)
 	w.String("provider", e.Provider)
 	w.String("resource", e.Resource)
+	w.String("mode", e.Mode)
 	w.Int("timestamp", e.Timestamp)
 }
 
@@ -62,20 +91,38 @@ type OAuthUserInfo struct {
 	Email  string
 	Name   string
 	Avatar string
+
+	// EmailVerified is the provider's word that the user proved they own
+	// Email. Only then does the default oauth2 link policy attach the login
+	// to an existing account with that address.
+	EmailVerified bool
+
+	// Tenant is the organization the IdP says the account belongs to: the
+	// Google Workspace domain (hd) or the Entra ID tenant (tid). Empty for
+	// personal accounts.
+	Tenant string
 }
 
 // OAuthToken is what a provider returns when it exchanges the code. It replaces
 // oauth2.Token: that type dragged net/http in, and net/http does not exist under TinyGo —
 // which put this whole module out of the edge for one function call.
 type OAuthToken struct {
-	AccessToken string
-	TokenType   string
-	ExpiresIn   int
+	AccessToken  string
+	TokenType    string
+	ExpiresIn    int
+	IDToken      string // OpenID Connect providers only
+	RefreshToken string // only when the provider grants offline access
+
+	// Expiry is when AccessToken stops working, in unix seconds; 0 if the
+	// provider didn't say. A ProviderTokenStore sets it from ExpiresIn.
+	Expiry int64
 }
 
 func (t *OAuthToken) DecodeFields(r model.FieldReader) {
 	t.AccessToken, _ = r.String("access_token")
 	t.TokenType, _ = r.String("token_type")
+	t.IDToken, _ = r.String("id_token")
+	t.RefreshToken, _ = r.String("refresh_token")
 	exp, _ := r.Int("expires_in")
 	t.ExpiresIn = int(exp)
 }
@@ -92,13 +139,39 @@ type OAuthConfig struct {
 	TokenURL     string // provider's token endpoint
 }
 
+// OAuthProvider is one IdP. challenge and verifier are the PKCE (RFC 7636, S256)
+// pair the oauth2 mode generated for this login; either is "" when PKCE is off.
 type OAuthProvider interface {
 	Name() string
-	AuthCodeURL(state string) string
-	ExchangeCode(code string) (OAuthToken, error)
+	AuthCodeURL(state, challenge string) string
+	ExchangeCode(code, verifier string) (OAuthToken, error)
 	GetUserInfo(token OAuthToken) (OAuthUserInfo, error)
 }
 
+// OIDCProvider is optionally implemented by an OAuthProvider whose id_token
+// carries a nonce (OpenID Connect). The oauth2 mode then mints a nonce per
+// login, keeps it with the state, and has the provider check it.
+type OIDCProvider interface {
+	OAuthProvider
+	AuthCodeURLWithNonce(state, challenge, nonce string) string
+	VerifiedUserInfo(token OAuthToken, nonce string) (OAuthUserInfo, error)
+}
+
+// OAuthRefresher is optionally implemented by an OAuthProvider that can
+// redeem a refresh token (grant_type=refresh_token) for a new access token.
+// oauth2's TokenFor uses it once a stored token is about to expire.
+type OAuthRefresher interface {
+	RefreshToken(refreshToken string) (OAuthToken, error)
+}
+
+// ProviderTokenStore is the port oauth2.WithTokenStore saves each login's
+// provider tokens through, one set per (user, provider) identity. authority
+// seals them with Config.TokenKey.
+type ProviderTokenStore interface {
+	SaveProviderToken(userID, provider string, t OAuthToken) error
+	ProviderToken(userID, provider string) (OAuthToken, error)
+}
+
 // Authenticator is one login mode. It owns its HTTP routes completely — authority
 // never inspects, duplicates, or knows the shape of what it mounts.
 type Authenticator interface {
@@ -106,14 +179,135 @@ type Authenticator interface {
 	Mount(r router.Router)
 }
 
+// RateLimiter decides whether one more attempt under key may proceed. Every
+// mode accepts one through its WithRateLimiter option; ratelimit.Limiter is the
+// ready-made implementation.
+type RateLimiter interface {
+	Allow(key string) bool
+}
+
+// Allowed asks l about both keys a login attempt carries: the client IP and,
+// when known, the identifier it targets. One IP spraying many accounts and many
+// IPs hammering one account are both caught. The identifier is trimmed and
+// lowercased first, so "Ann@x.com " shares a bucket with "ann@x.com". A nil l
+// allows everything.
+func Allowed(l RateLimiter, ip, identifier string) bool {
+	if l == nil {
+		return true
+	}
+	ok := l.Allow("ip:" + ip)
+	identifier = fmt.Convert(identifier).TrimSpace().ToLower().String()
+	if identifier != "" && !l.Allow("id:"+identifier) {
+		ok = false
+	}
+	return ok
+}
+
 // SessionStrategy is how identity survives across requests after a successful
 // login. authority holds exactly one (default: session/cookie); the consumer may
 // swap it via Module.SetStrategy before mounting. Implementations: session/cookie,
 // session/jwt.
 type SessionStrategy interface {
-	Issue(ctx router.Context, userID string) error          // starts a session, writes the credential onto ctx's response
-	Identify(ctx router.Context) (userID string, err error) // reads the incoming credential; "" only alongside a non-nil err
-	Revoke(ctx router.Context) error                        // ends the session named by ctx's incoming credential
+	Issue(ctx router.Context, userID string, opts ...SessionOption) error // starts a session, writes the credential onto ctx's response
+	Identify(ctx router.Context) (userID string, err error)               // reads the incoming credential; "" only alongside a non-nil err
+	Revoke(ctx router.Context) error                                      // ends the session named by ctx's incoming credential
+}
+
+// SessionOptions tunes one issued session. The zero value is a full session.
+type SessionOptions struct {
+	// Scope restricts the session to the one op it names (e.g.
+	// OpChangePassword). Identify then reports ScopedUserID(Scope, userID)
+	// instead of the bare id, so everything else treats the caller as unknown.
+	Scope string
+
+	// Remember asks for a long-lived session (Config.RememberTTL) carried in a
+	// persistent cookie. Without it, once remember-me is configured, the
+	// session is short (Config.TokenTTL) and its cookie dies with the browser.
+	Remember bool
+
+	// TTL overrides the session's lifetime in seconds, for short-lived
+	// partial sessions; its cookie dies with the browser. 0 = the default.
+	TTL int
+}
+
+type SessionOption func(*SessionOptions)
+
+func WithScope(scope string) SessionOption { return func(o *SessionOptions) { o.Scope = scope } }
+
+func WithRemember(remember bool) SessionOption {
+	return func(o *SessionOptions) { o.Remember = remember }
+}
+
+func WithTTL(seconds int) SessionOption { return func(o *SessionOptions) { o.TTL = seconds } }
+
+// ScopeMFA is the scope of a partial session: the password (or any first
+// factor) checked out, a second factor is still owed. Like every scope, it is
+// never set as ctx.UserID by authority's Authenticate middleware — only
+// PendingUser sees it.
+const ScopeMFA = "mfa"
+
+// ApplySessionOptions folds opts into a SessionOptions — for strategies and
+// repos that receive them.
+func ApplySessionOptions(opts []SessionOption) SessionOptions {
+	var o SessionOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ScopedUserID is the ctx.UserID() of a restricted session. It is never a real
+// user id: Can, GetUser and any app route that looks the caller up fail
+// closed. Only a handler that knows the scope accepts it (SplitScopedUserID).
+func ScopedUserID(scope, userID string) string {
+	if scope == "" {
+		return userID
+	}
+	return scope + "#" + userID
+}
+
+// SplitScopedUserID undoes ScopedUserID; scope is "" for a full session.
+func SplitScopedUserID(id string) (scope, userID string) {
+	if i := fmt.Index(id, "#"); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	return "", id
+}
+
+// CurrentSession is optionally implemented by a SessionStrategy whose
+// credential names a SessionRepo row (session/cookie does; session/jwt has no
+// rows). authority uses it to spare the caller's own session when it revokes
+// the others.
+type CurrentSession interface {
+	SessionID(ctx router.Context) (id string, ok bool)
+}
+
+// SecondFactor is an Authenticator that also guards logins: authority.Enable
+// records every one, and once any is Enrolled for a user, IssueSession hands
+// out a partial ScopeMFA session instead and answers ErrMFARequired.
+type SecondFactor interface {
+	Enrolled(userID string) bool
+}
+
+// CodeFactor is a SecondFactor checked with a typed code (totp). authority's
+// POST /mfa/verify tries every enrolled one.
+type CodeFactor interface {
+	SecondFactor
+	Verify(userID, code string) error
+}
+
+// AddressLogin is implemented by modes that sign a user in through their
+// email or phone alone, with no Identity row (magic_link, otp_code). While one
+// that Reaches a user is enabled, that user can unlink their last Identity.
+type AddressLogin interface {
+	Reaches(u User) bool
+}
+
+// PendingMFA is the port a second factor with routes of its own uses to finish
+// a two-phase login.
+type PendingMFA interface {
+	PendingUser(ctx router.Context) (userID string, err error)                  // the user ctx's partial session awaits; ErrSessionExpired if none
+	CompleteMFA(ctx router.Context, userID string, opts ...SessionOption) error // swaps the partial session for a full one
 }
 
 // --- Ports a mode receives at construction. It asks for ONLY the ones it needs —
@@ -123,7 +317,7 @@ type SessionStrategy interface {
 // SessionIssuer lets a mode start a session after verifying credentials, without
 // knowing whether the app carries it in a cookie or a signed JWT.
 type SessionIssuer interface {
-	IssueSession(ctx router.Context, userID string) error
+	IssueSession(ctx router.Context, userID string, opts ...SessionOption) error
 }
 
 // IdentityStore is the persistence port a mode uses to resolve or register the
@@ -143,11 +337,151 @@ type IdentityStore interface {
 	UpdateUserAvatar(userID, avatar string) error
 }
 
+// RoleAssigner is the port a mode uses to give the accounts it creates a
+// starting role (oauth2.WithDefaultRole).
+type RoleAssigner interface {
+	AssignRole(userID, roleID string) error
+}
+
+// PendingAccounts is optionally implemented by the IdentityStore oauth2
+// receives (authority does). A provider login whose verified email matches a
+// "pending" sign-up claims the account through it instead of joining it as
+// is.
+type PendingAccounts interface {
+	// ClaimPending activates a pending account and drops the password it was
+	// created with, along with its verification links.
+	ClaimPending(userID string) error
+}
+
+// UserRemover is optionally implemented by the IdentityStore a mode receives
+// (authority does). oauth2 uses it to take back an account it just created
+// when the starting role can't be given, so no role-less user is left behind.
+type UserRemover interface {
+	DeleteUser(id string) error
+}
+
+// PasswordStore is the credential-write port a mode uses when the user picks
+// their own password (sign-up). authority applies the same policy to it that
+// Module.SetPassword enforces everywhere else.
+type PasswordStore interface {
+	ValidatePassword(password string, personal ...string) error // the policy SetPassword enforces, without writing
+	SetPassword(userID, password string) error
+}
+
+// PasswordResetStore is the recovery port email_password uses for forgotten
+// passwords. authority owns the password_reset table and keeps only a hash of
+// each token.
+type PasswordResetStore interface {
+	CreateResetToken(userID string) (token string, err error) // replaces any earlier token for userID
+	// ResetPassword consumes token (single-use, validates expiry), stores
+	// password under the same policy as SetPassword and revokes every session
+	// of the user. It returns whose password changed.
+	ResetPassword(token, password string) (userID string, err error)
+}
+
+// EmailVerifier is the port email_password uses to prove a new account owns its
+// email. authority owns the email_verification table and the "pending" status.
+type EmailVerifier interface {
+	// CreateVerification marks userID "pending" and mints its verification
+	// token, replacing any earlier one.
+	CreateVerification(userID string) (token string, err error)
+	// VerifyEmail consumes token (single-use, validates expiry) and activates
+	// the account. It returns whose email was verified.
+	VerifyEmail(token string) (userID string, err error)
+}
+
+// LoginGuard is the per-account lockout port email_password consults around
+// every password check. It is keyed by the identifier the caller typed (the
+// email), not by user ID, so guessing against an email with no account locks
+// exactly like guessing against a real one.
+type LoginGuard interface {
+	Locked(identifier string) bool
+	RecordFailure(identifier, ip string) // may lock the identifier and report EventAccountLocked
+	RecordSuccess(identifier string)
+}
+
+// MailKind says which one-time link a Mailer is delivering, so the app can
+// pick the subject and wording.
+type MailKind uint8
+
+const (
+	MailPasswordReset MailKind = iota // link to the app's reset page, carries ?token=
+	MailVerifyEmail                   // link to GET /verify-email, carries ?token=
+	MailMagicLink                     // link to GET /login/link/verify, carries ?token=
+)
+
+// Mailer is the delivery port for the one-time links the email flows mint. The
+// app owns the transport and the wording; a mode only says who, what for, and
+// the link.
+type Mailer interface {
+	Send(to string, kind MailKind, link string) error
+}
+
 // StateStore is the anti-CSRF port the oauth2 mode uses for its one-time state
 // token. authority owns the oauth_state table; a mode never touches it directly.
+// The row also carries what the callback needs back, such as the PKCE verifier.
 type StateStore interface {
-	CreateState(provider string) (state string, err error)
-	ConsumeState(state, provider string) error // single-use: deletes on read, validates provider+expiry
+	CreateState(s OAuthState) (state string, err error)      // s.Provider and payload; State and expiry are set here
+	ConsumeState(state, provider string) (OAuthState, error) // single-use: deletes on read, validates provider+expiry
+}
+
+// MagicLinkStore is the one-time login link port the magic_link mode uses, the
+// way StateStore serves oauth2. authority owns the magic_link table and keeps
+// only a hash of each token.
+type MagicLinkStore interface {
+	CreateMagicLink(userID string) (token string, err error)  // replaces any earlier link for userID
+	ConsumeMagicLink(token string) (userID string, err error) // single-use: deletes on read, validates expiry
+}
+
+// LoginCodeStore is the one-time code port the otp_code mode uses. A code is
+// bound to the client that asked for it: client is an opaque value only that
+// client holds (otp_code keeps it in a cookie), so the code alone is useless
+// on another device. authority owns the login_code table and keeps only hashes.
+type LoginCodeStore interface {
+	CreateLoginCode(userID, client string) (code string, err error) // replaces any earlier code for userID; ErrRateLimited past Config.LoginCodeSends
+	VerifyLoginCode(client, code string) (userID string, err error) // single-use; Config.LoginCodeAttempts wrong guesses burn it
+}
+
+// TOTPStore is the storage port the totp factor uses. authority owns the totp
+// and recovery_code tables; the factor owns the RFC 6238 math. Recovery codes
+// are stored hashed and handed out in clear exactly once, by ConfirmTOTP.
+type TOTPStore interface {
+	TOTPSecret(userID string) (secret string, confirmed bool, err error) // ErrNotFound when none
+	SaveTOTPSecret(userID, secret string) error                          // starts an unconfirmed enrollment, replacing any earlier one
+	ConfirmTOTP(userID string, step int64) (recovery []string, err error)
+	UseTOTPStep(userID string, step int64) error // replay guard: ErrInvalidCode for a step at or before the last one used
+	UseRecoveryCode(userID, code string) error   // single-use
+	DisableTOTP(userID string) error             // drops the secret and every recovery code
+}
+
+// ChallengeStore is the one-time challenge port the webauthn mode uses, the
+// way StateStore serves oauth2. authority owns the webauthn_challenge table.
+type ChallengeStore interface {
+	CreateChallenge(userID, purpose string) (challenge string, err error)  // ErrRateLimited while too many user-less ones are outstanding
+	ConsumeChallenge(challenge, purpose string) (userID string, err error) // single-use: deletes on read, validates purpose+expiry
+}
+
+// CredentialStore keeps webauthn public keys. Each credential is also a
+// "webauthn" Identity of its user, so it lists and unlinks like any other.
+type CredentialStore interface {
+	SaveCredential(c WebAuthnCredential) error // ErrInvalidAttestation if the id is taken
+	CredentialByID(id string) (WebAuthnCredential, error)
+	CredentialsFor(userID string) ([]WebAuthnCredential, error)
+	UpdateSignCount(id string, count int64) error
+}
+
+// CodeChannel says where a one-time login code is delivered.
+type CodeChannel uint8
+
+const (
+	CodeByEmail CodeChannel = iota // to User.Email
+	CodeBySMS                      // to User.Phone
+)
+
+// CodeSender is the delivery port for one-time login codes. Like Mailer, the
+// app owns the transport and the wording.
+type CodeSender interface {
+	SendCode(to string, channel CodeChannel, code string) error
 }
 
 // TrustedIPStore is the read-only port the trusted_ip mode uses to check whether
@@ -158,6 +492,14 @@ type TrustedIPStore interface {
 	IsTrustedIP(userID, ip string) bool
 }
 
+// PasswordExpiry is optionally implemented by the IdentityStore a mode receives
+// (authority does). email_password asks it after a correct password: a user
+// who must change theirs gets a session scoped to OpChangePassword only.
+type PasswordExpiry interface {
+	MustChangePassword(userID string) bool // admin-set password, or older than Config.PasswordMaxAge
+	StartPasswordClock(userID string)      // after a correct password: starts the max-age clock of a password that has none
+}
+
 // SecurityNotifier lets a mode report a SecurityEvent without knowing whether
 // anything is subscribed.
 type SecurityNotifier interface {
@@ -167,11 +509,29 @@ type SecurityNotifier interface {
 // SessionRepo is the storage port a SessionStrategy uses to persist stateful
 // sessions. authority.Module implements it with its own table + cache.
 type SessionRepo interface {
-	CreateSession(userID, ip, userAgent string) (Session, error)
+	CreateSession(userID, ip, userAgent string, opts ...SessionOption) (Session, error)
 	GetSession(id string) (Session, error)
 	DeleteSession(id string) error
 }
 
+// QueryParam returns the raw value of key in ctx's query string, "" if absent.
+// Shared by every GET route that receives a one-time value in its link (OAuth
+// callbacks, email verification).
+func QueryParam(ctx router.Context, key string) string {
+	path := ctx.Path()
+	if !fmt.Contains(path, "?") {
+		return ""
+	}
+	query := fmt.Split(path, "?")[1]
+	for _, part := range fmt.Split(query, "&") {
+		kv := fmt.Split(part, "=")
+		if len(kv) == 2 && kv[0] == key {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
 // ClientIP extracts the caller's IP from ctx. When trustProxy is true it reads
 // X-Forwarded-For / X-Real-IP first (only safe behind a reverse proxy you control —
 // otherwise a client can spoof its own IP). Shared by every mode/strategy that
@@ -206,6 +566,12 @@ type Config struct {
 	CookieName string // default: "session"
 	TokenTTL   int    // default: 86400 (seconds)
 
+	// RememberTTL turns on remember-me: a login with LoginData.Remember gets
+	// a session this long in a persistent cookie, any other login a
+	// TokenTTL session in a browser-session cookie. 0 = off: every session
+	// lasts TokenTTL and its cookie persists that long.
+	RememberTTL int
+
 	// TrustProxy tells every IP-extracting collaborator (the default cookie
 	// strategy, Module.LoginLAN) whether to trust X-Forwarded-For/X-Real-IP.
 	// The composition root passes this SAME value to any mode it constructs
@@ -223,16 +589,112 @@ type Config struct {
 	// are dropped (fire-and-forget contract), never an error.
 	Events events.Publisher
 
-	// OnPasswordValidate is consulted by Module.SetPassword before hashing.
-	// Return a non-nil error to reject the password. nil = only the built-in
-	// len>=8 check applies.
+	// PasswordPolicy is the declarative rule set Module.SetPassword and
+	// Module.ValidatePassword enforce; a rejection is a *PolicyError listing
+	// every broken rule. nil = only the built-in len>=8 check applies.
+	PasswordPolicy *PasswordPolicy
+
+	// OnPasswordValidate is consulted by Module.SetPassword (and by
+	// Module.ValidatePassword, which sign-up calls) before hashing, after
+	// PasswordPolicy. Return a non-nil error to reject the password.
 	OnPasswordValidate func(password string) error
+
+	// PasswordHistory refuses a new password equal to any of the user's last
+	// N (the current one included); every SetPassword pays up to N hash
+	// comparisons. PasswordMinAge is how long change_password makes a user
+	// wait after their last change — it stops cycling through N throwaway
+	// passwords back to the old one. Resets and admin sets ignore it. 0 = off.
+	PasswordHistory int
+	PasswordMinAge  int // seconds
+
+	// PasswordMaxAge flags a password older than this many seconds as
+	// must-change, like an admin-set one (Module.SetTemporaryPassword). The
+	// clock of a password set before this was configured starts at the
+	// user's next password login. Users without a password are never
+	// flagged. 0 = passwords never expire.
+	PasswordMaxAge int
+
+	// ResetTokenTTL is how long a password reset link stays valid.
+	ResetTokenTTL int // default: 3600 (seconds)
+
+	// VerifyTokenTTL is how long an email verification link stays valid.
+	VerifyTokenTTL int // default: 86400 (seconds)
+
+	// MagicLinkTTL is how long a passwordless login link stays valid.
+	MagicLinkTTL int // default: 900 (seconds)
+
+	// LoginCodeTTL is how long a one-time login code stays valid;
+	// LoginCodeAttempts how many wrong guesses burn it; LoginCodeSends how
+	// many codes one user can be sent per hour, with or without a limiter.
+	LoginCodeTTL      int // default: 300 (seconds)
+	LoginCodeAttempts int // default: 5
+	LoginCodeSends    int // default: 5
+
+	// MFASessionTTL is how long a partial session waits for the second
+	// factor. MFAAttempts is how many wrong codes one partial session may post
+	// before it is ended; MFAUserAttempts how many one user may post per hour
+	// across partial sessions. Both hold whether or not LockThreshold is set.
+	MFASessionTTL   int // default: 300 (seconds)
+	MFAAttempts     int // default: 5
+	MFAUserAttempts int // default: 20
+
+	// RateLimiter, when set, is asked on the routes authority serves itself
+	// (POST /mfa/verify), per client IP and per user, like a login mode's
+	// WithRateLimiter.
+	RateLimiter RateLimiter
+
+	// ChallengeTTL is how long a webauthn ceremony may take;
+	// AnonymousChallenges how many passwordless-login challenges, which need
+	// no account to ask for, may be outstanding at once.
+	ChallengeTTL        int // default: 300 (seconds)
+	AnonymousChallenges int // default: 1000
+
+	// TokenKey is the 32-byte AES-256 key provider tokens are encrypted with
+	// (oauth2.WithTokenStore). Load it from a secret, never from the
+	// database it protects. nil = provider tokens can't be stored.
+	TokenKey []byte
+
+	// RevokeOnPasswordChange makes change_password end every other session of
+	// the user; the one that made the change stays valid.
+	RevokeOnPasswordChange bool
+
+	// LockThreshold turns on per-account lockout: that many consecutive failed
+	// logins for one email lock it for LockWindow seconds, doubling with each
+	// further lock up to LockMaxWindow. 0 = no lockout.
+	LockThreshold int
+	LockWindow    int // default: 60 (seconds)
+	LockMaxWindow int // default: 3600 (seconds)
 }
 
 const (
-	PathLogin      = "/login"
-	PathLogout     = "/logout"
-	PathAfterLogin = "/"
+	PathLogin    = "/login"
+	PathLogout   = "/logout"
+	PathRegister = "/register"
+
+	PathPasswordForgot    = "/password/forgot"
+	PathPasswordReset     = "/password/reset"
+	PathVerifyEmail       = "/verify-email"
+	PathVerifyEmailResend = "/verify-email/resend"
+	PathAfterLogin        = "/"
+
+	PathMagicLink       = "/login/link"
+	PathMagicLinkVerify = "/login/link/verify"
+	PathLoginCode       = "/login/code"
+	PathLoginCodeVerify = "/login/code/verify"
+
+	// PathMFA is the app's own second-factor page: a form login lands there
+	// when a second factor is owed, and posts the code to PathMFAVerify.
+	PathMFA       = "/mfa"
+	PathMFAVerify = "/mfa/verify"
+
+	PathTOTPEnroll  = "/mfa/totp/enroll"
+	PathTOTPConfirm = "/mfa/totp/confirm"
+	PathTOTPDisable = "/mfa/totp/disable"
+
+	PathWebAuthnRegisterBegin  = "/webauthn/register/begin"
+	PathWebAuthnRegisterFinish = "/webauthn/register/finish"
+	PathWebAuthnLoginBegin     = "/webauthn/login/begin"
+	PathWebAuthnLoginFinish    = "/webauthn/login/finish"
 )
 
 // TopicSecurity is the events topic every SecurityEvent is published on.
@@ -244,6 +706,13 @@ const (
 	OpListUsers  = "list_users"  // admin: list users
 	OpUpsertUser = "upsert_user" // admin: create (Id=="") or update
 	OpDeleteUser = "delete_user" // admin: delete by record
+	OpResetMFA   = "reset_mfa"   // admin: drop a user's second factor so they can enroll again
+
+	OpChangePassword       = "change_password"        // authenticated caller replaces their own password
+	OpSetTemporaryPassword = "set_temporary_password" // admin: set a password the user must change at next login
+
+	OpListIdentities = "list_identities" // authenticated caller's linked login methods
+	OpUnlinkIdentity = "unlink_identity" // authenticated caller removes one of them, by Identity.Id
 )
 
 // ProfileDTO is a safe subset of User data for public/API consumption.
@@ -256,6 +725,10 @@ type ProfileDTO struct {
 	RoleNames   []string
 	Permissions []string // "resource:actions" pairs, e.g. "service_catalog:rc"
 	Locale      string
+
+	// MustChangePassword tells the shell to send the user to its
+	// change-password page: until they do, their session reaches nothing else.
+	MustChangePassword bool
 }
 
 func (p ProfileDTO) EncodeFields(w model.FieldWriter) {
@@ -264,6 +737,11 @@ func (p ProfileDTO) EncodeFields(w model.FieldWriter) {
 	w.String("email", p.Email)
 	w.String("avatar", p.Avatar)
 	w.String("locale", p.Locale)
+	var mustChange int64
+	if p.MustChangePassword {
+		mustChange = 1
+	}
+	w.Int("must_change_password", mustChange)
 	aw := w.Array("roles", len(p.Roles))
 	for _, r := range p.Roles {
 		aw.String(r)
@@ -289,6 +767,9 @@ func (p *ProfileDTO) DecodeFields(r model.FieldReader) {
 	p.Email, _ = r.String("email")
 	p.Avatar, _ = r.String("avatar")
 	p.Locale, _ = r.String("locale")
+	if v, ok := r.Int("must_change_password"); ok {
+		p.MustChangePassword = v != 0
+	}
 	if ar, ok := r.Array("roles"); ok {
 		p.Roles = make([]string, ar.Len())
 		for i := 0; i < ar.Len(); i++ {
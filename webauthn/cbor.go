@@ -0,0 +1,160 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/tinywasm/fmt"
+)
+
+// Just enough of RFC 8949 to read attestation objects and COSE keys. CTAP2
+// encodes both canonically, so tags, floats and indefinite lengths never
+// appear and are rejected.
+
+var errCBOR = fmt.Err("cbor", "invalid")
+
+// maxDepth bounds nesting; real attestation objects stay under four levels.
+const maxDepth = 8
+
+type cborPair struct{ key, val any }
+
+// cborMap keeps pairs in wire order. Keys are int64 or string.
+type cborMap []cborPair
+
+func (m cborMap) get(key any) (any, bool) {
+	for _, p := range m {
+		if p.key == key {
+			return p.val, true
+		}
+	}
+	return nil, false
+}
+
+func (m cborMap) int(key any) (int64, bool) {
+	v, _ := m.get(key)
+	n, ok := v.(int64)
+	return n, ok
+}
+
+func (m cborMap) bytes(key any) ([]byte, bool) {
+	v, _ := m.get(key)
+	b, ok := v.([]byte)
+	return b, ok
+}
+
+// decodeCBOR reads one item off the front of b and returns what follows it.
+// Items decode to int64, []byte, string, bool, nil, []any or cborMap.
+func decodeCBOR(b []byte) (any, []byte, error) { return decodeItem(b, 0) }
+
+func decodeItem(b []byte, depth int) (any, []byte, error) {
+	if depth > maxDepth || len(b) == 0 {
+		return nil, nil, errCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		}
+		return nil, nil, errCBOR
+	}
+	n, b, err := readArg(info, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(n), b, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(n), b, nil
+	case 2, 3:
+		if n > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		if major == 3 {
+			return string(b[:n]), b[n:], nil
+		}
+		return b[:n:n], b[n:], nil
+	case 4:
+		if n > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		arr := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var v any
+			if v, b, err = decodeItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, b, nil
+	case 5:
+		if n > uint64(len(b)) {
+			return nil, nil, errCBOR
+		}
+		m := make(cborMap, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var k, v any
+			if k, b, err = decodeItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if v, b, err = decodeItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m = append(m, cborPair{k, v})
+		}
+		return m, b, nil
+	}
+	return nil, nil, errCBOR
+}
+
+// readArg reads the length or value that follows an initial byte.
+func readArg(info byte, b []byte) (uint64, []byte, error) {
+	size := 0
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, nil, errCBOR
+	}
+	if len(b) < size {
+		return 0, nil, errCBOR
+	}
+	var n uint64
+	switch size {
+	case 1:
+		n = uint64(b[0])
+	case 2:
+		n = uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		n = uint64(binary.BigEndian.Uint32(b))
+	case 8:
+		n = binary.BigEndian.Uint64(b)
+	}
+	return n, b[size:], nil
+}
@@ -0,0 +1,236 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/tinywasm/fmt"
+)
+
+// COSE algorithm identifiers this package verifies, in the order Mount offers
+// them to authenticators.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// algs is what registration begin advertises in pubKeyCredParams.
+var algs = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+var (
+	errKey         = fmt.Err("key", "unsupported")
+	errAuthData    = fmt.Err("authenticator", "data", "invalid")
+	errAttestation = fmt.Err("attestation", "statement", "invalid")
+)
+
+// publicKey is a credential key parsed from its COSE_Key encoding.
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// verify checks sig over data: ASN.1 ECDSA for ES256, PKCS #1 v1.5 for RS256,
+// pure Ed25519 for EdDSA — the encodings WebAuthn authenticators produce.
+func (k publicKey) verify(data, sig []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		h := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, h[:], sig)
+	case *rsa.PublicKey:
+		h := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, sig)
+	}
+	return false
+}
+
+// parseCOSEKey reads one COSE_Key (RFC 9053) off the front of b and returns
+// what follows it.
+func parseCOSEKey(b []byte) (publicKey, []byte, error) {
+	v, rest, err := decodeCBOR(b)
+	if err != nil {
+		return publicKey{}, nil, err
+	}
+	m, ok := v.(cborMap)
+	if !ok {
+		return publicKey{}, nil, errKey
+	}
+	kty, _ := m.int(int64(1))
+	alg, _ := m.int(int64(3))
+	crv, _ := m.int(int64(-1))
+
+	switch {
+	case kty == 2 && alg == AlgES256 && crv == 1:
+		x, _ := m.bytes(int64(-2))
+		y, _ := m.bytes(int64(-3))
+		if len(x) != 32 || len(y) != 32 {
+			return publicKey{}, nil, errKey
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return publicKey{}, nil, errKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return publicKey{alg: alg, key: key}, rest, nil
+
+	case kty == 3 && alg == AlgRS256:
+		n, _ := m.bytes(int64(-1))
+		e, _ := m.bytes(int64(-2))
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return publicKey{}, nil, errKey
+		}
+		exp := new(big.Int).SetBytes(e)
+		if exp.Int64() < 3 {
+			return publicKey{}, nil, errKey
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		return publicKey{alg: alg, key: key}, rest, nil
+
+	case kty == 1 && alg == AlgEdDSA && crv == 6:
+		x, _ := m.bytes(int64(-2))
+		if len(x) != ed25519.PublicKeySize {
+			return publicKey{}, nil, errKey
+		}
+		return publicKey{alg: alg, key: ed25519.PublicKey(x)}, rest, nil
+	}
+	return publicKey{}, nil, errKey
+}
+
+// Authenticator data flags.
+const (
+	flagUP = 0x01 // user present
+	flagUV = 0x04 // user verified
+	flagAT = 0x40 // attested credential data included
+	flagED = 0x80 // extensions included
+)
+
+// authData is the parsed authenticator data both ceremonies sign over.
+type authData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// Set only when flagAT is: registration.
+	aaguid []byte
+	credID []byte
+	key    publicKey
+	rawKey []byte
+}
+
+func parseAuthData(b []byte) (authData, error) {
+	if len(b) < 37 {
+		return authData{}, errAuthData
+	}
+	d := authData{rpIDHash: b[:32], flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37])}
+	rest := b[37:]
+
+	if d.flags&flagAT != 0 {
+		if len(rest) < 18 {
+			return authData{}, errAuthData
+		}
+		d.aaguid = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if n == 0 || n > 1023 || len(rest) < n {
+			return authData{}, errAuthData
+		}
+		d.credID, rest = rest[:n], rest[n:]
+		key, after, err := parseCOSEKey(rest)
+		if err != nil {
+			return authData{}, err
+		}
+		d.key, d.rawKey, rest = key, rest[:len(rest)-len(after)], after
+	}
+	if d.flags&flagED != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return authData{}, err
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return authData{}, errAuthData
+	}
+	return d, nil
+}
+
+// oidAAGUID is the id-fido-gen-ce-aaguid certificate extension.
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyAttestation checks an attestation statement (WebAuthn §8.2, §8.7).
+// "packed" is verified either as self attestation, signed by the credential
+// key itself, or against the leaf of x5c. Chains aren't walked to a trusted
+// root: like most relying parties we take attestation as a sanity check, not
+// as a policy on which authenticator models may register.
+func verifyAttestation(format string, stmt cborMap, d authData, rawAuthData, clientDataHash []byte) error {
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return errAttestation
+		}
+		return nil
+	case "packed":
+	default:
+		return errAttestation
+	}
+
+	alg, _ := stmt.int("alg")
+	sig, _ := stmt.bytes("sig")
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash...)
+
+	x5c, hasX5C := stmt.get("x5c")
+	if !hasX5C {
+		if alg != d.key.alg || !d.key.verify(signed, sig) {
+			return errAttestation
+		}
+		return nil
+	}
+
+	chain, ok := x5c.([]any)
+	if !ok || len(chain) == 0 {
+		return errAttestation
+	}
+	der, ok := chain[0].([]byte)
+	if !ok {
+		return errAttestation
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil || cert.Version != 3 || cert.IsCA {
+		return errAttestation
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidAAGUID) {
+			continue
+		}
+		var aaguid []byte
+		if _, err := asn1.Unmarshal(ext.Value, &aaguid); err != nil || subtle.ConstantTimeCompare(aaguid, d.aaguid) != 1 {
+			return errAttestation
+		}
+	}
+
+	var key publicKey
+	switch pub := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		key = publicKey{alg: AlgES256, key: pub}
+	case *rsa.PublicKey:
+		key = publicKey{alg: AlgRS256, key: pub}
+	case ed25519.PublicKey:
+		key = publicKey{alg: AlgEdDSA, key: pub}
+	}
+	if key.key == nil || alg != key.alg || !key.verify(signed, sig) {
+		return errAttestation
+	}
+	return nil
+}
@@ -0,0 +1,486 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/tinywasm/fmt"
+	"github.com/tinywasm/json"
+	"github.com/tinywasm/model"
+	"github.com/tinywasm/router"
+	"github.com/tinywasm/user"
+)
+
+// Challenge purposes: a challenge minted for one ceremony can't answer another.
+const (
+	purposeRegister = "register"
+	purposeLogin    = "login"
+	purposeMFA      = "mfa"
+)
+
+// RelyingParty is the site credentials are scoped to. ID is its registrable
+// domain ("example.com"), Origin the exact scheme+host the browser reports
+// ("https://app.example.com"), Name what authenticators show.
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// Authenticator is the passkey mode. Registered credentials log in on their
+// own (passwordless, user verification required) and, being a
+// user.SecondFactor, also guard every other mode's logins for users who have
+// one: a partial session finishes at POST /webauthn/login/finish.
+type Authenticator struct {
+	store      user.IdentityStore
+	creds      user.CredentialStore
+	challenges user.ChallengeStore
+	mfa        user.PendingMFA
+	notify     user.SecurityNotifier
+	rp         RelyingParty
+	rpIDHash   [32]byte
+	afterLogin string
+	limiter    user.RateLimiter
+	trustProxy bool
+}
+
+type Option func(*Authenticator)
+
+func WithAfterLogin(path string) Option { return func(a *Authenticator) { a.afterLogin = path } }
+func WithTrustProxy(v bool) Option      { return func(a *Authenticator) { a.trustProxy = v } }
+
+// WithRateLimiter limits the login routes per client IP (e.g. ratelimit.New).
+func WithRateLimiter(l user.RateLimiter) Option { return func(a *Authenticator) { a.limiter = l } }
+
+// New builds the mode. A passkey login ends the way a second factor does,
+// through mfa.CompleteMFA: a user-verified assertion is already two factors.
+func New(store user.IdentityStore, creds user.CredentialStore, challenges user.ChallengeStore, mfa user.PendingMFA, notify user.SecurityNotifier, rp RelyingParty, opts ...Option) *Authenticator {
+	a := &Authenticator{store: store, creds: creds, challenges: challenges, mfa: mfa, notify: notify, rp: rp}
+	a.rpIDHash = sha256.Sum256([]byte(rp.ID))
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *Authenticator) Name() string { return "webauthn" }
+
+// CreationOptions is the answer to POST /webauthn/register/begin, flattened
+// from PublicKeyCredentialCreationOptions. Binary values (challenge, user_id,
+// exclude_credentials) are base64url without padding; algs are COSE
+// identifiers as decimal strings, most preferred first.
+type CreationOptions struct {
+	Challenge        string
+	RPID             string
+	RPName           string
+	UserID           string
+	UserName         string
+	UserDisplayName  string
+	UserVerification string
+	Algs             []int
+	Exclude          []string
+}
+
+func (o *CreationOptions) IsNil() bool { return o == nil }
+func (o *CreationOptions) EncodeFields(w model.FieldWriter) {
+	w.String("challenge", o.Challenge)
+	w.String("rp_id", o.RPID)
+	w.String("rp_name", o.RPName)
+	w.String("user_id", o.UserID)
+	w.String("user_name", o.UserName)
+	w.String("user_display_name", o.UserDisplayName)
+	w.String("user_verification", o.UserVerification)
+	w.String("attestation", "none")
+	aw := w.Array("algs", len(o.Algs))
+	for _, alg := range o.Algs {
+		aw.String(fmt.Convert(alg).String())
+	}
+	aw.Close()
+	aw = w.Array("exclude_credentials", len(o.Exclude))
+	for _, id := range o.Exclude {
+		aw.String(id)
+	}
+	aw.Close()
+}
+
+// RequestOptions is the answer to POST /webauthn/login/begin, flattened from
+// PublicKeyCredentialRequestOptions. allow_credentials is empty for a
+// passwordless login: the authenticator offers its discoverable credentials.
+type RequestOptions struct {
+	Challenge        string
+	RPID             string
+	UserVerification string
+	Allow            []string
+}
+
+func (o *RequestOptions) IsNil() bool { return o == nil }
+func (o *RequestOptions) EncodeFields(w model.FieldWriter) {
+	w.String("challenge", o.Challenge)
+	w.String("rp_id", o.RPID)
+	w.String("user_verification", o.UserVerification)
+	aw := w.Array("allow_credentials", len(o.Allow))
+	for _, id := range o.Allow {
+		aw.String(id)
+	}
+	aw.Close()
+}
+
+// registrationData is the browser's PublicKeyCredential from create(), its
+// binary fields base64url encoded.
+type registrationData struct{ ID, ClientData, AttestationObject string }
+
+func (d *registrationData) IsNil() bool { return d == nil }
+func (d *registrationData) DecodeFields(r model.FieldReader) {
+	d.ID, _ = r.String("id")
+	d.ClientData, _ = r.String("client_data")
+	d.AttestationObject, _ = r.String("attestation_object")
+}
+
+// assertionData is the browser's PublicKeyCredential from get().
+type assertionData struct{ ID, ClientData, AuthenticatorData, Signature, UserHandle, Remember string }
+
+func (d *assertionData) IsNil() bool { return d == nil }
+func (d *assertionData) DecodeFields(r model.FieldReader) {
+	d.ID, _ = r.String("id")
+	d.ClientData, _ = r.String("client_data")
+	d.AuthenticatorData, _ = r.String("authenticator_data")
+	d.Signature, _ = r.String("signature")
+	d.UserHandle, _ = r.String("user_handle")
+	d.Remember, _ = r.String("remember")
+}
+
+// Mount serves both ceremonies. Registration is for the signed-in user;
+// login is public and serves either a passwordless login or, when ctx carries
+// a partial session, its second step.
+func (a *Authenticator) Mount(r router.Router) {
+	afterLogin := a.afterLogin
+	if afterLogin == "" {
+		afterLogin = user.PathAfterLogin
+	}
+
+	r.Post(user.PathWebAuthnRegisterBegin, func(ctx router.Context) {
+		userID := caller(ctx)
+		if userID == "" {
+			ctx.WriteStatus(401)
+			return
+		}
+		u, err := a.store.UserByID(userID)
+		if err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		challenge, err := a.challenges.CreateChallenge(userID, purposeRegister)
+		if err != nil {
+			ctx.WriteStatus(500)
+			return
+		}
+		ctx.Encode(&CreationOptions{
+			Challenge:        challenge,
+			RPID:             a.rp.ID,
+			RPName:           a.rp.Name,
+			UserID:           base64.RawURLEncoding.EncodeToString([]byte(u.Id)),
+			UserName:         u.Email,
+			UserDisplayName:  u.Name,
+			UserVerification: "preferred",
+			Algs:             algs,
+			Exclude:          a.credentialIDs(userID),
+		})
+	}).Authenticated()
+
+	r.Post(user.PathWebAuthnRegisterFinish, func(ctx router.Context) {
+		userID := caller(ctx)
+		if userID == "" {
+			ctx.WriteStatus(401)
+			return
+		}
+		data := &registrationData{}
+		if err := ctx.Decode(data); err != nil {
+			ctx.WriteStatus(400)
+			return
+		}
+		c, err := a.register(userID, data)
+		if err != nil {
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, user.ErrInvalidAttestation)
+			return
+		}
+		if err := a.creds.SaveCredential(c); err != nil {
+			if err == user.ErrInvalidAttestation {
+				user.RespondError(ctx, 409, user.CodeInvalidRequest, err)
+				return
+			}
+			ctx.WriteStatus(500)
+			return
+		}
+		ctx.WriteStatus(201)
+	}).Authenticated()
+
+	r.Post(user.PathWebAuthnLoginBegin, func(ctx router.Context) {
+		if a.limited(ctx, user.ClientIP(ctx, a.trustProxy)) {
+			return
+		}
+		pending, _ := a.mfa.PendingUser(ctx)
+		purpose, uv := purposeLogin, "required"
+		if pending != "" {
+			purpose, uv = purposeMFA, "discouraged"
+		}
+		challenge, err := a.challenges.CreateChallenge(pending, purpose)
+		if err == user.ErrRateLimited {
+			a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, IP: user.ClientIP(ctx, a.trustProxy), Mode: a.Name()})
+			user.RespondError(ctx, 429, user.CodeRateLimited, err)
+			return
+		}
+		if err != nil {
+			user.RespondError(ctx, 500, user.CodeServerError, nil)
+			return
+		}
+		opts := &RequestOptions{Challenge: challenge, RPID: a.rp.ID, UserVerification: uv}
+		if pending != "" {
+			opts.Allow = a.credentialIDs(pending)
+		}
+		ctx.Encode(opts)
+	}).Public()
+
+	r.Post(user.PathWebAuthnLoginFinish, func(ctx router.Context) {
+		ip := user.ClientIP(ctx, a.trustProxy)
+		if a.limited(ctx, ip) {
+			return
+		}
+		data := &assertionData{}
+		if err := ctx.Decode(data); err != nil {
+			user.RespondError(ctx, 400, user.CodeInvalidRequest, nil)
+			return
+		}
+
+		pending, _ := a.mfa.PendingUser(ctx)
+		userID, err := a.assert(data, pending)
+		if err != nil {
+			if err == errCloned {
+				a.notify.Notify(user.SecurityEvent{Type: user.EventCredentialCloned, IP: ip, UserID: userID, Mode: a.Name()})
+			} else if pending != "" {
+				a.notify.Notify(user.SecurityEvent{Type: user.EventMFAFailed, IP: ip, UserID: pending, Mode: a.Name()})
+			}
+			user.RespondError(ctx, 401, user.CodeInvalidCredentials, user.ErrInvalidCredentials)
+			return
+		}
+		u, err := a.store.UserByID(userID)
+		if err != nil {
+			user.RespondError(ctx, 401, user.CodeInvalidCredentials, user.ErrInvalidCredentials)
+			return
+		}
+		if u.Status != "active" {
+			a.notify.Notify(user.SecurityEvent{Type: user.EventNonActiveAccess, IP: ip, UserID: u.Id, Mode: a.Name()})
+			user.RespondError(ctx, 401, user.CodeSuspended, nil)
+			return
+		}
+
+		if err := a.mfa.CompleteMFA(ctx, u.Id, user.WithRemember(user.Checked(data.Remember))); err != nil {
+			user.RespondError(ctx, 500, user.CodeServerError, nil)
+			return
+		}
+		user.RespondLogin(ctx, user.NextOr(ctx, afterLogin), a.store, u)
+	}).Public()
+}
+
+// Enrolled makes Authenticator a user.SecondFactor: a user with a passkey
+// finishes every other mode's login with it.
+func (a *Authenticator) Enrolled(userID string) bool {
+	creds, err := a.creds.CredentialsFor(userID)
+	return err == nil && len(creds) > 0
+}
+
+var errCloned = fmt.Err("sign", "count", "invalid")
+
+// register runs the registration ceremony checks (WebAuthn §7.1) and returns
+// the credential to store.
+func (a *Authenticator) register(userID string, data *registrationData) (user.WebAuthnCredential, error) {
+	rawClient, err := decode(data.ClientData)
+	if err != nil {
+		return user.WebAuthnCredential{}, err
+	}
+	challenge, err := a.clientData(rawClient, "webauthn.create")
+	if err != nil {
+		return user.WebAuthnCredential{}, err
+	}
+	if bound, err := a.challenges.ConsumeChallenge(challenge, purposeRegister); err != nil || bound != userID {
+		return user.WebAuthnCredential{}, user.ErrInvalidAttestation
+	}
+
+	rawAtt, err := decode(data.AttestationObject)
+	if err != nil {
+		return user.WebAuthnCredential{}, err
+	}
+	v, rest, err := decodeCBOR(rawAtt)
+	att, ok := v.(cborMap)
+	if err != nil || !ok || len(rest) != 0 {
+		return user.WebAuthnCredential{}, errAttestation
+	}
+	format, _ := att.get("fmt")
+	stmt, _ := att.get("attStmt")
+	rawAuth, _ := att.bytes("authData")
+	f, _ := format.(string)
+	s, ok := stmt.(cborMap)
+	if !ok {
+		return user.WebAuthnCredential{}, errAttestation
+	}
+
+	d, err := parseAuthData(rawAuth)
+	if err != nil {
+		return user.WebAuthnCredential{}, err
+	}
+	if d.flags&flagAT == 0 || !a.checkAuthData(d, false) {
+		return user.WebAuthnCredential{}, errAuthData
+	}
+	id := base64.RawURLEncoding.EncodeToString(d.credID)
+	if id != data.ID {
+		return user.WebAuthnCredential{}, errAuthData
+	}
+	clientHash := sha256.Sum256(rawClient)
+	if err := verifyAttestation(f, s, d, rawAuth, clientHash[:]); err != nil {
+		return user.WebAuthnCredential{}, err
+	}
+	return user.WebAuthnCredential{
+		Id:        id,
+		UserId:    userID,
+		PublicKey: base64.RawURLEncoding.EncodeToString(d.rawKey),
+		SignCount: int64(d.signCount),
+	}, nil
+}
+
+// assert runs the authentication ceremony checks (WebAuthn §7.2) and returns
+// whose credential signed. pending is the user a partial session awaits, ""
+// for a passwordless login.
+func (a *Authenticator) assert(data *assertionData, pending string) (string, error) {
+	purpose := purposeLogin
+	if pending != "" {
+		purpose = purposeMFA
+	}
+	rawClient, err := decode(data.ClientData)
+	if err != nil {
+		return "", err
+	}
+	challenge, err := a.clientData(rawClient, "webauthn.get")
+	if err != nil {
+		return "", err
+	}
+	if bound, err := a.challenges.ConsumeChallenge(challenge, purpose); err != nil || bound != pending {
+		return "", user.ErrInvalidCredentials
+	}
+
+	c, err := a.creds.CredentialByID(data.ID)
+	if err != nil || (pending != "" && c.UserId != pending) {
+		return "", user.ErrInvalidCredentials
+	}
+	if data.UserHandle != "" {
+		handle, err := decode(data.UserHandle)
+		if err != nil || string(handle) != c.UserId {
+			return "", user.ErrInvalidCredentials
+		}
+	}
+
+	rawAuth, err := decode(data.AuthenticatorData)
+	if err != nil {
+		return "", err
+	}
+	d, err := parseAuthData(rawAuth)
+	if err != nil || d.flags&flagAT != 0 || !a.checkAuthData(d, pending == "") {
+		return "", errAuthData
+	}
+	rawKey, err := decode(c.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	key, _, err := parseCOSEKey(rawKey)
+	if err != nil {
+		return "", err
+	}
+	sig, err := decode(data.Signature)
+	if err != nil {
+		return "", err
+	}
+	clientHash := sha256.Sum256(rawClient)
+	if !key.verify(append(append([]byte{}, rawAuth...), clientHash[:]...), sig) {
+		return "", user.ErrInvalidCredentials
+	}
+
+	// Authenticators that keep no counter always report 0; any other must
+	// move forward, or two copies of the key are in use.
+	count := int64(d.signCount)
+	if count != 0 || c.SignCount != 0 {
+		if count <= c.SignCount {
+			return c.UserId, errCloned
+		}
+		if err := a.creds.UpdateSignCount(c.Id, count); err != nil {
+			return "", err
+		}
+	}
+	return c.UserId, nil
+}
+
+// collectedClientData is the part of the browser's clientDataJSON checked.
+type collectedClientData struct{ Type, Challenge, Origin string }
+
+func (d *collectedClientData) IsNil() bool { return d == nil }
+func (d *collectedClientData) DecodeFields(r model.FieldReader) {
+	d.Type, _ = r.String("type")
+	d.Challenge, _ = r.String("challenge")
+	d.Origin, _ = r.String("origin")
+}
+
+// clientData checks the collected client data's type and origin and returns
+// its challenge.
+func (a *Authenticator) clientData(raw []byte, typ string) (string, error) {
+	var cd collectedClientData
+	if err := json.Decode(raw, &cd); err != nil {
+		return "", err
+	}
+	if cd.Type != typ || cd.Origin != a.rp.Origin || cd.Challenge == "" {
+		return "", user.ErrInvalidCredentials
+	}
+	return cd.Challenge, nil
+}
+
+// checkAuthData checks the RP ID hash and the user present flag, and user
+// verified too when uv.
+func (a *Authenticator) checkAuthData(d authData, uv bool) bool {
+	if subtle.ConstantTimeCompare(d.rpIDHash, a.rpIDHash[:]) != 1 || d.flags&flagUP == 0 {
+		return false
+	}
+	return !uv || d.flags&flagUV != 0
+}
+
+func (a *Authenticator) credentialIDs(userID string) []string {
+	creds, _ := a.creds.CredentialsFor(userID)
+	ids := make([]string, len(creds))
+	for i, c := range creds {
+		ids[i] = c.Id
+	}
+	return ids
+}
+
+// limited answers 429 when the limiter rejects ctx's client IP.
+func (a *Authenticator) limited(ctx router.Context, ip string) bool {
+	if user.Allowed(a.limiter, ip, "") {
+		return false
+	}
+	a.notify.Notify(user.SecurityEvent{Type: user.EventRateLimited, IP: ip, Mode: a.Name()})
+	user.RespondError(ctx, 429, user.CodeRateLimited, user.ErrRateLimited)
+	return true
+}
+
+// caller is the signed-in user managing their passkeys. A restricted session
+// (see user.ScopedUserID) can't.
+func caller(ctx router.Context) string {
+	scope, userID := user.SplitScopedUserID(ctx.UserID())
+	if scope != "" {
+		return ""
+	}
+	return userID
+}
+
+func decode(s string) ([]byte, error) { return base64.RawURLEncoding.DecodeString(s) }
+
+var (
+	_ user.Authenticator = (*Authenticator)(nil)
+	_ user.SecondFactor  = (*Authenticator)(nil)
+)